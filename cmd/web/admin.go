@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/matthewlmitchell/tempshare/pkg/models"
+)
+
+// How many days the charts of the admin dashboard cover
+const dashboardDays = 14
+
+// How many of the clients rejected by the rate limiter are listed on the admin dashboard
+const dashboardClients = 10
+
+// dashboard holds the statistics shown on the admin dashboard. None of them can be traced
+// back to a single TempShare, and no text is ever shown.
+type dashboard struct {
+	Stats       *models.Stats
+	MostCreated int // Highest daily count of the charts, which their bars are scaled to
+	MostViewed  int
+
+	// Counted by the metrics since the server started
+	CreatedByKind map[string]int
+	Viewed        int
+	Expired       int
+	RateLimited   int
+
+	RateLimitEnabled bool
+	RejectedClients  []rejectedClient
+}
+
+// Storage returns the size of the stored texts, e.g. "1.5 MB"
+func (d *dashboard) Storage() string {
+
+	size := float64(d.Stats.StorageBytes)
+	for _, unit := range []string{"B", "KB", "MB", "GB"} {
+		if size < 1000 || unit == "GB" {
+			if unit == "B" {
+				return fmt.Sprintf("%.0f %s", size, unit)
+			}
+			return fmt.Sprintf("%.1f %s", size, unit)
+		}
+		size /= 1000
+	}
+
+	return ""
+}
+
+// adminDashboard shows aggregate statistics of the TempShares, from the database and from
+// the metrics, along with the clients rejected most by the rate limiter
+func (app *application) adminDashboard(w http.ResponseWriter, r *http.Request) {
+
+	stats, err := app.tempShare.Stats(r.Context(), dashboardDays)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := &dashboard{
+		Stats:            stats,
+		MostCreated:      1,
+		MostViewed:       1,
+		CreatedByKind:    map[string]int{},
+		RateLimitEnabled: app.rateLimiter != nil,
+	}
+
+	for _, day := range stats.Created {
+		data.MostCreated = max(data.MostCreated, day.Count)
+	}
+	for _, day := range stats.Viewed {
+		data.MostViewed = max(data.MostViewed, day.Count)
+	}
+
+	created, err := app.metrics.counterTotals("tempshare_shares_created_total", "kind")
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	for kind, count := range created {
+		data.CreatedByKind[kind] = int(count)
+	}
+
+	for name, count := range map[string]*int{
+		"tempshare_shares_viewed_total":         &data.Viewed,
+		"tempshare_shares_expired_total":        &data.Expired,
+		"tempshare_rate_limited_requests_total": &data.RateLimited,
+	} {
+		totals, err := app.metrics.counterTotals(name, "")
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		*count = int(totals[""])
+	}
+
+	if app.rateLimiter != nil {
+		data.RejectedClients = app.rateLimiter.top(dashboardClients)
+	}
+
+	app.render(w, r, "admin.page.tmpl", &templateData{Dashboard: data})
+}
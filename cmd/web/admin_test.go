@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestAdminDashboardRequiresLogin(t *testing.T) {
+
+	app := newTestApplication(t)
+	testServ := newTestServer(t, app.adminRoutes(), false)
+	defer testServ.Close()
+
+	// The dashboard takes signing in
+	statusCode, header, _ := testServ.get(t, "/admin")
+	if statusCode != http.StatusSeeOther || header.Get("Location") != "/admin/login" {
+		t.Fatalf("Expected a redirect to the login page, received %d %s", statusCode, header.Get("Location"))
+	}
+}
+
+func TestAdminDashboard(t *testing.T) {
+
+	testCases := []struct {
+		name             string
+		rateLimit        int
+		expectedResponse [][]byte
+	}{
+		{
+			name:      "Rate limiting disabled",
+			rateLimit: 0,
+			expectedResponse: [][]byte{
+				[]byte("Active TempShares by expiry"),
+				[]byte("0 single, 0 split, 0 sent to several recipients"),
+				[]byte("Rate limiting is disabled."),
+			},
+		},
+		{
+			name:      "Rate limited client",
+			rateLimit: 1,
+			expectedResponse: [][]byte{
+				[]byte("192.0.2.1"),
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			app.metrics.sharesViewed.Inc()
+
+			testServ := newTestServer(t, app.adminRoutes(), false)
+			defer testServ.Close()
+
+			loginAdmin(t, testServ)
+
+			if testCase.rateLimit > 0 {
+				app.rateLimiter = newRateLimiter(testCase.rateLimit)
+				for i := 0; i < 3; i++ {
+					app.rateLimiter.allow("192.0.2.1", time.Now())
+				}
+			}
+
+			statusCode, header, responseBody := testServ.get(t, "/admin")
+
+			if statusCode != http.StatusOK {
+				t.Fatalf("Expected status %d, received %d", http.StatusOK, statusCode)
+			}
+
+			if header.Get("Content-Security-Policy") == "" {
+				t.Error("Expected the dashboard to be sent with a CSP")
+			}
+
+			for _, expected := range testCase.expectedResponse {
+				if !bytes.Contains(responseBody, expected) {
+					t.Errorf("Expected %s in response body, received %s", expected, responseBody)
+				}
+			}
+
+			// The statistics never include the text of a TempShare
+			if bytes.Contains(responseBody, []byte("This is an example tempshare")) {
+				t.Error("Expected no share text on the dashboard")
+			}
+		})
+	}
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/matthewlmitchell/tempshare/pkg/forms"
+	"github.com/matthewlmitchell/tempshare/pkg/i18n"
+	"github.com/matthewlmitchell/tempshare/pkg/models"
+	"github.com/matthewlmitchell/tempshare/pkg/webhook"
+)
+
+// The API takes the same fields as the pages, sent as a JSON object, and is validated by the
+// same forms. It has no sessions, so it needs no CSRF protection: browsers won't send a
+// cross-site request with a JSON body without asking the server first, which it never allows.
+
+// apiError is the body of an API response to a request which failed. Fields holds the error
+// messages of each invalid field.
+type apiError struct {
+	Error  string              `json:"error"`
+	Fields map[string][]string `json:"fields,omitempty"`
+}
+
+// apiTempShare is a TempShare created through the API
+type apiTempShare struct {
+	Link       string     `json:"link"`
+	ManageLink string     `json:"manageLink,omitempty"`
+	Recipient  string     `json:"recipient,omitempty"`
+	Expires    time.Time  `json:"expires"`
+	NotBefore  *time.Time `json:"notBefore,omitempty"`
+	Passphrase string     `json:"passphrase,omitempty"`
+}
+
+// apiCreated is the body of the response to creating TempShares. A secret split into parts
+// has a TempShare for each part, and one sent to several recipients has one for each of them,
+// all of which are managed by ManageLink.
+type apiCreated struct {
+	TempShares []apiTempShare `json:"tempshares"`
+	ManageLink string         `json:"manageLink,omitempty"`
+	Threshold  int            `json:"threshold,omitempty"`
+	EmailedTo  string         `json:"emailedTo,omitempty"`
+}
+
+// apiSecret is the body of the response to combining the parts of a split secret
+type apiSecret struct {
+	Text    string    `json:"text"`
+	Created time.Time `json:"created"`
+	Expires time.Time `json:"expires"`
+}
+
+// apiCatalog returns the catalog the messages of an API response are translated with, which
+// is chosen by the Accept-Language header alone
+func (app *application) apiCatalog(r *http.Request) *i18n.Catalog {
+	return app.locales().Match(r.Header.Get("Accept-Language"))
+}
+
+// writeJSON writes v as the JSON body of a response with the given status
+func (app *application) writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	json.NewEncoder(w).Encode(v)
+}
+
+// apiClientError responds with a translated error message and, if the request failed because
+// of its fields, the error messages of the form
+func (app *application) apiClientError(w http.ResponseWriter, r *http.Request, status int, message string, form *forms.Form) {
+
+	catalog := app.apiCatalog(r)
+
+	body := &apiError{Error: catalog.T(message)}
+	if form != nil && !form.Valid() {
+		body.Fields = map[string][]string{}
+		for field, messages := range form.Errors {
+			for _, message := range messages {
+				body.Fields[field] = append(body.Fields[field], catalog.Translate(message))
+			}
+		}
+	}
+
+	app.writeJSON(w, status, body)
+}
+
+// parseJSONForm reads the JSON object in the body of an API request into a form. If the body
+// isn't a JSON object, the client is told so and false is returned.
+func (app *application) parseJSONForm(w http.ResponseWriter, r *http.Request) (*forms.Form, bool) {
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "application/json" {
+		app.apiClientError(w, r, http.StatusUnsupportedMediaType, "The request body must be sent as application/json", nil)
+		return nil, false
+	}
+
+	form, err := forms.FromJSON(r.Body)
+	if err != nil {
+		app.apiClientError(w, r, http.StatusBadRequest, "The request body must be a JSON object of strings, numbers and booleans", nil)
+		return nil, false
+	}
+
+	return form, true
+}
+
+// apiCreateTempShare creates the same TempShares as the create page from a JSON object of its
+// fields, and responds with their links
+func (app *application) apiCreateTempShare(w http.ResponseWriter, r *http.Request) {
+
+	form, ok := app.parseJSONForm(w, r)
+	if !ok {
+		return
+	}
+
+	data := &createForm{}
+	if err := form.Bind(data); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	data.validate(form)
+
+	if !form.Valid() {
+		app.apiClientError(w, r, http.StatusUnprocessableEntity, "Please correct the errors in the request", form)
+		return
+	}
+
+	success, err := app.verifyCaptcha(r, data.Captcha)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if !success {
+		app.apiClientError(w, r, http.StatusUnprocessableEntity, "The captcha could not be verified", nil)
+		return
+	}
+
+	// Read receipts and links can only be emailed if an SMTP server has been configured
+	notifyEmail, recipientEmail := "", ""
+	if app.mailer != nil {
+		notifyEmail = data.Notify
+		recipientEmail = data.Email
+	}
+
+	created := &apiCreated{}
+	var tempShares []*models.TempShare
+
+	switch {
+	case data.Parts != 0:
+		tempShares, err = app.tempShare.NewSplit(r.Context(), data.Text, data.Expires, data.ViewLimit, data.Parts, data.Threshold, data.NotBefore)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		app.metrics.sharesCreated.WithLabelValues("split").Inc()
+		created.Threshold = data.Threshold
+
+	case len(data.recipients()) > 0:
+		var manageToken string
+		manageToken, tempShares, err = app.tempShare.NewMulti(r.Context(), data.Text, data.Expires, data.ViewLimit, notifyEmail, data.recipients(), data.NotBefore)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		app.metrics.sharesCreated.WithLabelValues("multi").Inc()
+		created.ManageLink = fmt.Sprintf("%s/manage?token=%s", app.serverConfig.baseURL, manageToken)
+
+	default:
+		tempShare, err := app.tempShare.New(r.Context(), data.Text, data.Expires, data.ViewLimit, notifyEmail, data.Passphrase, data.NotBefore)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		app.metrics.sharesCreated.WithLabelValues("single").Inc()
+		tempShares = []*models.TempShare{tempShare}
+	}
+
+	for _, tempShare := range tempShares {
+		tempShare := tempShare
+		app.runInBackground(func() {
+			app.emitEvent(context.WithoutCancel(r.Context()), webhook.EventCreated, tempShare, "")
+		})
+
+		link := apiTempShare{
+			Link:       fmt.Sprintf("%s/view?token=%s", app.serverConfig.baseURL, tempShare.PlainText),
+			Recipient:  tempShare.Recipient,
+			Expires:    tempShare.Expires,
+			Passphrase: tempShare.Passphrase,
+		}
+		if created.ManageLink == "" {
+			link.ManageLink = fmt.Sprintf("%s/manage?token=%s", app.serverConfig.baseURL, tempShare.ManageToken)
+		}
+		if !tempShare.NotBefore.IsZero() {
+			link.NotBefore = &tempShare.NotBefore
+		}
+
+		created.TempShares = append(created.TempShares, link)
+	}
+
+	// Only a single TempShare can be emailed, validate makes sure of that
+	if recipientEmail != "" {
+		tempShare := tempShares[0]
+		err = app.mailer.Enqueue(recipientEmail, "share.mail.tmpl", map[string]interface{}{
+			"Link":      created.TempShares[0].Link,
+			"Expires":   FormattedDate(tempShare.Expires) + " UTC",
+			"ViewLimit": tempShare.ViewLimit,
+		})
+		if err != nil {
+			app.loggerFrom(r.Context()).Error("failed to queue email", "error", err)
+		} else {
+			created.EmailedTo = recipientEmail
+		}
+	}
+
+	app.writeJSON(w, http.StatusCreated, created)
+}
+
+// apiCombineTempShare combines the parts of a split secret, like the combine page, and responds
+// with its text. The links or tokens of the parts may be sent as an array, or as a string with
+// one on each line.
+func (app *application) apiCombineTempShare(w http.ResponseWriter, r *http.Request) {
+
+	form, ok := app.parseJSONForm(w, r)
+	if !ok {
+		return
+	}
+
+	if tokens := form.Values["tokens"]; len(tokens) > 1 {
+		form.Set("tokens", strings.Join(tokens, "\n"))
+	}
+
+	data := &combineForm{}
+	if err := form.Bind(data); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	data.validate(form)
+
+	if !form.Valid() {
+		app.apiClientError(w, r, http.StatusUnprocessableEntity, "Please correct the errors in the request", form)
+		return
+	}
+
+	success, err := app.verifyCaptcha(r, data.Captcha)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if !success {
+		app.apiClientError(w, r, http.StatusUnprocessableEntity, "The captcha could not be verified", nil)
+		return
+	}
+
+	secret, tempShares, err := app.tempShare.Combine(r.Context(), data.tokens())
+	if data.addError(form, err) {
+		app.apiClientError(w, r, http.StatusUnprocessableEntity, "The secret could not be combined", form)
+		return
+	} else if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	for _, tempShare := range tempShares {
+		app.recordView(r, tempShare)
+	}
+
+	app.writeJSON(w, http.StatusOK, &apiSecret{
+		Text:    secret,
+		Created: tempShares[0].Created,
+		Expires: tempShares[0].Expires,
+	})
+}
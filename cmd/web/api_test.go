@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestAPICreateTempShare(t *testing.T) {
+	app := newTestApplication(t)
+
+	testServ := newTestServer(t, app.routes(), false)
+	defer testServ.Close()
+
+	notBefore := time.Now().UTC().Add(time.Hour).Truncate(time.Second)
+
+	testCases := []struct {
+		name               string
+		contentType        string
+		body               string
+		expectedStatusCode int
+		expectedResponse   []byte
+	}{
+		{"Valid submission", "application/json",
+			`{"text": "Hello World", "expires": 1, "viewlimit": 1, "g-recaptcha-response": "test"}`,
+			http.StatusCreated, []byte(`"link":"https://placeholder.com/view?token=MUPPH5PDKV7AGCUAAEERL5ARIXICVVGYLRIV365X5XSV3EKISAXQ"`)},
+		{"Charset", "application/json; charset=utf-8",
+			`{"text": "Hello World", "expires": 1, "viewlimit": 1, "g-recaptcha-response": "test"}`,
+			http.StatusCreated, []byte(`"manageLink":"https://placeholder.com/manage?token=Q3NXTLOVHBWY2GWCQVJ4WDPHJ6B7TM2ZK5XRDFAE6OUKIRPLSM4Q"`)},
+		{"Not before", "application/json",
+			fmt.Sprintf(`{"text": "Hello World", "expires": 1, "viewlimit": 1, "notbefore": %q, "g-recaptcha-response": "test"}`, notBefore.Format(time.RFC3339)),
+			http.StatusCreated, []byte(fmt.Sprintf(`"notBefore":%q`, notBefore.Format(time.RFC3339)))},
+		{"Not before in the past", "application/json",
+			`{"text": "Hello World", "expires": 1, "viewlimit": 1, "notbefore": "2020-01-01T00:00:00Z", "g-recaptcha-response": "test"}`,
+			http.StatusUnprocessableEntity, []byte(`"notbefore":["This field must be in the future"]`)},
+		{"Passphrase", "application/json",
+			`{"text": "Hello World", "expires": 1, "viewlimit": 1, "passphrase": true, "g-recaptcha-response": "test"}`,
+			http.StatusCreated, []byte(`"passphrase":"ABCDE-FGHIJ-KLMNO-PQRST"`)},
+		{"Split", "application/json",
+			`{"text": "Hello World", "expires": 1, "viewlimit": 1, "parts": 3, "threshold": 2, "g-recaptcha-response": "test"}`,
+			http.StatusCreated, []byte(`"threshold":2`)},
+		{"Recipients", "application/json",
+			`{"text": "Hello World", "expires": 1, "viewlimit": 1, "recipients": "Alice\nBob", "g-recaptcha-response": "test"}`,
+			http.StatusCreated, []byte(`"recipient":"Bob"`)},
+		{"Empty text", "application/json",
+			`{"text": "", "expires": 1, "viewlimit": 1, "g-recaptcha-response": "test"}`,
+			http.StatusUnprocessableEntity, []byte(`"text":["This field must not be blank"]`)},
+		{"Invalid expiry", "application/json",
+			`{"text": "Hello World", "expires": 2, "viewlimit": 1, "g-recaptcha-response": "test"}`,
+			http.StatusUnprocessableEntity, []byte(`"expires":["This field is invalid."]`)},
+		{"No captcha", "application/json",
+			`{"text": "Hello World", "expires": 1, "viewlimit": 1}`,
+			http.StatusUnprocessableEntity, []byte(`"g-recaptcha-response":["This field must not be blank"]`)},
+		{"Not an object", "application/json",
+			`["Hello World"]`,
+			http.StatusBadRequest, []byte(`"error":"The request body must be a JSON object of strings, numbers and booleans"`)},
+		{"Nested object", "application/json",
+			`{"text": {"value": "Hello World"}}`,
+			http.StatusBadRequest, []byte(`"error":"The request body must be a JSON object of strings, numbers and booleans"`)},
+		{"Form content type", "application/x-www-form-urlencoded",
+			`text=Hello+World&expires=1&viewlimit=1`,
+			http.StatusUnsupportedMediaType, []byte(`"error":"The request body must be sent as application/json"`)},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			statusCode, header, responseBody := testServ.postJSON(t, "/api/create", testCase.contentType, testCase.body)
+
+			if statusCode != testCase.expectedStatusCode {
+				t.Errorf("Expected status %d, received status %d", testCase.expectedStatusCode, statusCode)
+			}
+
+			if contentType := header.Get("Content-Type"); contentType != "application/json" {
+				t.Errorf("Expected Content-Type application/json, received %q", contentType)
+			}
+
+			if !bytes.Contains(responseBody, testCase.expectedResponse) {
+				t.Errorf("Expected %s, received %s", testCase.expectedResponse, responseBody)
+			}
+		})
+	}
+}
+
+func TestAPICombineTempShare(t *testing.T) {
+	app := newTestApplication(t)
+
+	testServ := newTestServer(t, app.routes(), false)
+	defer testServ.Close()
+
+	testCases := []struct {
+		name               string
+		body               string
+		expectedStatusCode int
+		expectedResponse   []byte
+	}{
+		{"Array of tokens",
+			`{"tokens": ["SPLITPARTONEAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA", "https://placeholder.com/view?token=SPLITPARTTHREEAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA"], "g-recaptcha-response": "test"}`,
+			http.StatusOK, []byte(`"text":"This is an example tempshare for testing purposes!"`)},
+		{"Tokens on lines",
+			`{"tokens": "SPLITPARTONEAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA\nSPLITPARTTWOAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA", "g-recaptcha-response": "test"}`,
+			http.StatusOK, []byte(`"text":"This is an example tempshare for testing purposes!"`)},
+		{"Threshold not met",
+			`{"tokens": ["SPLITPARTONEAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA"], "g-recaptcha-response": "test"}`,
+			http.StatusUnprocessableEntity, []byte(`"tokens":["Not enough parts of the secret were supplied, no views have been used"]`)},
+		{"Part used up",
+			`{"tokens": ["SPLITPARTONEAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA", "SPLITPARTUSEDAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA"], "g-recaptcha-response": "test"}`,
+			http.StatusUnprocessableEntity, []byte(`"error":"The secret could not be combined"`)},
+		{"Not a split secret",
+			`{"tokens": ["SPLITPARTONEAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA", "MUPPH5PDKV7AGCUAAEERL5ARIXICVVGYLRIV365X5XSV3EKISAXQ"], "g-recaptcha-response": "test"}`,
+			http.StatusUnprocessableEntity, []byte(`aren't part of a split secret`)},
+		{"Invalid token",
+			`{"tokens": ["SPLITPARTONE"], "g-recaptcha-response": "test"}`,
+			http.StatusUnprocessableEntity, []byte(`"tokens":["One or more of the links are invalid"]`)},
+		{"No tokens",
+			`{"g-recaptcha-response": "test"}`,
+			http.StatusUnprocessableEntity, []byte(`"tokens":["This field must not be blank"]`)},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			statusCode, _, responseBody := testServ.postJSON(t, "/api/combine", "application/json", testCase.body)
+
+			if statusCode != testCase.expectedStatusCode {
+				t.Errorf("Expected status %d, received status %d", testCase.expectedStatusCode, statusCode)
+			}
+
+			if !bytes.Contains(responseBody, testCase.expectedResponse) {
+				t.Errorf("Expected %s, received %s", testCase.expectedResponse, responseBody)
+			}
+		})
+	}
+}
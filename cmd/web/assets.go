@@ -0,0 +1,154 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/matthewlmitchell/tempshare/pkg/i18n"
+	"github.com/matthewlmitchell/tempshare/pkg/overlayfs"
+	"github.com/matthewlmitchell/tempshare/ui"
+)
+
+// uiFiles returns the templates and static files compiled into the binary, with those in
+// dir (e.g. a custom theme's static/css/main.css) replacing them if dir is set. In development
+// dir defaults to ./ui if it exists, so that edits in a checkout are picked up without rebuilding.
+func uiFiles(dir string, development bool) fs.FS {
+
+	if dir == "" && development {
+		if info, err := os.Stat("ui"); err == nil && info.IsDir() {
+			dir = "ui"
+		}
+	}
+
+	if dir == "" {
+		return ui.Files
+	}
+
+	return overlayfs.New(os.DirFS(dir), ui.Files)
+}
+
+// uiCache holds what is loaded from the files of the UI
+type uiCache struct {
+	assets    *assets
+	locales   *i18n.Bundle
+	templates map[string]map[string]*template.Template // By locale, then by file name
+}
+
+// loadUI hashes the static files of fsys, loads its message catalogs, and parses its
+// templates once for each locale
+func loadUI(fsys fs.FS) (*uiCache, error) {
+
+	staticFS, err := fs.Sub(fsys, "static")
+	if err != nil {
+		return nil, err
+	}
+
+	static, err := newAssets(staticFS)
+	if err != nil {
+		return nil, err
+	}
+
+	localesFS, err := fs.Sub(fsys, "locales")
+	if err != nil {
+		return nil, err
+	}
+
+	locales, err := i18n.Load(localesFS)
+	if err != nil {
+		return nil, err
+	}
+
+	htmlFS, err := fs.Sub(fsys, "html")
+	if err != nil {
+		return nil, err
+	}
+
+	templates := map[string]map[string]*template.Template{}
+	for _, catalog := range locales.Catalogs() {
+		templates[catalog.Locale()], err = initTemplateCache(htmlFS, static, catalog)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &uiCache{assets: static, locales: locales, templates: templates}, nil
+}
+
+// assets serves the static files, and gives each of them a URL which changes with its
+// contents, so that browsers can cache them for good
+type assets struct {
+	fsys   fs.FS
+	hashes map[string]string
+}
+
+// newAssets hashes every file in fsys
+func newAssets(fsys fs.FS) (*assets, error) {
+
+	hashes := map[string]string{}
+
+	err := fs.WalkDir(fsys, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+
+		sum := sha256.Sum256(data)
+		hashes[name] = hex.EncodeToString(sum[:6])
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &assets{fsys: fsys, hashes: hashes}, nil
+}
+
+// url returns the URL of the static file name, e.g. "/static/css/main.css?v=3f2a9c0b71de".
+// It is available to templates as {{asset "css/main.css"}}.
+func (a *assets) url(name string) string {
+
+	name = strings.TrimPrefix(name, "/")
+
+	if hash, ok := a.hashes[name]; ok {
+		return "/static/" + name + "?v=" + hash
+	}
+
+	return "/static/" + name
+}
+
+// handler serves the static files, with the "/static" prefix stripped. Files requested with
+// their current hash can be cached forever, others must be revalidated with their ETag.
+// Directories aren't listed.
+func (a *assets) handler() http.Handler {
+
+	fileServer := http.FileServer(http.FS(a.fsys))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		name := strings.TrimPrefix(r.URL.Path, "/")
+
+		hash, ok := a.hashes[name]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		if r.URL.Query().Get("v") == hash {
+			w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		} else {
+			w.Header().Set("Cache-Control", "no-cache")
+		}
+		w.Header().Set("ETag", `"`+hash+`"`)
+
+		fileServer.ServeHTTP(w, r)
+	})
+}
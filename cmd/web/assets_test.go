@@ -0,0 +1,74 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAssets(t *testing.T) {
+
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "static", "css"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "static", "css", "main.css"), []byte("body { color: red; }"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	loadedUI, err := loadUI(uiFiles(dir, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	static := loadedUI.assets
+
+	if len(loadedUI.templates) == 0 {
+		t.Fatal("Expected the templates compiled in to be parsed")
+	}
+
+	cssURL := static.url("css/main.css")
+	if !strings.HasPrefix(cssURL, "/static/css/main.css?v=") {
+		t.Fatalf("Expected a hashed URL, received %s", cssURL)
+	}
+
+	tests := []struct {
+		name             string
+		urlPath          string
+		wantCode         int
+		wantCacheControl string
+		wantBody         string
+	}{
+		{"Overridden file", cssURL, http.StatusOK, "public, max-age=31536000, immutable", "color: red"},
+		{"Compiled in file", static.url("js/main.js"), http.StatusOK, "public, max-age=31536000, immutable", "enableSubmit"},
+		{"Outdated hash", "/static/css/main.css?v=000000000000", http.StatusOK, "no-cache", "color: red"},
+		{"Directory", "/static/css/", http.StatusNotFound, "", ""},
+		{"Missing file", "/static/css/missing.css", http.StatusNotFound, "", ""},
+	}
+
+	handler := http.StripPrefix("/static", static.handler())
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			responseRecorder := httptest.NewRecorder()
+			handler.ServeHTTP(responseRecorder, httptest.NewRequest(http.MethodGet, tt.urlPath, nil))
+
+			if responseRecorder.Code != tt.wantCode {
+				t.Fatalf("Expected status %d, received %d", tt.wantCode, responseRecorder.Code)
+			}
+
+			if cacheControl := responseRecorder.Header().Get("Cache-Control"); cacheControl != tt.wantCacheControl {
+				t.Errorf("Expected Cache-Control %q, received %q", tt.wantCacheControl, cacheControl)
+			}
+
+			body, _ := io.ReadAll(responseRecorder.Body)
+			if !strings.Contains(string(body), tt.wantBody) {
+				t.Errorf("Expected the body to contain %q, received %q", tt.wantBody, body)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"crypto/tls"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// How often the certificate and key files are checked for changes
+const certPollInterval = 30 * time.Second
+
+// tlsVersions maps the values of the tls-min-version setting to TLS versions
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// newTLSConfig returns the TLS config of the server. TLS 1.3 suites aren't configurable and are
+// all modern, so the suites only restrict TLS 1.2 connections, to forward secret AEAD ones.
+func newTLSConfig(minVersion string, certificates *certReloader) *tls.Config {
+	return &tls.Config{
+		MinVersion:       tlsVersions[minVersion],
+		CurvePreferences: []tls.CurveID{tls.X25519, tls.CurveP256, tls.CurveP384},
+		CipherSuites: []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+		},
+		GetCertificate: certificates.getCertificate,
+	}
+}
+
+// certReloader serves the certificate loaded from a certificate and key file, which can be
+// replaced while the server is running. Connections which have already been established
+// keep using the certificate they were set up with.
+type certReloader struct {
+	certPath string
+	keyPath  string
+
+	mu          sync.RWMutex
+	certificate *tls.Certificate
+	modTimes    [2]time.Time
+}
+
+func newCertReloader(certPath string, keyPath string) (*certReloader, error) {
+
+	reloader := &certReloader{certPath: certPath, keyPath: keyPath}
+	if err := reloader.reload(); err != nil {
+		return nil, err
+	}
+
+	return reloader, nil
+}
+
+// reload loads the certificate and key files. If they can't be loaded, e.g. because only
+// one of them has been replaced so far, the current certificate is kept.
+func (reloader *certReloader) reload() error {
+
+	modTimes, err := reloader.statFiles()
+	if err != nil {
+		return err
+	}
+
+	certificate, err := tls.LoadX509KeyPair(reloader.certPath, reloader.keyPath)
+	if err != nil {
+		return err
+	}
+
+	reloader.mu.Lock()
+	defer reloader.mu.Unlock()
+
+	reloader.certificate = &certificate
+	reloader.modTimes = modTimes
+
+	return nil
+}
+
+// changed reports whether the certificate or key file has been modified since it was loaded
+func (reloader *certReloader) changed() (bool, error) {
+
+	modTimes, err := reloader.statFiles()
+	if err != nil {
+		return false, err
+	}
+
+	reloader.mu.RLock()
+	defer reloader.mu.RUnlock()
+
+	return modTimes != reloader.modTimes, nil
+}
+
+func (reloader *certReloader) statFiles() ([2]time.Time, error) {
+
+	var modTimes [2]time.Time
+
+	for i, path := range []string{reloader.certPath, reloader.keyPath} {
+		info, err := os.Stat(path)
+		if err != nil {
+			return modTimes, err
+		}
+		modTimes[i] = info.ModTime()
+	}
+
+	return modTimes, nil
+}
+
+func (reloader *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	reloader.mu.RLock()
+	defer reloader.mu.RUnlock()
+
+	return reloader.certificate, nil
+}
+
+// watchCertificates reloads the certificate on SIGHUP, and when its files change
+func (app *application) watchCertificates(reloader *certReloader) {
+
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+
+	app.runInBackground(func() {
+		ticker := time.NewTicker(certPollInterval)
+		defer ticker.Stop()
+
+		for {
+			reason := "signal"
+
+			select {
+			case <-hangup:
+			case <-ticker.C:
+				changed, err := reloader.changed()
+				if err != nil {
+					app.logger.Error("failed to check certificate files", "error", err)
+					continue
+				}
+				if !changed {
+					continue
+				}
+				reason = "file change"
+			}
+
+			if err := reloader.reload(); err != nil {
+				app.logger.Error("failed to reload certificate, keeping the current one", "reason", reason, "error", err)
+				continue
+			}
+
+			app.logger.Info("reloaded certificate", "reason", reason, "cert", reloader.certPath)
+		}
+	})
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTestCertificate writes a self-signed certificate for commonName and its key to dir
+func writeTestCertificate(t *testing.T, dir string, commonName string) (string, string) {
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certPath, keyPath := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+
+	err = os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return certPath, keyPath
+}
+
+func TestCertReloader(t *testing.T) {
+
+	dir := t.TempDir()
+	certPath, keyPath := writeTestCertificate(t, dir, "first")
+
+	reloader, err := newCertReloader(certPath, keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, _ := reloader.getCertificate(nil)
+
+	if changed, err := reloader.changed(); err != nil || changed {
+		t.Fatalf("Expected no change, received %v (%v)", changed, err)
+	}
+
+	writeTestCertificate(t, dir, "second")
+
+	// Make sure the modification times differ on file systems with a coarse resolution
+	later := time.Now().Add(time.Minute)
+	for _, path := range []string{certPath, keyPath} {
+		if err := os.Chtimes(path, later, later); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if changed, err := reloader.changed(); err != nil || !changed {
+		t.Fatalf("Expected a change, received %v (%v)", changed, err)
+	}
+
+	if err := reloader.reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	second, _ := reloader.getCertificate(nil)
+	if bytes.Equal(first.Certificate[0], second.Certificate[0]) {
+		t.Error("Expected the certificate to be replaced")
+	}
+
+	// A broken key pair must not replace the current certificate
+	if err := os.WriteFile(keyPath, []byte("not a key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := reloader.reload(); err == nil {
+		t.Error("Expected an error reloading a broken key pair")
+	}
+
+	if current, _ := reloader.getCertificate(nil); current != second {
+		t.Error("Expected the current certificate to be kept")
+	}
+}
+
+func TestTLSConfig(t *testing.T) {
+
+	certPath, keyPath := writeTestCertificate(t, t.TempDir(), "localhost")
+
+	reloader, err := newCertReloader(certPath, keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name             string
+		minVersion       string
+		clientMaxVersion uint16
+		clientSuites     []uint16
+		expectError      bool
+	}{
+		{"TLS 1.3", "1.2", tls.VersionTLS13, nil, false},
+		{"TLS 1.2 with a forward secret suite", "1.2", tls.VersionTLS12, []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256}, false},
+		{"TLS 1.2 with a CBC suite", "1.2", tls.VersionTLS12, []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA}, true},
+		{"TLS 1.2 when 1.3 is required", "1.3", tls.VersionTLS12, nil, true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+
+			server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			server.TLS = newTLSConfig(testCase.minVersion, reloader)
+			server.StartTLS()
+			defer server.Close()
+
+			// The test server also has a certificate of its own, which is served to clients without SNI
+			client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+				ServerName:         "localhost",
+				InsecureSkipVerify: true,
+				MaxVersion:         testCase.clientMaxVersion,
+				CipherSuites:       testCase.clientSuites,
+			}}}
+
+			response, err := client.Get(server.URL)
+			if err == nil {
+				response.Body.Close()
+			}
+
+			if (err != nil) != testCase.expectError {
+				t.Errorf("Expected error: %v, received %v", testCase.expectError, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,412 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"gopkg.in/yaml.v3"
+)
+
+// Where the value of a setting came from, in increasing order of precedence
+const (
+	sourceDefault = "default"
+	sourceFile    = "file"
+	sourceEnv     = "env"
+	sourceFlag    = "flag"
+)
+
+const maskedValue = "********"
+
+// legacyEnv maps settings to the environment variables they were read from before
+// TEMPSHARE_<SETTING> was supported, which are still accepted
+var legacyEnv = map[string]string{
+	"db-dsn":             "TEMPSHARE_DSN",
+	"recaptcha-site-key": "TEMPSHARE_reCAPTCHA_PUBLIC",
+	"recaptcha-secret":   "TEMPSHARE_reCAPTCHA_SECRET",
+}
+
+// secretSettings are masked when the config is printed
+var secretSettings = map[string]bool{
+	"secret":           true,
+	"csrf-key":         true,
+	"recaptcha-secret": true,
+	"smtp-password":    true,
+}
+
+// flagSet defines a flag for every setting of cfg. Each of them may also be set in the
+// config file under the same name, or with the environment variable TEMPSHARE_<NAME>,
+// e.g. db-max-idle-conns can be set with TEMPSHARE_DB_MAX_IDLE_CONNS.
+func (cfg *config) flagSet() *flag.FlagSet {
+
+	flags := flag.NewFlagSet("tempshare", flag.ContinueOnError)
+
+	flags.StringVar(&cfg.file, "config", "", "YAML file to read settings from, overridden by environment variables and flags")
+
+	flags.IntVar(&cfg.port, "port", 4000, "HTTP network address")
+	flags.StringVar(&cfg.adminAddr, "admin-addr", "127.0.0.1:4001", "Address of the admin listener serving /metrics, and the /admin dashboard unless plain-http is set, disabled if empty")
+	flags.StringVar(&cfg.env, "env", "development", "Environment (development|staging|production)")
+	flags.StringVar(&cfg.baseURL, "base-url", "https://placeholder.com", "Scheme and host that share links are formatted with")
+
+	flags.BoolVar(&cfg.plainHTTP, "plain-http", false, "Serve plain HTTP instead of HTTPS, behind a proxy which terminates TLS (the admin dashboard is then not served)")
+	flags.StringVar(&cfg.unixSocket, "unix-socket", "", "Unix socket to listen on instead of the port, whose peers are trusted as proxies")
+	flags.StringVar(&cfg.trustedProxies, "trusted-proxies", "", "Comma separated CIDRs of the proxies whose X-Forwarded-For and X-Forwarded-Proto headers are trusted")
+
+	flags.StringVar(&cfg.redirectAddr, "redirect-addr", "", "Address of a plain HTTP listener redirecting to HTTPS, e.g. :80, disabled if empty")
+	flags.BoolVar(&cfg.http3, "http3", false, "Also serve HTTP/3 over QUIC on the UDP port matching port, advertised with Alt-Svc")
+
+	flags.StringVar(&cfg.TLS.certFile, "tls-cert", "./tls/cert.pem", "TLS certificate file, reloaded on SIGHUP or when it changes")
+	flags.StringVar(&cfg.TLS.keyFile, "tls-key", "./tls/key.pem", "TLS private key file, reloaded along with the certificate")
+	flags.StringVar(&cfg.TLS.minVersion, "tls-min-version", "1.2", "Minimum TLS version accepted (1.2|1.3)")
+
+	flags.StringVar(&cfg.keyring, "keyring", "", "Keyring file that the session and CSRF keys are derived from, see \"keyring rotate\"")
+	flags.StringVar(&cfg.sessionSecret, "secret", "", "Cookie store session secret of at least 32 characters, if keyring isn't set (random if empty)")
+	flags.StringVar(&cfg.csrfKey, "csrf-key", "", "Secret key of at least 32 characters used to generate CSRF tokens, if keyring isn't set (random if empty)")
+
+	flags.StringVar(&cfg.reCAPTCHA.siteKey, "recaptcha-site-key", "", "reCAPTCHA site key embedded in pages, required in production")
+	flags.StringVar(&cfg.reCAPTCHA.secret, "recaptcha-secret", "", "reCAPTCHA secret key used to verify responses, required in production")
+
+	flags.StringVar(&cfg.DB.dsn, "db-dsn", "", "Specifies the MySQL database data source name (dsn)")
+	flags.StringVar(&cfg.DB.maxIdleTime, "db-max-idle-time", "5m", "MySQL maximum time allowed for an idle connection")
+	flags.IntVar(&cfg.DB.maxIdleConnections, "db-max-idle-conns", 25, "MySQL maximum number of idle connections")
+	flags.IntVar(&cfg.DB.maxOpenConnections, "db-max-open-conns", 25, "MySQL maximum number of open connections")
+
+	flags.StringVar(&cfg.SMTP.host, "smtp-host", "", "SMTP server used for emailing share links and view notifications, disabled if empty")
+	flags.IntVar(&cfg.SMTP.port, "smtp-port", 587, "SMTP server port")
+	flags.StringVar(&cfg.SMTP.username, "smtp-username", "", "SMTP username")
+	flags.StringVar(&cfg.SMTP.password, "smtp-password", "", "SMTP password")
+	flags.StringVar(&cfg.SMTP.sender, "smtp-sender", "TempShare <no-reply@tempshare.local>", "SMTP sender address")
+	flags.BoolVar(&cfg.SMTP.insecure, "smtp-insecure", false, "Allow sending mail to SMTP servers which don't support STARTTLS")
+
+	flags.StringVar(&cfg.notifyWebhook, "notify-webhook", "", "URL which view notifications are sent to, disabled if empty")
+	flags.StringVar(&cfg.webhooksFile, "webhooks", "", "JSON file listing webhook destinations for share lifecycle events")
+
+	flags.IntVar(&cfg.rateLimit, "rate-limit", 0, "Requests a minute allowed from each client IP address to the pages, unlimited if 0")
+
+	flags.BoolVar(&cfg.captchaCheck, "readyz-captcha", true, "Require the reCAPTCHA API to be reachable for /readyz to succeed")
+
+	flags.StringVar(&cfg.uiDir, "ui-dir", "", "Directory of templates and static files (html/, mail/, static/) replacing those compiled in, e.g. for a custom theme")
+
+	flags.StringVar(&cfg.otlpEndpoint, "otlp-endpoint", "", "URL of an OTLP/HTTP collector to export traces to, e.g. http://localhost:4318, disabled if empty")
+
+	return flags
+}
+
+// loadConfig builds the config from the command line arguments args, the environment and the
+// config file, in that order of precedence, falling back to the defaults of the flags.
+// It returns the flag set the config was parsed with and where each setting came from.
+func loadConfig(args []string) (*config, *flag.FlagSet, map[string]string, error) {
+
+	cfg := &config{}
+	flags := cfg.flagSet()
+
+	if err := flags.Parse(args); err != nil {
+		return nil, nil, nil, err
+	}
+
+	sources := map[string]string{}
+	flags.Visit(func(f *flag.Flag) {
+		sources[f.Name] = sourceFlag
+	})
+
+	var err error
+	flags.VisitAll(func(f *flag.Flag) {
+		if err != nil || sources[f.Name] != "" {
+			return
+		}
+
+		value, ok := lookupEnv(f.Name)
+		if !ok {
+			return
+		}
+
+		if setErr := flags.Set(f.Name, value); setErr != nil {
+			err = fmt.Errorf("environment variable for %s: %w", f.Name, setErr)
+			return
+		}
+		sources[f.Name] = sourceEnv
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if cfg.file != "" {
+		settings, err := readConfigFile(cfg.file)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		for name, value := range settings {
+			if name == "config" || flags.Lookup(name) == nil {
+				return nil, nil, nil, fmt.Errorf("%s: unknown setting %q", cfg.file, name)
+			}
+			if sources[name] != "" {
+				continue
+			}
+
+			if err := flags.Set(name, value); err != nil {
+				return nil, nil, nil, fmt.Errorf("%s: %s: %w", cfg.file, name, err)
+			}
+			sources[name] = sourceFile
+		}
+	}
+
+	flags.VisitAll(func(f *flag.Flag) {
+		if sources[f.Name] == "" {
+			sources[f.Name] = sourceDefault
+		}
+	})
+
+	return cfg, flags, sources, nil
+}
+
+// lookupEnv returns the value of the environment variable of the setting name,
+// e.g. TEMPSHARE_DB_DSN for db-dsn, or of its legacy variable if that isn't set
+func lookupEnv(name string) (string, bool) {
+
+	if value, ok := os.LookupEnv("TEMPSHARE_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))); ok {
+		return value, true
+	}
+
+	if legacy, ok := legacyEnv[name]; ok {
+		return os.LookupEnv(legacy)
+	}
+
+	return "", false
+}
+
+// readConfigFile reads the YAML file at path into a map of setting names to values.
+// Nested keys are joined with a hyphen, so db-dsn may also be written as dsn under db.
+func readConfigFile(path string) (map[string]string, error) {
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	document := map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	settings := map[string]string{}
+	if err := flattenSettings(settings, "", document); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return settings, nil
+}
+
+func flattenSettings(settings map[string]string, prefix string, document map[string]interface{}) error {
+
+	for key, value := range document {
+		name := key
+		if prefix != "" {
+			name = prefix + "-" + key
+		}
+
+		switch value := value.(type) {
+		case map[string]interface{}:
+			if err := flattenSettings(settings, name, value); err != nil {
+				return err
+			}
+		case []interface{}:
+			return fmt.Errorf("%s: lists are not supported", name)
+		case nil:
+			settings[name] = ""
+		default:
+			settings[name] = fmt.Sprint(value)
+		}
+	}
+
+	return nil
+}
+
+// validate checks the config for settings which would stop the server from working
+// correctly, returning an error listing all of them
+func (cfg *config) validate() error {
+
+	var problems []error
+	problem := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Errorf(format, args...))
+	}
+
+	production := cfg.env == "production"
+
+	switch cfg.env {
+	case "development", "staging", "production":
+	default:
+		problem("env must be development, staging or production, not %q", cfg.env)
+	}
+
+	if cfg.port < 1 || cfg.port > 65535 {
+		problem("port must be between 1 and 65535")
+	}
+
+	if baseURL, err := url.Parse(cfg.baseURL); err != nil || baseURL.Host == "" || (baseURL.Scheme != "https" && baseURL.Scheme != "http") {
+		problem("base-url must be an absolute http(s) URL")
+	} else if production && baseURL.Scheme != "https" {
+		problem("base-url must use https in production")
+	}
+
+	if _, ok := tlsVersions[cfg.TLS.minVersion]; !ok {
+		problem("tls-min-version must be 1.2 or 1.3, not %q", cfg.TLS.minVersion)
+	}
+
+	if !cfg.plainHTTP && (cfg.TLS.certFile == "" || cfg.TLS.keyFile == "") {
+		problem("tls-cert and tls-key are required, unless plain-http is set")
+	}
+
+	if cfg.plainHTTP && (cfg.redirectAddr != "" || cfg.http3) {
+		problem("redirect-addr and http3 can't be used with plain-http")
+	}
+
+	proxies, err := parseTrustedProxies(cfg.trustedProxies)
+	if err != nil {
+		problem("trusted-proxies is invalid: %w", err)
+	}
+
+	// Without a proxy telling it the original scheme, the server would send insecure cookies
+	if production && cfg.plainHTTP && len(proxies) == 0 && cfg.unixSocket == "" {
+		problem("plain-http requires trusted-proxies or unix-socket in production")
+	}
+
+	// Random keys would stop replicas from sharing cookies, and be replaced on every restart
+	if cfg.keyring != "" {
+		if cfg.sessionSecret != "" || cfg.csrfKey != "" {
+			problem("secret and csrf-key can't be set along with keyring")
+		}
+	} else if production && (cfg.sessionSecret == "" || cfg.csrfKey == "") {
+		problem("keyring, or secret and csrf-key, are required in production")
+	}
+
+	for name, value := range map[string]string{"secret": cfg.sessionSecret, "csrf-key": cfg.csrfKey} {
+		if value != "" && len(value) < 32 {
+			problem("%s must be at least 32 characters", name)
+		}
+	}
+
+	if production && (cfg.reCAPTCHA.siteKey == "" || cfg.reCAPTCHA.secret == "") {
+		problem("recaptcha-site-key and recaptcha-secret are required in production")
+	}
+
+	if cfg.DB.dsn == "" {
+		problem("db-dsn is required")
+	} else if _, err := mysql.ParseDSN(cfg.DB.dsn); err != nil {
+		problem("db-dsn is invalid: %w", err)
+	}
+
+	if _, err := time.ParseDuration(cfg.DB.maxIdleTime); err != nil {
+		problem("db-max-idle-time must be a duration, e.g. 5m")
+	}
+
+	if cfg.DB.maxOpenConnections < 1 || cfg.DB.maxIdleConnections < 0 {
+		problem("db-max-open-conns must be positive and db-max-idle-conns must not be negative")
+	}
+
+	if cfg.SMTP.host != "" {
+		if cfg.SMTP.port < 1 || cfg.SMTP.port > 65535 {
+			problem("smtp-port must be between 1 and 65535")
+		}
+		if cfg.SMTP.sender == "" {
+			problem("smtp-sender is required when smtp-host is set")
+		}
+	}
+
+	for name, value := range map[string]string{"notify-webhook": cfg.notifyWebhook, "otlp-endpoint": cfg.otlpEndpoint} {
+		if value == "" {
+			continue
+		}
+		if parsed, err := url.Parse(value); err != nil || parsed.Host == "" {
+			problem("%s must be an absolute URL", name)
+		}
+	}
+
+	if cfg.rateLimit < 0 {
+		problem("rate-limit must not be negative")
+	}
+
+	if cfg.uiDir != "" {
+		if info, err := os.Stat(cfg.uiDir); err != nil || !info.IsDir() {
+			problem("ui-dir must be an existing directory")
+		}
+	}
+
+	return errors.Join(problems...)
+}
+
+// printConfig writes every setting of flags with its value and where it came from,
+// with secrets (and the password in the database DSN) masked
+func printConfig(w io.Writer, flags *flag.FlagSet, sources map[string]string) {
+
+	var names []string
+	flags.VisitAll(func(f *flag.Flag) {
+		names = append(names, f.Name)
+	})
+	sort.Strings(names)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	for _, name := range names {
+		value := flags.Lookup(name).Value.String()
+
+		switch {
+		case secretSettings[name] && value != "":
+			value = maskedValue
+		case name == "db-dsn":
+			value = maskDSN(value)
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t(%s)\n", name, value, sources[name])
+	}
+
+	tw.Flush()
+}
+
+// maskDSN masks the password in a MySQL DSN, or all of it if it can't be parsed
+func maskDSN(dsn string) string {
+
+	if dsn == "" {
+		return ""
+	}
+
+	parsed, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return maskedValue
+	}
+
+	if parsed.Passwd != "" {
+		parsed.Passwd = maskedValue
+	}
+
+	return parsed.FormatDSN()
+}
+
+// checkConfig implements "tempshare config check": it loads the config from args as the
+// server would, prints it, and reports whether it is valid with the exit status
+func checkConfig(args []string, stdout io.Writer, stderr io.Writer) int {
+
+	cfg, flags, sources, err := loadConfig(args)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+
+	printConfig(stdout, flags, sources)
+
+	if err := cfg.validate(); err != nil {
+		fmt.Fprintf(stderr, "invalid config:\n%s\n", err)
+		return 1
+	}
+
+	fmt.Fprintln(stdout, "config is valid")
+	return 0
+}
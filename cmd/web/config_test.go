@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testDSN = "tempshare:hunter2@tcp(localhost:3306)/tempshare?parseTime=true"
+
+func TestLoadConfig(t *testing.T) {
+
+	configFile := filepath.Join(t.TempDir(), "tempshare.yaml")
+	err := os.WriteFile(configFile, []byte("port: 5000\nenv: staging\nsmtp:\n  host: mail.example.com\n  port: 2525\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("TEMPSHARE_ENV", "production")
+	t.Setenv("TEMPSHARE_DSN", testDSN)
+
+	cfg, _, sources, err := loadConfig([]string{"-config", configFile, "-port", "6000"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name           string
+		setting        string
+		value          interface{}
+		expectedValue  interface{}
+		expectedSource string
+	}{
+		{"Flag overrides file", "port", cfg.port, 6000, sourceFlag},
+		{"Env overrides file", "env", cfg.env, "production", sourceEnv},
+		{"Legacy env", "db-dsn", cfg.DB.dsn, testDSN, sourceEnv},
+		{"Nested file setting", "smtp-host", cfg.SMTP.host, "mail.example.com", sourceFile},
+		{"Nested file setting of another type", "smtp-port", cfg.SMTP.port, 2525, sourceFile},
+		{"Default", "db-max-idle-time", cfg.DB.maxIdleTime, "5m", sourceDefault},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if testCase.value != testCase.expectedValue {
+				t.Errorf("Expected %v, received %v", testCase.expectedValue, testCase.value)
+			}
+
+			if sources[testCase.setting] != testCase.expectedSource {
+				t.Errorf("Expected source %s, received %s", testCase.expectedSource, sources[testCase.setting])
+			}
+		})
+	}
+}
+
+func TestLoadConfigUnknownSetting(t *testing.T) {
+
+	configFile := filepath.Join(t.TempDir(), "tempshare.yaml")
+	if err := os.WriteFile(configFile, []byte("prot: 5000\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, _, err := loadConfig([]string{"-config", configFile})
+	if err == nil || !strings.Contains(err.Error(), `unknown setting "prot"`) {
+		t.Errorf("Expected an unknown setting error, received %v", err)
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+
+	testCases := []struct {
+		name          string
+		args          []string
+		expectedError string
+	}{
+		{"Valid development config", []string{"-db-dsn", testDSN}, ""},
+		{"Valid production config", []string{"-db-dsn", testDSN, "-env", "production", "-keyring", "keyring.json",
+			"-recaptcha-site-key", "site", "-recaptcha-secret", "secret"}, ""},
+		{"Missing DSN", []string{}, "db-dsn is required"},
+		{"Missing CSRF key in production", []string{"-db-dsn", testDSN, "-env", "production",
+			"-secret", strings.Repeat("s", 32), "-recaptcha-site-key", "site", "-recaptcha-secret", "secret"}, "keyring, or secret and csrf-key, are required in production"},
+		{"Keys along with a keyring", []string{"-db-dsn", testDSN, "-keyring", "keyring.json", "-csrf-key", strings.Repeat("k", 32)},
+			"secret and csrf-key can't be set along with keyring"},
+		{"Short CSRF key", []string{"-db-dsn", testDSN, "-csrf-key", "short"}, "csrf-key must be at least 32 characters"},
+		{"Unknown environment", []string{"-db-dsn", testDSN, "-env", "prod"}, "env must be development, staging or production"},
+		{"Invalid duration", []string{"-db-dsn", testDSN, "-db-max-idle-time", "5"}, "db-max-idle-time must be a duration"},
+		{"Relative base URL", []string{"-db-dsn", testDSN, "-base-url", "tempshare.example.com"}, "base-url must be an absolute http(s) URL"},
+		{"Negative rate limit", []string{"-db-dsn", testDSN, "-rate-limit", "-1"}, "rate-limit must not be negative"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			cfg, _, _, err := loadConfig(testCase.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = cfg.validate()
+
+			switch {
+			case testCase.expectedError == "" && err != nil:
+				t.Errorf("Expected no error, received %v", err)
+			case testCase.expectedError != "" && (err == nil || !strings.Contains(err.Error(), testCase.expectedError)):
+				t.Errorf("Expected error %q, received %v", testCase.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestCheckConfig(t *testing.T) {
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+
+	status := checkConfig([]string{"-db-dsn", testDSN, "-smtp-password", "hunter3", "-csrf-key", strings.Repeat("k", 32)}, stdout, stderr)
+	if status != 0 {
+		t.Fatalf("Expected exit status 0, received %d: %s", status, stderr)
+	}
+
+	for _, secret := range []string{"hunter2", "hunter3", strings.Repeat("k", 32)} {
+		if strings.Contains(stdout.String(), secret) {
+			t.Errorf("Expected %s to be masked, received %s", secret, stdout)
+		}
+	}
+
+	if !strings.Contains(stdout.String(), "tempshare:"+maskedValue+"@tcp(localhost:3306)") {
+		t.Errorf("Expected the DSN with its password masked, received %s", stdout)
+	}
+}
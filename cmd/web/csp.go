@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+
+	"github.com/matthewlmitchell/tempshare/pkg/logging"
+)
+
+// Reports are small, so larger bodies are truncated rather than read into memory
+const maxCSPReportSize = 64 << 10
+
+// cspViolation holds the fields of a CSP violation report which are logged. Browsers send
+// them either with report-uri, as application/csp-report, or with report-to, as
+// application/reports+json, which names them differently.
+type cspViolation struct {
+	DocumentURI        string `json:"document-uri"`
+	BlockedURI         string `json:"blocked-uri"`
+	ViolatedDirective  string `json:"violated-directive"`
+	DocumentURL        string `json:"documentURL"`
+	BlockedURL         string `json:"blockedURL"`
+	EffectiveDirective string `json:"effectiveDirective"`
+}
+
+// cspReport logs the CSP violations reported by browsers. Reports can be sent by anyone, so
+// they are only logged, with the URIs stripped of query strings and tokens.
+func (app *application) cspReport(w http.ResponseWriter, r *http.Request) {
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxCSPReportSize))
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	var violations []cspViolation
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/csp-report":
+		var report struct {
+			Body cspViolation `json:"csp-report"`
+		}
+		if err := json.Unmarshal(body, &report); err != nil {
+			app.clientError(w, http.StatusBadRequest)
+			return
+		}
+		violations = append(violations, report.Body)
+
+	case "application/reports+json":
+		var reports []struct {
+			Type string       `json:"type"`
+			Body cspViolation `json:"body"`
+		}
+		if err := json.Unmarshal(body, &reports); err != nil {
+			app.clientError(w, http.StatusBadRequest)
+			return
+		}
+		for _, report := range reports {
+			if report.Type == "csp-violation" {
+				violations = append(violations, report.Body)
+			}
+		}
+
+	default:
+		app.clientError(w, http.StatusUnsupportedMediaType)
+		return
+	}
+
+	for _, violation := range violations {
+		app.loggerFrom(r.Context()).Warn("content security policy violation",
+			"document", redactURI(firstNonEmpty(violation.DocumentURI, violation.DocumentURL)),
+			"blocked", redactURI(firstNonEmpty(violation.BlockedURI, violation.BlockedURL)),
+			"directive", firstNonEmpty(violation.ViolatedDirective, violation.EffectiveDirective),
+		)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// redactURI removes the query string and fragment of a reported URI, which may hold tokens.
+// Values which aren't URLs, such as "inline", are returned as is.
+func redactURI(uri string) string {
+
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		return logging.RedactString(uri)
+	}
+
+	parsed.RawQuery = ""
+	parsed.Fragment = ""
+	parsed.RawFragment = ""
+	parsed.User = nil
+
+	return logging.RedactString(parsed.String())
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+
+	return ""
+}
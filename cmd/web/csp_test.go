@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/matthewlmitchell/tempshare/pkg/logging"
+)
+
+func TestCSPReport(t *testing.T) {
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantCode    int
+		wantLog     string
+	}{
+		{
+			"report-uri",
+			"application/csp-report",
+			`{"csp-report":{"document-uri":"https://example.com/view?token=MUPPH5PDKV7AGCUAAEERL5ARIXICVVGYLRIV365X5XSV3EKISAXQ","blocked-uri":"inline","violated-directive":"script-src-elem"}}`,
+			http.StatusNoContent,
+			"script-src-elem",
+		},
+		{
+			"report-to",
+			"application/reports+json",
+			`[{"type":"csp-violation","body":{"documentURL":"https://example.com/view?token=MUPPH5PDKV7AGCUAAEERL5ARIXICVVGYLRIV365X5XSV3EKISAXQ","blockedURL":"https://evil.example/x.js","effectiveDirective":"script-src"}}]`,
+			http.StatusNoContent,
+			"https://evil.example/x.js",
+		},
+		{"Invalid JSON", "application/csp-report", `{`, http.StatusBadRequest, ""},
+		{"Unsupported type", "text/plain", `{}`, http.StatusUnsupportedMediaType, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+
+			logBuffer := &bytes.Buffer{}
+			app.logger = logging.New(logBuffer, slog.LevelInfo)
+
+			responseRecorder := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodPost, "/csp-report", strings.NewReader(tt.body))
+			request.Header.Set("Content-Type", tt.contentType)
+
+			app.cspReport(responseRecorder, request)
+
+			if responseRecorder.Code != tt.wantCode {
+				t.Errorf("Expected status %d, received %d", tt.wantCode, responseRecorder.Code)
+			}
+
+			if !strings.Contains(logBuffer.String(), tt.wantLog) {
+				t.Errorf("Expected the log to contain %q, received %s", tt.wantLog, logBuffer.String())
+			}
+
+			if strings.Contains(logBuffer.String(), "MUPPH5PDKV7AGCUAAEERL5ARIXICVVGYLRIV365X5XSV3EKISAXQ") {
+				t.Errorf("Expected the token to be removed, received %s", logBuffer.String())
+			}
+		})
+	}
+}
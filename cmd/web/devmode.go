@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"regexp"
+	"strconv"
+	"sync"
+)
+
+// devUI re-parses the templates and re-hashes the static files whenever a file of the UI has
+// changed since the last request, so that edits show up without restarting the server.
+// It stats every file on each request, so it is only used in development.
+type devUI struct {
+	fsys fs.FS
+
+	mu          sync.Mutex
+	fingerprint [sha256.Size]byte
+	cache       *uiCache
+}
+
+// load returns what is loaded from the files of the UI, loading it again if any file has changed
+func (ui *devUI) load() (*uiCache, error) {
+
+	fingerprint, err := fingerprintFS(ui.fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	if ui.cache != nil && fingerprint == ui.fingerprint {
+		return ui.cache, nil
+	}
+
+	// The previous fingerprint is kept on failure, so the next request tries again
+	cache, err := loadUI(ui.fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	ui.fingerprint = fingerprint
+	ui.cache = cache
+
+	return cache, nil
+}
+
+// fingerprintFS hashes the name, size and modification time of every file in fsys
+func fingerprintFS(fsys fs.FS) ([sha256.Size]byte, error) {
+
+	hash := sha256.New()
+
+	err := fs.WalkDir(fsys, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(hash, "%s\x00%d\x00%d\n", name, info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+
+	var fingerprint [sha256.Size]byte
+	copy(fingerprint[:], hash.Sum(nil))
+
+	return fingerprint, err
+}
+
+// currentUI returns what is loaded from the files of the UI, reloaded first in development
+// if a file has changed
+func (app *application) currentUI() (*uiCache, error) {
+
+	if app.devUI == nil {
+		return app.ui, nil
+	}
+
+	return app.devUI.load()
+}
+
+// staticFiles serves the static files, reloaded first in development if a file has changed
+func (app *application) staticFiles() http.Handler {
+
+	if app.devUI == nil {
+		return app.ui.assets.handler()
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cache, err := app.devUI.load()
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		cache.assets.handler().ServeHTTP(w, r)
+	})
+}
+
+// templateError responds to a template that failed to parse or execute. In development it
+// shows the error along with the lines of the template around it, rather than the generic
+// page of serverError.
+func (app *application) templateError(w http.ResponseWriter, r *http.Request, tmplName string, err error) {
+
+	if app.devUI == nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.loggerFrom(r.Context()).Error("template error", "template", tmplName, "error", err)
+
+	data := debugData{
+		Method:   r.Method,
+		Path:     r.URL.Path,
+		Template: tmplName,
+		Error:    err.Error(),
+	}
+
+	// Errors start with the file and line they occurred at, e.g. "template: create.page.tmpl:12:5: ..."
+	if matches := templateErrorRX.FindStringSubmatch(data.Error); matches != nil {
+		line, _ := strconv.Atoi(matches[2])
+		data.File = matches[1]
+		data.Excerpt = templateExcerpt(app.devUI.fsys, "html/"+matches[1], line)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusInternalServerError)
+
+	if err := debugPage.Execute(w, data); err != nil {
+		app.loggerFrom(r.Context()).Error("failed to render debug page", "error", err)
+	}
+}
+
+var templateErrorRX = regexp.MustCompile(`template: ([\w.-]+):(\d+)`)
+
+// How many lines before and after the line of a template error are shown
+const excerptContext = 5
+
+type debugData struct {
+	Method   string
+	Path     string
+	Template string
+	Error    string
+	File     string
+	Excerpt  []excerptLine
+}
+
+type excerptLine struct {
+	Number int
+	Text   string
+	Error  bool
+}
+
+// templateExcerpt returns the lines of the named file around line, or nil if it can't be read
+func templateExcerpt(fsys fs.FS, name string, line int) []excerptLine {
+
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil
+	}
+
+	var excerpt []excerptLine
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for number := 1; scanner.Scan(); number++ {
+		if number >= line-excerptContext && number <= line+excerptContext {
+			excerpt = append(excerpt, excerptLine{Number: number, Text: scanner.Text(), Error: number == line})
+		}
+	}
+
+	return excerpt
+}
+
+// debugPage has no styles or scripts, which the CSP would block
+var debugPage = template.Must(template.New("debug").Parse(`<!doctype html>
+<html lang="en">
+	<head>
+		<meta charset="utf-8">
+		<title>Template error - TempShare</title>
+	</head>
+	<body>
+		<h1>Template error</h1>
+		<p>{{.Method}} {{.Path}} failed to render {{.Template}}:</p>
+		<pre>{{.Error}}</pre>
+		{{with .Excerpt}}
+		<h2>{{$.File}}</h2>
+		<pre>{{range .}}{{if .Error}}<mark>{{printf "%4d" .Number}}  {{.Text}}</mark>{{else}}{{printf "%4d" .Number}}  {{.Text}}{{end}}
+{{end}}</pre>
+		{{end}}
+		<p>This page is only shown in development, edit the template and reload.</p>
+	</body>
+</html>
+`))
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matthewlmitchell/tempshare/pkg/overlayfs"
+	"github.com/matthewlmitchell/tempshare/ui"
+)
+
+func TestDevUI(t *testing.T) {
+
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "html"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	page := filepath.Join(dir, "html", "about.page.tmpl")
+	writePage := func(body string, modTime time.Time) {
+		content := `{{template "base" .}}{{define "title"}}About{{end}}{{define "body"}}` + body + `{{end}}`
+		if err := os.WriteFile(page, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(page, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	app := newTestApplication(t)
+	app.devUI = &devUI{fsys: overlayfs.New(os.DirFS(dir), ui.Files)}
+
+	tests := []struct {
+		name     string
+		body     string
+		wantCode int
+		wantBody string
+	}{
+		{"Initial template", "<p>First version</p>", http.StatusOK, "First version"},
+		{"Edited template", "<p>Second version</p>", http.StatusOK, "Second version"},
+		{"Execution error", "<p>\n{{.Missing}}\n</p>", http.StatusInternalServerError, "<mark>   2  {{.Missing}}</mark>"},
+		{"Parse error", "<p>{{if}}</p>", http.StatusInternalServerError, "about.page.tmpl"},
+		{"Fixed template", "<p>Third version</p>", http.StatusOK, "Third version"},
+	}
+
+	modTime := time.Now().Add(-time.Hour)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			// Every edit gets a later modification time, as the file system may have a coarse resolution
+			modTime = modTime.Add(time.Second)
+			writePage(tt.body, modTime)
+
+			responseRecorder := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodGet, "/about", nil)
+
+			app.session.Enable(http.HandlerFunc(app.about)).ServeHTTP(responseRecorder, request)
+
+			if responseRecorder.Code != tt.wantCode {
+				t.Errorf("Expected status %d, received %d", tt.wantCode, responseRecorder.Code)
+			}
+
+			if !strings.Contains(responseRecorder.Body.String(), tt.wantBody) {
+				t.Errorf("Expected the body to contain %q, received %s", tt.wantBody, responseRecorder.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"mime/multipart"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/matthewlmitchell/tempshare/pkg/forms"
+	"github.com/matthewlmitchell/tempshare/pkg/models"
+)
+
+// The forms below are bound with forms.Bind, which validates each field by its tag. Checks
+// which involve several fields are done by their validate methods.
+
+// createForm is submitted by the create page, for every kind of TempShare
+type createForm struct {
+	Text       string    `form:"text,required,min=2,max=1024"`
+	Expires    int       `form:"expires,required,oneof=1 3 7"`
+	ViewLimit  int       `form:"viewlimit,required,oneof=1 3 10"`
+	Notify     string    `form:"notify,email"`
+	Email      string    `form:"email,email"`
+	Passphrase bool      `form:"passphrase"`
+	NotBefore  time.Time `form:"notbefore"`
+	Recipients string    `form:"recipients,max=1024"`
+	Parts      int       `form:"parts,min=2,max=10"`
+	Threshold  int       `form:"threshold,min=2,max=10"`
+	Captcha    string    `form:"g-recaptcha-response,required"`
+}
+
+// uploadForm is the text file which the text of the create form can be uploaded as instead.
+// It is bound before createForm, whose rules for the text then apply to the file's text too.
+type uploadForm struct {
+	File *multipart.FileHeader `form:"file,max=4KiB,types=text/plain"`
+}
+
+// copyText sets the text field of the form to the text of the uploaded file, if there is one.
+// An error is only returned if the file can't be read.
+func (data *uploadForm) copyText(form *forms.Form) error {
+
+	if data.File == nil {
+		return nil
+	}
+
+	if strings.TrimSpace(form.Get("text")) != "" {
+		form.Errors.Add("file", "Enter the text or upload a file, not both")
+		return nil
+	}
+
+	file, err := data.File.Open()
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	text, err := io.ReadAll(file)
+	if err != nil {
+		return err
+	}
+
+	if !utf8.Valid(text) {
+		form.Errors.Add("file", "This file must contain UTF-8 text")
+		return nil
+	}
+
+	form.Set("text", string(text))
+
+	return nil
+}
+
+// recipients returns the names of the recipients, one per non-blank line
+func (data *createForm) recipients() []string {
+	return parseRecipients(data.Recipients)
+}
+
+// validate checks the options of the create form against each other
+func (data *createForm) validate(form *forms.Form) {
+
+	// A time-locked share must become available at some point before it expires
+	if !data.NotBefore.IsZero() {
+		if !data.NotBefore.After(time.Now()) {
+			form.Errors.Add("notbefore", "This field must be in the future")
+		} else if data.NotBefore.After(time.Now().AddDate(0, 0, data.Expires)) {
+			form.Errors.Add("notbefore", "This field must be before the TempShare expires")
+		}
+	}
+
+	// Every recipient gets their own link, so they must be told apart by name
+	recipients := data.recipients()
+	if len(recipients) > maxRecipients {
+		form.Errors.Addf("recipients", "This field must not contain more than %d recipients", maxRecipients)
+	}
+	seen := map[string]bool{}
+	for _, recipient := range recipients {
+		if utf8.RuneCountInString(recipient) > 64 {
+			form.Errors.Add("recipients", "Recipient names must not be longer than 64 characters")
+			break
+		}
+		if seen[strings.ToLower(recipient)] {
+			form.Errors.Add("recipients", "Recipient names must be unique")
+			break
+		}
+		seen[strings.ToLower(recipient)] = true
+	}
+	if len(recipients) > 0 {
+		if data.Passphrase {
+			form.Errors.Add("passphrase", "Split delivery can't be used when sending to several recipients")
+		}
+		if data.Email != "" {
+			form.Errors.Add("email", "Links can't be emailed when sending to several recipients")
+		}
+	}
+
+	// Splitting a secret into parts is pointless if all of them end up in the same place
+	if data.Parts != 0 {
+		if data.Threshold == 0 {
+			form.Errors.Add("threshold", "This field must not be blank")
+		} else if data.Threshold > data.Parts {
+			form.Errors.Add("threshold", "This field must not be more than the number of parts")
+		}
+		if data.Passphrase {
+			form.Errors.Add("passphrase", "Split delivery can't be used when splitting into parts")
+		}
+		if data.Email != "" {
+			form.Errors.Add("email", "Links can't be emailed when splitting into parts")
+		}
+		if len(recipients) > 0 {
+			form.Errors.Add("recipients", "A secret split into parts can't be sent to several recipients")
+		}
+	}
+}
+
+// combineForm is submitted by the combine page
+type combineForm struct {
+	Tokens  string `form:"tokens,required,max=10240"`
+	Captcha string `form:"g-recaptcha-response,required"`
+}
+
+// tokens returns the tokens of the parts, one per non-blank line, each of which is either a
+// token or a whole link
+func (data *combineForm) tokens() []string {
+
+	tokens := []string{}
+	for _, line := range strings.Split(data.Tokens, "\n") {
+		if token := extractToken(line); token != "" {
+			tokens = append(tokens, token)
+		}
+	}
+
+	return tokens
+}
+
+// validate checks that every line of the combine form holds a token
+func (data *combineForm) validate(form *forms.Form) {
+
+	for _, token := range data.tokens() {
+		if len(token) != 52 {
+			form.Errors.Add("tokens", "One or more of the links are invalid")
+			break
+		}
+	}
+}
+
+// addError adds the reason the parts of the secret couldn't be combined to the tokens field.
+// It returns false if err is nil, or isn't caused by the tokens, but by the server.
+func (data *combineForm) addError(form *forms.Form, err error) bool {
+
+	var notYet *models.NotYetAvailableError
+
+	switch {
+	case err == models.ErrNotSplitShare:
+		form.Errors.Add("tokens", "One or more of the links aren't part of a split secret. Open them on the View page instead.")
+	case errors.Is(err, models.ErrNoRecord):
+		form.Errors.Add("tokens", unavailablePartMessage(err))
+	case err == models.ErrThresholdNotMet:
+		form.Errors.Add("tokens", "Not enough parts of the secret were supplied, no views have been used")
+	case err == models.ErrMixedShares:
+		form.Errors.Add("tokens", "These links are parts of different secrets")
+	case errors.As(err, &notYet):
+		form.Errors.Addf("tokens", "This secret is available from %s UTC", notYet.NotBefore)
+	default:
+		return false
+	}
+
+	return true
+}
+
+// tokenForm is the query of the pages which open a link, such as the manage page
+type tokenForm struct {
+	Token string `form:"token,required,min=52,max=52"`
+}
+
+// revokeForm is submitted by the manage page, with the recipient set when only their link
+// is revoked
+type revokeForm struct {
+	Token     string `form:"token,required,min=52,max=52"`
+	Recipient string `form:"recipient,max=64"`
+}
+
+// viewForm is submitted by the view page
+type viewForm struct {
+	Token      string `form:"token,required,min=52,max=52"`
+	Passphrase string `form:"passphrase,max=64"`
+	Captcha    string `form:"g-recaptcha-response,required"`
+}
+
+// requestForm is submitted by the request page
+type requestForm struct {
+	Expires int    `form:"expires,required,oneof=1 3 7"`
+	Captcha string `form:"g-recaptcha-response,required"`
+}
+
+// respondForm is submitted by the respond page
+type respondForm struct {
+	Token   string `form:"token,required,min=52,max=52"`
+	Text    string `form:"text,required,min=2,max=1024"`
+	Captcha string `form:"g-recaptcha-response,required"`
+}
+
+// collectForm is submitted by the collect page
+type collectForm struct {
+	Token   string `form:"token,required,min=52,max=52"`
+	Captcha string `form:"g-recaptcha-response,required"`
+}
+
+// loginForm is submitted by the admin login page
+type loginForm struct {
+	Username string `form:"username,required,max=64"`
+	Password string `form:"password,required,max=1024"`
+}
+
+// totpForm is submitted by the admin login page asking for a TOTP code
+type totpForm struct {
+	Code string `form:"code,required,pattern=^[0-9 ]{6,7}$"`
+}
@@ -1,12 +1,14 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 
 	"github.com/matthewlmitchell/tempshare/pkg/forms"
 	"github.com/matthewlmitchell/tempshare/pkg/models"
-	"github.com/matthewlmitchell/tempshare/pkg/recaptcha"
+	"github.com/matthewlmitchell/tempshare/pkg/webhook"
 )
 
 func (app *application) home(w http.ResponseWriter, r *http.Request) {
@@ -25,27 +27,40 @@ func (app *application) createTempShare(w http.ResponseWriter, r *http.Request)
 
 	// Parse the HTTP POST request for data to populate r.PostForm and r.Form .
 	// If any errors return, tell the client their request was bad.
-	err := r.ParseForm()
+	form, err := parseMultipartForm(r)
 	if err != nil {
 		app.clientError(w, http.StatusBadRequest)
 		return
 	}
+	if r.MultipartForm != nil {
+		defer r.MultipartForm.RemoveAll()
+	}
 
-	form := forms.New(r.PostForm)
-	form.Required("text", "expires", "viewlimit", "g-recaptcha-response")
-	form.MinLength("text", 2)
-	form.MaxLength("text", 1024)
-	form.PermittedValues("expires", "1", "3", "7")
-	form.PermittedValues("viewlimit", "1", "3", "10")
+	upload := &uploadForm{}
+	if err := form.Bind(upload); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if err := upload.copyText(form); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := &createForm{}
+	if err := form.Bind(data); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	data.validate(form)
 
 	if !form.Valid() {
 		app.render(w, r, "create.page.tmpl", &templateData{Form: form})
 		return
 	}
 
-	success, err := recaptcha.VerifyRecaptcha(app.serverConfig.env, app.httpsClient, r, form.Get("g-recaptcha-response"))
+	success, err := app.verifyCaptcha(r, data.Captcha)
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 	if !success {
@@ -53,13 +68,65 @@ func (app *application) createTempShare(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	tempShare, err := app.tempShare.New(form.Get("text"), form.Get("expires"), form.Get("viewlimit"))
+	if data.Parts != 0 {
+		app.createSplitTempShare(w, r, form, data)
+		return
+	}
+
+	// Read receipts and links can only be emailed if an SMTP server has been configured
+	notifyEmail, recipientEmail := "", ""
+	if app.mailer != nil {
+		notifyEmail = data.Notify
+		recipientEmail = data.Email
+	}
+
+	if len(data.recipients()) > 0 {
+		app.createMultiTempShare(w, r, form, data, notifyEmail)
+		return
+	}
+
+	tempShare, err := app.tempShare.New(r.Context(), data.Text, data.Expires, data.ViewLimit, notifyEmail, data.Passphrase, data.NotBefore)
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 
-	app.session.Put(r, "flash", fmt.Sprintf("Your TempShare link: %s", fmt.Sprintf("https://placeholder.com/view?token=%s", tempShare.PlainText)))
+	app.metrics.sharesCreated.WithLabelValues("single").Inc()
+
+	app.runInBackground(func() {
+		app.emitEvent(context.WithoutCancel(r.Context()), webhook.EventCreated, tempShare, "")
+	})
+
+	catalog := app.catalog(r)
+
+	link := fmt.Sprintf("%s/view?token=%s", app.serverConfig.baseURL, tempShare.PlainText)
+	flash := catalog.T("Your TempShare link: %s\nManage it at: %s",
+		link, fmt.Sprintf("%s/manage?token=%s", app.serverConfig.baseURL, tempShare.ManageToken))
+
+	if !tempShare.NotBefore.IsZero() {
+		flash += "\n" + catalog.T("It can't be opened before %s UTC.", tempShare.NotBefore)
+	}
+
+	// The passphrase is only ever shown here, it is not stored in plain text and is never emailed
+	if tempShare.Passphrase != "" {
+		flash += "\n" + catalog.T("Passphrase (send it separately from the link, it will not be shown again): %s", tempShare.Passphrase)
+	}
+
+	if recipientEmail != "" {
+		err = app.mailer.Enqueue(recipientEmail, "share.mail.tmpl", map[string]interface{}{
+			"Link":      link,
+			"Expires":   FormattedDate(tempShare.Expires) + " UTC",
+			"ViewLimit": tempShare.ViewLimit,
+		})
+		if err != nil {
+			app.loggerFrom(r.Context()).Error("failed to queue email", "error", err)
+			flash += "\n" + catalog.T("The link could not be emailed, please share it yourself.")
+		} else {
+			flash += "\n" + catalog.T("The link will be emailed to %s.", recipientEmail)
+		}
+	}
+
+	app.session.Put(r, "flash", flash)
 
 	// Refresh the page so the message flash will become visible
 	//http.Redirect(w, r, "/create", http.StatusSeeOther)
@@ -68,6 +135,77 @@ func (app *application) createTempShare(w http.ResponseWriter, r *http.Request)
 
 }
 
+// createSplitTempShare splits the text of a validated create form into several parts,
+// any "threshold" of which are required to reconstruct it, and flashes a link for each part.
+func (app *application) createSplitTempShare(w http.ResponseWriter, r *http.Request, form *forms.Form, data *createForm) {
+
+	parts, threshold, notBefore := data.Parts, data.Threshold, data.NotBefore
+
+	tempShares, err := app.tempShare.NewSplit(r.Context(), data.Text, data.Expires, data.ViewLimit, parts, threshold, notBefore)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.metrics.sharesCreated.WithLabelValues("split").Inc()
+
+	catalog := app.catalog(r)
+
+	flash := catalog.T("Your secret was split into %d links, any %d of which can be combined at %s/combine", len(tempShares), threshold, app.serverConfig.baseURL)
+	if !notBefore.IsZero() {
+		flash += " " + catalog.T("from %s UTC", notBefore)
+	}
+
+	for i, tempShare := range tempShares {
+		tempShare := tempShare
+		app.runInBackground(func() {
+			app.emitEvent(context.WithoutCancel(r.Context()), webhook.EventCreated, tempShare, "")
+		})
+
+		flash += "\n" + catalog.T("Part %d: %s/view?token=%s (manage it at: %s/manage?token=%s)", i+1,
+			app.serverConfig.baseURL, tempShare.PlainText, app.serverConfig.baseURL, tempShare.ManageToken)
+	}
+
+	app.session.Put(r, "flash", flash)
+
+	app.render(w, r, "create.page.tmpl", &templateData{Form: form})
+}
+
+// createMultiTempShare sends the text of a validated create form to several named recipients,
+// and flashes a link for each recipient along with a single link to manage all of them.
+func (app *application) createMultiTempShare(w http.ResponseWriter, r *http.Request, form *forms.Form, data *createForm, notifyEmail string) {
+
+	notBefore := data.NotBefore
+
+	manageToken, tempShares, err := app.tempShare.NewMulti(r.Context(), data.Text, data.Expires, data.ViewLimit, notifyEmail, data.recipients(), notBefore)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.metrics.sharesCreated.WithLabelValues("multi").Inc()
+
+	catalog := app.catalog(r)
+
+	flash := catalog.T("Your TempShare links for %d recipients, manage them all at: %s/manage?token=%s", len(tempShares), app.serverConfig.baseURL, manageToken)
+	if !notBefore.IsZero() {
+		flash += "\n" + catalog.T("They can't be opened before %s UTC.", notBefore)
+	}
+
+	for _, tempShare := range tempShares {
+		tempShare := tempShare
+		app.runInBackground(func() {
+			app.emitEvent(context.WithoutCancel(r.Context()), webhook.EventCreated, tempShare, "")
+		})
+
+		flash += fmt.Sprintf("\n%s: %s/view?token=%s", tempShare.Recipient, app.serverConfig.baseURL, tempShare.PlainText)
+	}
+
+	app.session.Put(r, "flash", flash)
+
+	app.render(w, r, "create.page.tmpl", &templateData{Form: form})
+}
+
 func (app *application) viewTempShareForm(w http.ResponseWriter, r *http.Request) {
 
 	formData := forms.New(r.URL.Query())
@@ -84,9 +222,11 @@ func (app *application) viewTempShare(w http.ResponseWriter, r *http.Request) {
 	}
 
 	form := forms.New(r.PostForm)
-	form.Required("token", "g-recaptcha-response")
-	form.MaxLength("token", 52)
-	form.MinLength("token", 52)
+	data := &viewForm{}
+	if err := form.Bind(data); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
 
 	if !form.Valid() {
 		form.Errors.Add("generic", "Invalid token")
@@ -94,38 +234,457 @@ func (app *application) viewTempShare(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	success, err := recaptcha.VerifyRecaptcha(app.serverConfig.env, app.httpsClient, r, form.Get("g-recaptcha-response"))
+	success, err := app.verifyCaptcha(r, data.Captcha)
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 	if !success {
-		app.session.Put(r, "flash", "An error occurred.\nPlease complete the captcha again.")
+		app.session.Put(r, "flash", app.catalog(r).T("An error occurred.\nPlease complete the captcha again."))
 		app.render(w, r, "view.page.tmpl", &templateData{Form: form})
 		return
 	}
 
 	token := &models.TempShare{
-		PlainText: form.Get("token"),
+		PlainText: data.Token,
 	}
 
-	tempShareData, err := app.tempShare.Get(token.PlainText)
-	if err == models.ErrNoRecord {
-		form.Errors.Add("generic", "Invalid token")
+	var notYet *models.NotYetAvailableError
+
+	tempShareData, err := app.tempShare.Get(r.Context(), token.PlainText, data.Passphrase)
+	if err == models.ErrInvalidPassphrase {
+		if data.Passphrase == "" {
+			form.Errors.Add("passphrase", "A passphrase is required to open this TempShare")
+		} else {
+			form.Errors.Add("passphrase", "Incorrect passphrase")
+		}
+		app.render(w, r, "view.page.tmpl", &templateData{Form: form})
+		return
+	} else if err == models.ErrSplitShare {
+		form.Errors.Add("generic", "This link is one part of a split secret. Open it together with the other parts on the Combine page.")
+		app.render(w, r, "view.page.tmpl", &templateData{Form: form})
+		return
+	} else if errors.As(err, &notYet) {
+		form.Errors.Addf("generic", "This TempShare is available from %s UTC", notYet.NotBefore)
+		app.render(w, r, "view.page.tmpl", &templateData{Form: form})
+		return
+	} else if errors.Is(err, models.ErrNoRecord) {
+		form.Errors.Add("generic", unavailableMessage(err))
 		app.render(w, r, "view.page.tmpl", &templateData{Form: form})
 		return
 	} else if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 
-	// TODO: Delete tempShare from database when views >= viewlimit
+	app.recordView(r, tempShareData)
 
-	app.session.Put(r, "flash", fmt.Sprintf("This link has %d uses remaining.", tempShareData.ViewLimit-tempShareData.Views-1))
+	// Get has already cleared the text if this was the last view. The row is kept without its
+	// text, so that whoever opens the link next is told it has been used up.
+
+	app.session.Put(r, "flash", app.catalog(r).T("This link has %d uses remaining.", tempShareData.ViewLimit-tempShareData.Views-1))
 
 	app.render(w, r, "home.page.tmpl", &templateData{TempShare: tempShareData})
 }
 
+func (app *application) combineTempShareForm(w http.ResponseWriter, r *http.Request) {
+
+	app.render(w, r, "combine.page.tmpl", &templateData{
+		Form: forms.New(nil),
+	})
+}
+
+func (app *application) combineTempShare(w http.ResponseWriter, r *http.Request) {
+
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	data := &combineForm{}
+	if err := form.Bind(data); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	data.validate(form)
+
+	if !form.Valid() {
+		app.render(w, r, "combine.page.tmpl", &templateData{Form: form})
+		return
+	}
+
+	success, err := app.verifyCaptcha(r, data.Captcha)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if !success {
+		app.session.Put(r, "flash", app.catalog(r).T("An error occurred.\nPlease complete the captcha again."))
+		app.render(w, r, "combine.page.tmpl", &templateData{Form: form})
+		return
+	}
+
+	secret, tempShares, err := app.tempShare.Combine(r.Context(), data.tokens())
+	if data.addError(form, err) {
+		app.render(w, r, "combine.page.tmpl", &templateData{Form: form})
+		return
+	} else if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	for _, tempShare := range tempShares {
+		app.recordView(r, tempShare)
+	}
+
+	app.render(w, r, "home.page.tmpl", &templateData{TempShare: &models.TempShare{
+		Text:    secret,
+		Created: tempShares[0].Created,
+		Expires: tempShares[0].Expires,
+	}})
+}
+
+func (app *application) manageTempShare(w http.ResponseWriter, r *http.Request) {
+
+	form := forms.New(r.URL.Query())
+	data := &tokenForm{}
+	if err := form.Bind(data); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if !form.Valid() {
+		form.Errors.Add("generic", "Invalid token")
+		app.render(w, r, "manage.page.tmpl", &templateData{Form: form})
+		return
+	}
+
+	tempShareData, err := app.tempShare.GetManaged(r.Context(), data.Token)
+	if err == models.ErrNoRecord {
+		app.manageRecipients(w, r, form, data.Token)
+		return
+	} else if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	views, err := app.tempShare.Views(r.Context(), tempShareData.URLToken)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.render(w, r, "manage.page.tmpl", &templateData{
+		Form:      form,
+		TempShare: tempShareData,
+		Views:     views,
+	})
+}
+
+func (app *application) revokeTempShare(w http.ResponseWriter, r *http.Request) {
+
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	data := &revokeForm{}
+	if err := form.Bind(data); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if !form.Valid() {
+		form.Errors.Add("generic", "Invalid token")
+		app.render(w, r, "manage.page.tmpl", &templateData{Form: form})
+		return
+	}
+
+	if data.Recipient != "" {
+		app.revokeRecipient(w, r, form, data)
+		return
+	}
+
+	tempShareData, err := app.tempShare.Revoke(r.Context(), data.Token)
+	if err == models.ErrNoRecord {
+		form.Errors.Add("generic", "Invalid token")
+		app.render(w, r, "manage.page.tmpl", &templateData{Form: form})
+		return
+	} else if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.runInBackground(func() {
+		app.emitEvent(context.WithoutCancel(r.Context()), webhook.EventRevoked, tempShareData, "")
+	})
+
+	views, err := app.tempShare.Views(r.Context(), tempShareData.URLToken)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.session.Put(r, "flash", app.catalog(r).T("This TempShare has been revoked and can no longer be viewed."))
+
+	app.render(w, r, "manage.page.tmpl", &templateData{
+		Form:      form,
+		TempShare: tempShareData,
+		Views:     views,
+	})
+}
+
+// manageRecipients renders the management page of a TempShare sent to several recipients,
+// showing which of them have opened their link.
+func (app *application) manageRecipients(w http.ResponseWriter, r *http.Request, form *forms.Form, token string) {
+
+	recipients, err := app.tempShare.GetRecipients(r.Context(), token)
+	if err == models.ErrNoRecord {
+		form.Errors.Add("generic", "Invalid token")
+		app.render(w, r, "manage.page.tmpl", &templateData{Form: form})
+		return
+	} else if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.render(w, r, "manage.page.tmpl", &templateData{
+		Form:       form,
+		Recipients: recipients,
+	})
+}
+
+// revokeRecipient revokes the link of a single recipient of a TempShare sent to several
+// recipients, leaving the links of the others untouched.
+func (app *application) revokeRecipient(w http.ResponseWriter, r *http.Request, form *forms.Form, data *revokeForm) {
+
+	tempShareData, err := app.tempShare.RevokeRecipient(r.Context(), data.Token, data.Recipient)
+	if err == models.ErrNoRecord {
+		form.Errors.Add("generic", "Invalid token")
+		app.render(w, r, "manage.page.tmpl", &templateData{Form: form})
+		return
+	} else if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.runInBackground(func() {
+		app.emitEvent(context.WithoutCancel(r.Context()), webhook.EventRevoked, tempShareData, "")
+	})
+
+	recipients, err := app.tempShare.GetRecipients(r.Context(), data.Token)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.session.Put(r, "flash", app.catalog(r).T("The link for %s has been revoked and can no longer be viewed.", tempShareData.Recipient))
+
+	app.render(w, r, "manage.page.tmpl", &templateData{
+		Form:       form,
+		Recipients: recipients,
+	})
+}
+
+func (app *application) createRequestForm(w http.ResponseWriter, r *http.Request) {
+
+	app.render(w, r, "request.page.tmpl", &templateData{
+		Form: forms.New(nil),
+	})
+}
+
+func (app *application) createRequest(w http.ResponseWriter, r *http.Request) {
+
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	data := &requestForm{}
+	if err := form.Bind(data); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if !form.Valid() {
+		app.render(w, r, "request.page.tmpl", &templateData{Form: form})
+		return
+	}
+
+	success, err := app.verifyCaptcha(r, data.Captcha)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if !success {
+		app.render(w, r, "request.page.tmpl", &templateData{Form: form})
+		return
+	}
+
+	request, err := app.requests.New(r.Context(), data.Expires)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.session.Put(r, "flash", app.catalog(r).T("Send this link to the person you are requesting a secret from: %s\nKeep this link to yourself, it is the only way to read their response: %s",
+		fmt.Sprintf("%s/respond?token=%s", app.serverConfig.baseURL, request.UploadToken),
+		fmt.Sprintf("%s/collect?token=%s", app.serverConfig.baseURL, request.ReadToken)))
+
+	app.render(w, r, "request.page.tmpl", &templateData{Form: form})
+}
+
+func (app *application) respondRequestForm(w http.ResponseWriter, r *http.Request) {
+
+	form := forms.New(r.URL.Query())
+	data := &tokenForm{}
+	if err := form.Bind(data); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if !form.Valid() {
+		form.Errors.Add("generic", "This request link is invalid, has expired or has already been responded to")
+		app.render(w, r, "respond.page.tmpl", &templateData{Form: form})
+		return
+	}
+
+	request, err := app.requests.Pending(r.Context(), data.Token)
+	if err == models.ErrNoRecord {
+		form.Errors.Add("generic", "This request link is invalid, has expired or has already been responded to")
+		app.render(w, r, "respond.page.tmpl", &templateData{Form: form})
+		return
+	} else if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.render(w, r, "respond.page.tmpl", &templateData{Form: form, Request: request})
+}
+
+func (app *application) respondRequest(w http.ResponseWriter, r *http.Request) {
+
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	data := &respondForm{}
+	if err := form.Bind(data); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if form.Errors.Get("token") != nil {
+		form.Errors.Add("generic", "This request link is invalid, has expired or has already been responded to")
+		app.render(w, r, "respond.page.tmpl", &templateData{Form: form})
+		return
+	}
+
+	// The response form is only rendered along with a Request, which we haven't looked up yet
+	request := &models.Request{}
+
+	if !form.Valid() {
+		app.render(w, r, "respond.page.tmpl", &templateData{Form: form, Request: request})
+		return
+	}
+
+	success, err := app.verifyCaptcha(r, data.Captcha)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if !success {
+		app.session.Put(r, "flash", app.catalog(r).T("An error occurred.\nPlease complete the captcha again."))
+		app.render(w, r, "respond.page.tmpl", &templateData{Form: form, Request: request})
+		return
+	}
+
+	err = app.requests.Fulfil(r.Context(), data.Token, data.Text)
+	if err == models.ErrNoRecord {
+		form.Errors.Add("generic", "This request link is invalid, has expired or has already been responded to")
+		app.render(w, r, "respond.page.tmpl", &templateData{Form: form})
+		return
+	} else if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.session.Put(r, "flash", app.catalog(r).T("Your response has been stored. Only the person who requested it can read it, and only once."))
+
+	app.render(w, r, "respond.page.tmpl", &templateData{Form: forms.New(nil)})
+}
+
+func (app *application) collectRequestForm(w http.ResponseWriter, r *http.Request) {
+
+	formData := forms.New(r.URL.Query())
+
+	app.render(w, r, "collect.page.tmpl", &templateData{Form: formData})
+}
+
+func (app *application) collectRequest(w http.ResponseWriter, r *http.Request) {
+
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	data := &collectForm{}
+	if err := form.Bind(data); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if !form.Valid() {
+		form.Errors.Add("generic", "Invalid token")
+		app.render(w, r, "collect.page.tmpl", &templateData{Form: form})
+		return
+	}
+
+	success, err := app.verifyCaptcha(r, data.Captcha)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if !success {
+		app.session.Put(r, "flash", app.catalog(r).T("An error occurred.\nPlease complete the captcha again."))
+		app.render(w, r, "collect.page.tmpl", &templateData{Form: form})
+		return
+	}
+
+	request, err := app.requests.Get(r.Context(), data.Token)
+	if err == models.ErrNotFulfilled {
+		form.Errors.Add("generic", "Your request hasn't been responded to yet, please check back later.")
+		app.render(w, r, "collect.page.tmpl", &templateData{Form: form})
+		return
+	} else if err == models.ErrNoRecord {
+		form.Errors.Add("generic", "Invalid token")
+		app.render(w, r, "collect.page.tmpl", &templateData{Form: form})
+		return
+	} else if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.session.Put(r, "flash", app.catalog(r).T("This response can not be viewed again."))
+
+	app.render(w, r, "home.page.tmpl", &templateData{TempShare: &models.TempShare{
+		Text:    request.Text,
+		Created: request.Fulfilled,
+		Expires: request.Fulfilled.Add(models.RequestReadWindow),
+	}})
+}
+
 func (app *application) about(w http.ResponseWriter, r *http.Request) {
 
 	app.render(w, r, "about.page.tmpl", nil)
@@ -6,6 +6,7 @@ import (
 	"net/url"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestHome(t *testing.T) {
@@ -69,6 +70,10 @@ func TestCreateTempShare(t *testing.T) {
 		inputTempShareText      string
 		inputTempShareExpires   string
 		inputTempShareViewLimit string
+		inputTempShareParts     string
+		inputTempShareThreshold string
+		inputTempShareNotBefore string
+		inputRecipients         string
 		expectedStatusCode      int
 		expectedResponse        []byte
 	}{
@@ -162,6 +167,110 @@ func TestCreateTempShare(t *testing.T) {
 			expectedStatusCode:      http.StatusOK,
 			expectedResponse:        []byte("This field is invalid."),
 		},
+		{
+			name:                    "Valid split",
+			tokenCSRF:               csrfToken,
+			inputTempShareText:      "Hello World",
+			inputTempShareExpires:   "1",
+			inputTempShareViewLimit: "1",
+			inputTempShareParts:     "3",
+			inputTempShareThreshold: "2",
+			expectedStatusCode:      http.StatusOK,
+			expectedResponse:        []byte("Your secret was split into 3 links, any 2 of which"),
+		},
+		{
+			name:                    "Threshold above parts",
+			tokenCSRF:               csrfToken,
+			inputTempShareText:      "Hello World",
+			inputTempShareExpires:   "1",
+			inputTempShareViewLimit: "1",
+			inputTempShareParts:     "2",
+			inputTempShareThreshold: "3",
+			expectedStatusCode:      http.StatusOK,
+			expectedResponse:        []byte("This field must not be more than the number of parts"),
+		},
+		{
+			name:                    "Parts without threshold",
+			tokenCSRF:               csrfToken,
+			inputTempShareText:      "Hello World",
+			inputTempShareExpires:   "1",
+			inputTempShareViewLimit: "1",
+			inputTempShareParts:     "3",
+			expectedStatusCode:      http.StatusOK,
+			expectedResponse:        []byte("This field must not be blank"),
+		},
+		{
+			name:                    "Time-locked",
+			tokenCSRF:               csrfToken,
+			inputTempShareText:      "Hello World",
+			inputTempShareExpires:   "1",
+			inputTempShareViewLimit: "1",
+			inputTempShareNotBefore: time.Now().Add(2 * time.Hour).UTC().Format("2006-01-02T15:04"),
+			expectedStatusCode:      http.StatusOK,
+			expectedResponse:        []byte("It can&#39;t be opened before"),
+		},
+		{
+			name:                    "Available time in the past",
+			tokenCSRF:               csrfToken,
+			inputTempShareText:      "Hello World",
+			inputTempShareExpires:   "1",
+			inputTempShareViewLimit: "1",
+			inputTempShareNotBefore: "2022-03-02T12:00",
+			expectedStatusCode:      http.StatusOK,
+			expectedResponse:        []byte("This field must be in the future"),
+		},
+		{
+			name:                    "Available time after expiry",
+			tokenCSRF:               csrfToken,
+			inputTempShareText:      "Hello World",
+			inputTempShareExpires:   "1",
+			inputTempShareViewLimit: "1",
+			inputTempShareNotBefore: time.Now().AddDate(0, 0, 2).UTC().Format(time.RFC3339),
+			expectedStatusCode:      http.StatusOK,
+			expectedResponse:        []byte("This field must be before the TempShare expires"),
+		},
+		{
+			name:                    "Invalid available time",
+			tokenCSRF:               csrfToken,
+			inputTempShareText:      "Hello World",
+			inputTempShareExpires:   "1",
+			inputTempShareViewLimit: "1",
+			inputTempShareNotBefore: "tomorrow",
+			expectedStatusCode:      http.StatusOK,
+			expectedResponse:        []byte("This field must be a valid date and time"),
+		},
+		{
+			name:                    "Several recipients",
+			tokenCSRF:               csrfToken,
+			inputTempShareText:      "Hello World",
+			inputTempShareExpires:   "1",
+			inputTempShareViewLimit: "1",
+			inputRecipients:         "Alice\r\n\r\n Bob \r\nCarol",
+			expectedStatusCode:      http.StatusOK,
+			expectedResponse:        []byte("Bob: https://placeholder.com/view?token=RECIPIENT2TOKEN"),
+		},
+		{
+			name:                    "Duplicate recipients",
+			tokenCSRF:               csrfToken,
+			inputTempShareText:      "Hello World",
+			inputTempShareExpires:   "1",
+			inputTempShareViewLimit: "1",
+			inputRecipients:         "Alice\nalice",
+			expectedStatusCode:      http.StatusOK,
+			expectedResponse:        []byte("Recipient names must be unique"),
+		},
+		{
+			name:                    "Recipients of a split secret",
+			tokenCSRF:               csrfToken,
+			inputTempShareText:      "Hello World",
+			inputTempShareExpires:   "1",
+			inputTempShareViewLimit: "1",
+			inputTempShareParts:     "3",
+			inputTempShareThreshold: "2",
+			inputRecipients:         "Alice\nBob",
+			expectedStatusCode:      http.StatusOK,
+			expectedResponse:        []byte("A secret split into parts can&#39;t be sent to several recipients"),
+		},
 	}
 
 	for _, testCase := range testCases {
@@ -171,6 +280,10 @@ func TestCreateTempShare(t *testing.T) {
 			form.Add("text", testCase.inputTempShareText)
 			form.Add("expires", testCase.inputTempShareExpires)
 			form.Add("viewlimit", testCase.inputTempShareViewLimit)
+			form.Add("parts", testCase.inputTempShareParts)
+			form.Add("threshold", testCase.inputTempShareThreshold)
+			form.Add("notbefore", testCase.inputTempShareNotBefore)
+			form.Add("recipients", testCase.inputRecipients)
 			form.Add("g-recaptcha-response", "this-value-doesnt-matter-for-test-servers")
 
 			statusCode, _, responseBody := testServ.postForm(t, "/create", form)
@@ -206,6 +319,54 @@ func TestViewTempShareForm(t *testing.T) {
 	}
 }
 
+func TestCreateTempShareUpload(t *testing.T) {
+	app := newTestApplication(t)
+
+	testServ := newTestServer(t, app.routes(), false)
+	defer testServ.Close()
+
+	_, _, responseBody := testServ.get(t, "/create")
+	csrfToken := extractCSRFToken(t, responseBody)
+
+	testCases := []struct {
+		name             string
+		text             string
+		contentType      string
+		content          []byte
+		expectedResponse []byte
+	}{
+		{"Text file", "", "text/plain", []byte("Hello World"), []byte("Your TempShare link")},
+		{"Text file with charset", "", "text/plain; charset=utf-8", []byte("Hello World"), []byte("Your TempShare link")},
+		{"Text typed too", "Hello World", "text/plain", []byte("Hello World"), []byte("Enter the text or upload a file, not both")},
+		{"Binary file", "", "application/octet-stream", []byte("Hello World"), []byte("This type of file is not allowed")},
+		{"Too large", "", "text/plain", bytes.Repeat([]byte("A"), 5000), []byte("This file must not be larger than 4KiB")},
+		{"Too long", "", "text/plain", bytes.Repeat([]byte("A"), 2000), []byte("This field must contain less than 1024 characters")},
+		{"Not UTF-8", "", "text/plain", []byte{0xff, 0xfe, 0x41}, []byte("This file must contain UTF-8 text")},
+		{"No text or file", "", "", nil, []byte("This field must not be blank")},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("gorilla.csrf.Token", csrfToken)
+			form.Add("text", testCase.text)
+			form.Add("expires", "1")
+			form.Add("viewlimit", "1")
+			form.Add("g-recaptcha-response", "this-value-doesnt-matter-for-test-servers")
+
+			statusCode, _, responseBody := testServ.postMultipart(t, "/create", form, "file", testCase.contentType, testCase.content)
+
+			if statusCode != http.StatusOK {
+				t.Errorf("Expected status %d, received status %d", http.StatusOK, statusCode)
+			}
+
+			if !bytes.Contains(responseBody, testCase.expectedResponse) {
+				t.Errorf("Expected %s, received %s", testCase.expectedResponse, responseBody)
+			}
+		})
+	}
+}
+
 func TestViewTempShare(t *testing.T) {
 	app := newTestApplication(t)
 
@@ -230,6 +391,7 @@ func TestViewTempShare(t *testing.T) {
 		name               string
 		tokenCSRF          string
 		tokenTempShare     string
+		passphrase         string
 		expectedStatusCode int
 		expectedResponse   []byte
 	}{
@@ -275,6 +437,72 @@ func TestViewTempShare(t *testing.T) {
 			expectedStatusCode: http.StatusOK,
 			expectedResponse:   []byte("Invalid token"),
 		},
+		{
+			name:               "Part of a split secret",
+			tokenCSRF:          csrfToken,
+			tokenTempShare:     "SPLITPARTONEAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA",
+			expectedStatusCode: http.StatusOK,
+			expectedResponse:   []byte("This link is one part of a split secret"),
+		},
+		{
+			name:               "Unknown token",
+			tokenCSRF:          csrfToken,
+			tokenTempShare:     "UNKNOWNTOKENAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA",
+			expectedStatusCode: http.StatusOK,
+			expectedResponse:   []byte("Invalid token"),
+		},
+		{
+			name:               "Expired",
+			tokenCSRF:          csrfToken,
+			tokenTempShare:     "EXPIREDTOKENAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA",
+			expectedStatusCode: http.StatusOK,
+			expectedResponse:   []byte("This TempShare has expired"),
+		},
+		{
+			name:               "View limit reached",
+			tokenCSRF:          csrfToken,
+			tokenTempShare:     "EXHAUSTEDTOKENAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA",
+			expectedStatusCode: http.StatusOK,
+			expectedResponse:   []byte("This TempShare has already been opened"),
+		},
+		{
+			name:               "Revoked",
+			tokenCSRF:          csrfToken,
+			tokenTempShare:     "REVOKEDTOKENAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA",
+			expectedStatusCode: http.StatusOK,
+			expectedResponse:   []byte("This TempShare was revoked by the sender"),
+		},
+		{
+			name:               "Time-locked",
+			tokenCSRF:          csrfToken,
+			tokenTempShare:     "LOCKEDTOKENAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA",
+			expectedStatusCode: http.StatusOK,
+			expectedResponse:   []byte("This TempShare is available from"),
+		},
+		{
+			name:               "Valid passphrase",
+			tokenCSRF:          csrfToken,
+			tokenTempShare:     "P7ZJ2K5RXQ3MWN4VBTYHC6LDGAEUFSO2I7ZQ3XK5RWMN4VBTYHCQ",
+			passphrase:         "ABCDE-FGHIJ-KLMNO-PQRST",
+			expectedStatusCode: http.StatusOK,
+			expectedResponse:   []byte("This is an example tempshare protected by a passphrase!"),
+		},
+		{
+			name:               "Incorrect passphrase",
+			tokenCSRF:          csrfToken,
+			tokenTempShare:     "P7ZJ2K5RXQ3MWN4VBTYHC6LDGAEUFSO2I7ZQ3XK5RWMN4VBTYHCQ",
+			passphrase:         "ABCDE-FGHIJ-KLMNO-PQRSA",
+			expectedStatusCode: http.StatusOK,
+			expectedResponse:   []byte("Incorrect passphrase"),
+		},
+		{
+			name:               "Missing passphrase",
+			tokenCSRF:          csrfToken,
+			tokenTempShare:     "P7ZJ2K5RXQ3MWN4VBTYHC6LDGAEUFSO2I7ZQ3XK5RWMN4VBTYHCQ",
+			passphrase:         "",
+			expectedStatusCode: http.StatusOK,
+			expectedResponse:   []byte("A passphrase is required to open this TempShare"),
+		},
 	}
 
 	for _, testCase := range testCases {
@@ -282,6 +510,7 @@ func TestViewTempShare(t *testing.T) {
 			form := url.Values{}
 			form.Add("gorilla.csrf.Token", testCase.tokenCSRF)
 			form.Add("token", testCase.tokenTempShare)
+			form.Add("passphrase", testCase.passphrase)
 			form.Add("g-recaptcha-response", "this-value-doesnt-matter-with-test-key")
 
 			statusCode, _, responseBody := testServ.postForm(t, "/view", form)
@@ -297,6 +526,353 @@ func TestViewTempShare(t *testing.T) {
 	}
 }
 
+func TestCombineTempShare(t *testing.T) {
+	app := newTestApplication(t)
+
+	testServ := newTestServer(t, app.routes(), false)
+	defer testServ.Close()
+
+	_, _, responseBody := testServ.get(t, "/combine")
+	csrfToken := extractCSRFToken(t, responseBody)
+
+	testCases := []struct {
+		name               string
+		tokenCSRF          string
+		tokens             string
+		expectedStatusCode int
+		expectedResponse   []byte
+	}{
+		{
+			name:               "Threshold met",
+			tokenCSRF:          csrfToken,
+			tokens:             "SPLITPARTONEAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA\nhttps://placeholder.com/view?token=SPLITPARTTHREEAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA\n",
+			expectedStatusCode: http.StatusOK,
+			expectedResponse:   []byte("This is an example tempshare for testing purposes!"),
+		},
+		{
+			name:               "Threshold not met",
+			tokenCSRF:          csrfToken,
+			tokens:             "SPLITPARTONEAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA\nSPLITPARTONEAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA",
+			expectedStatusCode: http.StatusOK,
+			expectedResponse:   []byte("Not enough parts of the secret were supplied"),
+		},
+		{
+			name:               "Unknown part",
+			tokenCSRF:          csrfToken,
+			tokens:             "SPLITPARTONEAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA\nUNKNOWNPARTAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA",
+			expectedStatusCode: http.StatusOK,
+			expectedResponse:   []byte("One or more of the links are invalid"),
+		},
+		{
+			name:               "Used up part",
+			tokenCSRF:          csrfToken,
+			tokens:             "SPLITPARTONEAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA\nSPLITPARTUSEDAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA",
+			expectedStatusCode: http.StatusOK,
+			expectedResponse:   []byte("One or more of the links have already been opened as many times as the sender allowed."),
+		},
+		{
+			name:               "Not a part",
+			tokenCSRF:          csrfToken,
+			tokens:             "SPLITPARTONEAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA\nMUPPH5PDKV7AGCUAAEERL5ARIXICVVGYLRIV365X5XSV3EKISAXQ",
+			expectedStatusCode: http.StatusOK,
+			expectedResponse:   []byte("One or more of the links aren&#39;t part of a split secret."),
+		},
+		{
+			name:               "Malformed part",
+			tokenCSRF:          csrfToken,
+			tokens:             "SPLITPARTONEAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA\nINVALID",
+			expectedStatusCode: http.StatusOK,
+			expectedResponse:   []byte("One or more of the links are invalid"),
+		},
+		{
+			name:               "Invalid CSRF Token",
+			tokenCSRF:          "INVALID",
+			tokens:             "SPLITPARTONEAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA",
+			expectedStatusCode: http.StatusForbidden,
+			expectedResponse:   []byte("Forbidden - CSRF token invalid"),
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("gorilla.csrf.Token", testCase.tokenCSRF)
+			form.Add("tokens", testCase.tokens)
+			form.Add("g-recaptcha-response", "this-value-doesnt-matter-with-test-key")
+
+			statusCode, _, responseBody := testServ.postForm(t, "/combine", form)
+
+			if statusCode != testCase.expectedStatusCode {
+				t.Errorf("Expected status %d, received status %d", testCase.expectedStatusCode, statusCode)
+			}
+
+			if !bytes.Contains(responseBody, testCase.expectedResponse) {
+				t.Errorf("Expected body %s to contain %s", responseBody, testCase.expectedResponse)
+			}
+		})
+	}
+}
+
+func TestManageTempShare(t *testing.T) {
+	app := newTestApplication(t)
+
+	testServ := newTestServer(t, app.routes(), false)
+	defer testServ.Close()
+
+	testCases := []struct {
+		name               string
+		tokenManage        string
+		expectedStatusCode int
+		expectedResponse   []byte
+	}{
+		{
+			name:               "Valid token",
+			tokenManage:        "Q3NXTLOVHBWY2GWCQVJ4WDPHJ6B7TM2ZK5XRDFAE6OUKIRPLSM4Q",
+			expectedStatusCode: http.StatusOK,
+			expectedResponse:   []byte("Firefox from 192.0.2.0/24"),
+		},
+		{
+			name:               "Several recipients",
+			tokenManage:        "MULTIMANAGETOKENAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA",
+			expectedStatusCode: http.StatusOK,
+			expectedResponse:   []byte("<td>Alice</td>\n\t\t\t<td>1 of 1 times</td>"),
+		},
+		{
+			name:               "Share token is not a management token",
+			tokenManage:        "MUPPH5PDKV7AGCUAAEERL5ARIXICVVGYLRIV365X5XSV3EKISAXQ",
+			expectedStatusCode: http.StatusOK,
+			expectedResponse:   []byte("Invalid token"),
+		},
+		{
+			name:               "Management token too short",
+			tokenManage:        "INVALID",
+			expectedStatusCode: http.StatusOK,
+			expectedResponse:   []byte("Invalid token"),
+		},
+		{
+			name:               "Management token is empty",
+			tokenManage:        "",
+			expectedStatusCode: http.StatusOK,
+			expectedResponse:   []byte("Invalid token"),
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			statusCode, _, responseBody := testServ.get(t, "/manage?token="+url.QueryEscape(testCase.tokenManage))
+
+			if statusCode != testCase.expectedStatusCode {
+				t.Errorf("Expected status %d, received status %d", testCase.expectedStatusCode, statusCode)
+			}
+
+			if !bytes.Contains(responseBody, testCase.expectedResponse) {
+				t.Errorf("Expected body %s to contain %s", responseBody, testCase.expectedResponse)
+			}
+
+			// The text of a TempShare must never be shown on the management page
+			if bytes.Contains(responseBody, []byte("This is an example tempshare for testing purposes!")) {
+				t.Errorf("Expected body %s to not contain the TempShare text", responseBody)
+			}
+		})
+	}
+}
+
+func TestRevokeTempShare(t *testing.T) {
+	app := newTestApplication(t)
+
+	testServ := newTestServer(t, app.routes(), false)
+	defer testServ.Close()
+
+	_, _, responseBody := testServ.get(t, "/manage?token=Q3NXTLOVHBWY2GWCQVJ4WDPHJ6B7TM2ZK5XRDFAE6OUKIRPLSM4Q")
+	csrfToken := extractCSRFToken(t, responseBody)
+
+	testCases := []struct {
+		name               string
+		tokenCSRF          string
+		tokenManage        string
+		recipient          string
+		expectedStatusCode int
+		expectedResponse   []byte
+	}{
+		{
+			name:               "Valid token",
+			tokenCSRF:          csrfToken,
+			tokenManage:        "Q3NXTLOVHBWY2GWCQVJ4WDPHJ6B7TM2ZK5XRDFAE6OUKIRPLSM4Q",
+			expectedStatusCode: http.StatusOK,
+			expectedResponse:   []byte("This TempShare has been revoked"),
+		},
+		{
+			name:               "Single recipient",
+			tokenCSRF:          csrfToken,
+			tokenManage:        "MULTIMANAGETOKENAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA",
+			recipient:          "Bob",
+			expectedStatusCode: http.StatusOK,
+			expectedResponse:   []byte("The link for Bob has been revoked"),
+		},
+		{
+			name:               "Unknown recipient",
+			tokenCSRF:          csrfToken,
+			tokenManage:        "MULTIMANAGETOKENAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA",
+			recipient:          "Carol",
+			expectedStatusCode: http.StatusOK,
+			expectedResponse:   []byte("Invalid token"),
+		},
+		{
+			name:               "Invalid CSRF Token",
+			tokenCSRF:          "INVALID",
+			tokenManage:        "Q3NXTLOVHBWY2GWCQVJ4WDPHJ6B7TM2ZK5XRDFAE6OUKIRPLSM4Q",
+			expectedStatusCode: http.StatusForbidden,
+			expectedResponse:   []byte("Forbidden - CSRF token invalid"),
+		},
+		{
+			name:               "Share token is not a management token",
+			tokenCSRF:          csrfToken,
+			tokenManage:        "MUPPH5PDKV7AGCUAAEERL5ARIXICVVGYLRIV365X5XSV3EKISAXQ",
+			expectedStatusCode: http.StatusOK,
+			expectedResponse:   []byte("Invalid token"),
+		},
+		{
+			name:               "Management token is empty",
+			tokenCSRF:          csrfToken,
+			tokenManage:        "",
+			expectedStatusCode: http.StatusOK,
+			expectedResponse:   []byte("Invalid token"),
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("gorilla.csrf.Token", testCase.tokenCSRF)
+			form.Add("token", testCase.tokenManage)
+			form.Add("recipient", testCase.recipient)
+
+			statusCode, _, responseBody := testServ.postForm(t, "/manage/revoke", form)
+
+			if statusCode != testCase.expectedStatusCode {
+				t.Errorf("Expected status %d, received status %d", testCase.expectedStatusCode, statusCode)
+			}
+
+			if !bytes.Contains(responseBody, testCase.expectedResponse) {
+				t.Errorf("Expected body %s to contain %s", responseBody, testCase.expectedResponse)
+			}
+		})
+	}
+}
+
+func TestRequestFlow(t *testing.T) {
+	app := newTestApplication(t)
+
+	testServ := newTestServer(t, app.routes(), false)
+	defer testServ.Close()
+
+	_, _, responseBody := testServ.get(t, "/request")
+	csrfToken := extractCSRFToken(t, responseBody)
+
+	testCases := []struct {
+		name               string
+		method             string
+		urlPath            string
+		form               url.Values
+		expectedStatusCode int
+		expectedResponse   []byte
+	}{
+		{
+			name:               "Create request",
+			method:             "POST",
+			urlPath:            "/request",
+			form:               url.Values{"expires": {"1"}},
+			expectedStatusCode: http.StatusOK,
+			expectedResponse:   []byte("/respond?token=UPLOADTOKENAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA"),
+		},
+		{
+			name:               "Create request with invalid expiry",
+			method:             "POST",
+			urlPath:            "/request",
+			form:               url.Values{"expires": {"100"}},
+			expectedStatusCode: http.StatusOK,
+			expectedResponse:   []byte("This field is invalid."),
+		},
+		{
+			name:               "Open pending request",
+			method:             "GET",
+			urlPath:            "/respond?token=UPLOADTOKENAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA",
+			expectedStatusCode: http.StatusOK,
+			expectedResponse:   []byte("Someone has requested a secret from you"),
+		},
+		{
+			name:               "Open unknown request",
+			method:             "GET",
+			urlPath:            "/respond?token=READTOKENAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA",
+			expectedStatusCode: http.StatusOK,
+			expectedResponse:   []byte("This request link is invalid, has expired or has already been responded to"),
+		},
+		{
+			name:               "Respond to request",
+			method:             "POST",
+			urlPath:            "/respond",
+			form:               url.Values{"token": {"UPLOADTOKENAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA"}, "text": {"hunter2"}},
+			expectedStatusCode: http.StatusOK,
+			expectedResponse:   []byte("Your response has been stored"),
+		},
+		{
+			name:               "Respond with empty text",
+			method:             "POST",
+			urlPath:            "/respond",
+			form:               url.Values{"token": {"UPLOADTOKENAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA"}, "text": {""}},
+			expectedStatusCode: http.StatusOK,
+			expectedResponse:   []byte("This field must not be blank"),
+		},
+		{
+			name:               "Collect unanswered request",
+			method:             "POST",
+			urlPath:            "/collect",
+			form:               url.Values{"token": {"READTOKENAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA"}},
+			expectedStatusCode: http.StatusOK,
+			expectedResponse:   []byte("Your request hasn&#39;t been responded to yet"),
+		},
+		{
+			name:               "Collect answered request",
+			method:             "POST",
+			urlPath:            "/collect",
+			form:               url.Values{"token": {"FULFILLEDTOKENAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA"}},
+			expectedStatusCode: http.StatusOK,
+			expectedResponse:   []byte("This is an example response to a request!"),
+		},
+		{
+			name:               "Collect with upload token",
+			method:             "POST",
+			urlPath:            "/collect",
+			form:               url.Values{"token": {"UPLOADTOKENAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA"}},
+			expectedStatusCode: http.StatusOK,
+			expectedResponse:   []byte("Invalid token"),
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var statusCode int
+			var responseBody []byte
+
+			if testCase.method == "GET" {
+				statusCode, _, responseBody = testServ.get(t, testCase.urlPath)
+			} else {
+				testCase.form.Add("gorilla.csrf.Token", csrfToken)
+				testCase.form.Add("g-recaptcha-response", "this-value-doesnt-matter-with-test-key")
+				statusCode, _, responseBody = testServ.postForm(t, testCase.urlPath, testCase.form)
+			}
+
+			if statusCode != testCase.expectedStatusCode {
+				t.Errorf("Expected status %d, received status %d", testCase.expectedStatusCode, statusCode)
+			}
+
+			if !bytes.Contains(responseBody, testCase.expectedResponse) {
+				t.Errorf("Expected body %s to contain %s", responseBody, testCase.expectedResponse)
+			}
+		})
+	}
+}
+
 func TestAbout(t *testing.T) {
 	app := newTestApplication(t)
 
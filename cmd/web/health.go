@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/matthewlmitchell/tempshare/pkg/recaptcha"
+)
+
+const (
+	readinessTimeout    = 2 * time.Second  // Timeout of each readiness check
+	captchaPingInterval = 30 * time.Second // How long the result of pinging the reCAPTCHA API is reused for
+	shutdownDrainDelay  = 5 * time.Second  // How long /readyz fails before the server stops accepting connections
+)
+
+var errShuttingDown = errors.New("server is shutting down")
+
+// health tracks the state reported by /readyz
+type health struct {
+	shuttingDown atomic.Bool
+
+	mu             sync.Mutex
+	captchaChecked time.Time
+	captchaErr     error
+}
+
+// healthz reports that the process is alive and able to serve requests
+func (app *application) healthz(w http.ResponseWriter, r *http.Request) {
+	app.writeHealth(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// readyz reports whether the server should receive traffic: the database must be reachable,
+// the templates loaded, and the reCAPTCHA API reachable (unless that check is disabled).
+// It fails as soon as a graceful shutdown begins. The reasons for failed checks are logged
+// rather than returned, since they may describe the deployment.
+func (app *application) readyz(w http.ResponseWriter, r *http.Request) {
+
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	checks := map[string]error{
+		"shutdown":  nil,
+		"database":  app.database.PingContext(ctx),
+		"templates": nil,
+		"captcha":   nil,
+	}
+
+	if app.health.shuttingDown.Load() {
+		checks["shutdown"] = errShuttingDown
+	}
+
+	if app.ui == nil || len(app.ui.templates) == 0 {
+		checks["templates"] = errors.New("no templates loaded")
+	}
+
+	if app.serverConfig.captchaCheck {
+		checks["captcha"] = app.pingCaptcha(ctx)
+	}
+
+	status := http.StatusOK
+	results := map[string]string{}
+
+	for name, err := range checks {
+		switch {
+		case err != nil:
+			status = http.StatusServiceUnavailable
+			results[name] = "failing"
+			app.loggerFrom(r.Context()).Warn("readiness check failed", "check", name, "error", err)
+		case name == "captcha" && !app.serverConfig.captchaCheck:
+			results[name] = "disabled"
+		default:
+			results[name] = "ok"
+		}
+	}
+
+	results["status"] = "ok"
+	if status != http.StatusOK {
+		results["status"] = "failing"
+	}
+
+	app.writeHealth(w, status, results)
+}
+
+// pingCaptcha checks that the reCAPTCHA API is reachable. The result is reused for
+// captchaPingInterval, so that frequent probes don't each make a request to Google.
+func (app *application) pingCaptcha(ctx context.Context) error {
+
+	app.health.mu.Lock()
+	defer app.health.mu.Unlock()
+
+	if time.Since(app.health.captchaChecked) < captchaPingInterval {
+		return app.health.captchaErr
+	}
+
+	app.health.captchaErr = recaptcha.Ping(ctx, app.httpsClient)
+	app.health.captchaChecked = time.Now()
+
+	return app.health.captchaErr
+}
+
+func (app *application) writeHealth(w http.ResponseWriter, status int, body map[string]string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+
+	json.NewEncoder(w).Encode(body)
+}
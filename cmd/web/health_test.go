@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+// mockDatabase stands in for the *sql.DB pinged by /readyz
+type mockDatabase struct {
+	err error
+}
+
+func (db *mockDatabase) PingContext(ctx context.Context) error {
+	return db.err
+}
+
+func TestHealth(t *testing.T) {
+
+	testCases := []struct {
+		name         string
+		urlPath      string
+		databaseErr  error
+		shuttingDown bool
+		expectedCode int
+		expectedBody []byte
+	}{
+		{"Alive", "/healthz", nil, false, http.StatusOK, []byte(`"status":"ok"`)},
+		{"Alive while shutting down", "/healthz", nil, true, http.StatusOK, []byte(`"status":"ok"`)},
+		{"Ready", "/readyz", nil, false, http.StatusOK, []byte(`"captcha":"disabled"`)},
+		{"Database unreachable", "/readyz", errors.New("connection refused"), false, http.StatusServiceUnavailable, []byte(`"database":"failing"`)},
+		{"Shutting down", "/readyz", nil, true, http.StatusServiceUnavailable, []byte(`"shutdown":"failing"`)},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			app.database = &mockDatabase{err: testCase.databaseErr}
+			app.health.shuttingDown.Store(testCase.shuttingDown)
+
+			testServ := newTestServer(t, app.routes(), false)
+			defer testServ.Close()
+
+			statusCode, _, responseBody := testServ.get(t, testCase.urlPath)
+
+			if statusCode != testCase.expectedCode {
+				t.Errorf("Expected status %d, received %d", testCase.expectedCode, statusCode)
+			}
+
+			if !bytes.Contains(responseBody, testCase.expectedBody) {
+				t.Errorf("Expected %s in response body, received %s", testCase.expectedBody, responseBody)
+			}
+		})
+	}
+}
@@ -2,16 +2,25 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
-	"os"
+	"net/url"
 	"runtime/debug"
+	"strings"
 	"time"
 
 	"github.com/gorilla/csrf"
+	"github.com/matthewlmitchell/tempshare/pkg/forms"
+	"github.com/matthewlmitchell/tempshare/pkg/i18n"
+	"github.com/matthewlmitchell/tempshare/pkg/logging"
+	"github.com/matthewlmitchell/tempshare/pkg/models"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func (app *application) addDefaultData(tmplData *templateData, r *http.Request) *templateData {
+func (app *application) addDefaultData(tmplData *templateData, r *http.Request, locales *i18n.Bundle) *templateData {
 
 	if tmplData == nil {
 		tmplData = &templateData{}
@@ -22,48 +31,73 @@ func (app *application) addDefaultData(tmplData *templateData, r *http.Request)
 	if app.serverConfig.env == "testing" {
 		tmplData.SiteKey = "6LeIxAcTAAAAAJcZVRqyHh71UMIEGNQ_MXjiZKhI"
 	} else {
-		tmplData.SiteKey = os.Getenv("TEMPSHARE_reCAPTCHA_PUBLIC")
+		tmplData.SiteKey = app.serverConfig.reCAPTCHA.siteKey
 	}
 
+	tmplData.MailEnabled = app.mailer != nil
 	tmplData.CSRFToken = csrf.Token(r)
+	tmplData.CSPNonce = cspNonce(r)
 	tmplData.CurrentYear = time.Now().Year()
+	tmplData.Locale = locale(r)
+	tmplData.Locales = locales.Catalogs()
 	tmplData.Flash = app.session.PopString(r, "flash")
+	tmplData.Admin = signedInAdmin(r)
 
 	return tmplData
 }
 
 func (app *application) render(w http.ResponseWriter, r *http.Request, tmplName string, tmplData *templateData) {
 
-	templateParsed, ok := app.templateCache[tmplName]
+	cache, err := app.currentUI()
+	if err != nil {
+		app.templateError(w, r, tmplName, err)
+		return
+	}
+
+	// Templates are parsed for each locale, with the text and dates in them translated
+	templateCache, ok := cache.templates[locale(r)]
+	if !ok {
+		templateCache = cache.templates[i18n.DefaultLocale]
+	}
+
+	templateParsed, ok := templateCache[tmplName]
 	if !ok {
-		app.serverError(w, fmt.Errorf("the template %s does not exist", tmplName))
+		app.serverError(w, r, fmt.Errorf("the template %s does not exist", tmplName))
 		return
 	}
 
 	templateBuffer := new(bytes.Buffer)
 
-	err := templateParsed.Execute(templateBuffer, app.addDefaultData(tmplData, r))
+	err = templateParsed.Execute(templateBuffer, app.addDefaultData(tmplData, r, cache.locales))
 	if err != nil {
-		app.serverError(w, err)
+		app.templateError(w, r, tmplName, err)
 		return
 	}
 
 	templateBuffer.WriteTo(w)
 }
 
-// serverError() writes the stack trace and error message to app.errorLog
+// serverError() logs the error message and stack trace, along with the ID of the request,
 // then forwards the client to a code 500 error page
-func (app *application) serverError(w http.ResponseWriter, err error) {
-	trace := fmt.Sprintf("%s\n%s", err.Error(), debug.Stack())
-
-	// Setting the call depth to 2 will print starting from the previous step of the trace,
-	// since the last step in the trace will be the function that is printing the trace itself.
-	app.errorLog.Output(2, trace)
+func (app *application) serverError(w http.ResponseWriter, r *http.Request, err error) {
+	app.loggerFrom(r.Context()).Error("server error", "error", err, "trace", string(debug.Stack()))
 
 	errorMessage := "The server encountered a problem and could not process your request."
 	http.Error(w, errorMessage, http.StatusInternalServerError)
 }
 
+// loggerFrom returns app.logger with the ID of the request that ctx belongs to attached,
+// and the ID of its trace if it is being traced
+func (app *application) loggerFrom(ctx context.Context) *slog.Logger {
+	logger := logging.FromContext(ctx, app.logger)
+
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		logger = logger.With("trace_id", spanContext.TraceID().String())
+	}
+
+	return logger
+}
+
 // clientError() responds to the client via an http responsewriter with an http error status code
 func (app *application) clientError(w http.ResponseWriter, statusCode int) {
 	http.Error(w, http.StatusText(statusCode), statusCode)
@@ -71,16 +105,130 @@ func (app *application) clientError(w http.ResponseWriter, statusCode int) {
 
 // runInBackground() accepts a function and runs it inside of a new goroutine
 // while waiting to detect any panics. If a panic is detected in the goroutine,
-// automatically recover and print the necessary trace to our app.errorLog
+// automatically recover and log the necessary trace
 func (app *application) runInBackground(fn func()) {
 	go func() {
 
 		defer func() {
 			if err := recover(); err != nil {
-				app.errorLog.Printf("%s Trace: %s", err, debug.Stack())
+				app.logger.Error("panic in background task", "error", fmt.Sprint(err), "trace", string(debug.Stack()))
 			}
 		}()
 
 		fn()
 	}()
 }
+
+// runEvery calls fn every interval in a new goroutine until ctx is done. A panic in fn is
+// recovered and logged like in runInBackground, but only ends that call, so that the loop
+// keeps running. The goroutine is counted in app.background, which main waits for.
+func (app *application) runEvery(ctx context.Context, name string, interval time.Duration, fn func()) {
+
+	app.background.Add(1)
+
+	go func() {
+		defer app.background.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				app.runRecovered(name, fn)
+			}
+		}
+	}()
+}
+
+// runRecovered calls fn, recovering and logging a panic in it
+func (app *application) runRecovered(name string, fn func()) {
+
+	defer func() {
+		if err := recover(); err != nil {
+			app.logger.Error("panic in background loop", "loop", name, "error", fmt.Sprint(err), "trace", string(debug.Stack()))
+		}
+	}()
+
+	fn()
+}
+
+// maxMultipartMemory is how much of a multipart form is kept in memory, the rest of its files
+// are stored in temporary files
+const maxMultipartMemory = 32 << 10
+
+// parseMultipartForm parses the body of a form which may upload files, and so is sent either
+// as multipart/form-data, or URL encoded if the client doesn't upload any
+func parseMultipartForm(r *http.Request) (*forms.Form, error) {
+
+	err := r.ParseMultipartForm(maxMultipartMemory)
+	if errors.Is(err, http.ErrNotMultipart) {
+		return forms.New(r.PostForm), nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return forms.NewMultipart(r.MultipartForm), nil
+}
+
+// extractToken returns the TempShare token from a line of user input, which may either be
+// the token itself or a whole link containing it as the "token" query parameter
+func extractToken(line string) string {
+	line = strings.TrimSpace(line)
+
+	if strings.Contains(line, "token=") {
+		if parsedURL, err := url.Parse(line); err == nil {
+			return parsedURL.Query().Get("token")
+		}
+	}
+
+	return line
+}
+
+// maxRecipients is the most recipients a single TempShare can be sent to
+const maxRecipients = 10
+
+// parseRecipients splits the "recipients" create form field into one name per line,
+// ignoring blank lines and surrounding whitespace
+func parseRecipients(value string) []string {
+	recipients := []string{}
+	for _, line := range strings.Split(value, "\n") {
+		if name := strings.TrimSpace(line); name != "" {
+			recipients = append(recipients, name)
+		}
+	}
+
+	return recipients
+}
+
+// unavailableMessage explains to a recipient why a TempShare can't be viewed,
+// so they know whether to ask for a new link
+func unavailableMessage(err error) string {
+	switch {
+	case errors.Is(err, models.ErrExpired):
+		return "This TempShare has expired. Ask the sender for a new link."
+	case errors.Is(err, models.ErrViewLimitReached):
+		return "This TempShare has already been opened as many times as the sender allowed. If it wasn't you who opened it, let the sender know."
+	case errors.Is(err, models.ErrRevoked):
+		return "This TempShare was revoked by the sender. Ask them for a new link."
+	}
+
+	return "Invalid token"
+}
+
+// unavailablePartMessage is unavailableMessage for the parts of a split secret, which can't
+// say which of the parts couldn't be opened
+func unavailablePartMessage(err error) string {
+	switch {
+	case errors.Is(err, models.ErrExpired):
+		return "One or more of the links have expired. Ask the sender for new links."
+	case errors.Is(err, models.ErrViewLimitReached):
+		return "One or more of the links have already been opened as many times as the sender allowed. If it wasn't you who opened them, let the sender know."
+	case errors.Is(err, models.ErrRevoked):
+		return "One or more of the links were revoked by the sender. Ask them for new links."
+	}
+
+	return "One or more of the links are invalid"
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunEvery(t *testing.T) {
+
+	app := newTestApplication(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Every call panics, which must not stop the loop
+	var calls atomic.Int32
+	app.runEvery(ctx, "test", time.Millisecond, func() {
+		calls.Add(1)
+		panic("failed")
+	})
+
+	deadline := time.Now().Add(time.Second)
+	for calls.Load() < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the loop to keep running after a panic, received %d calls", calls.Load())
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// The loop stops once ctx is done
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		app.background.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the loop to stop once its context is done")
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/securecookie"
+	"github.com/matthewlmitchell/tempshare/pkg/keyring"
+)
+
+const (
+	csrfCookieName      = "_gorilla_csrf"
+	adminCSRFCookieName = "_admin_csrf"
+	csrfMaxAge          = 12 * 60 * 60 // The default of gorilla/csrf, in seconds
+)
+
+// loadKeys returns the keys that session and CSRF cookies are signed with, newest first.
+// They are derived from the keyring if one is configured, so that every replica (and restart)
+// shares them. Otherwise the secret and csrf-key settings are used, or random keys if unset,
+// which config.validate only allows outside production.
+func loadKeys(cfg *config) (sessionKeys [][]byte, csrfKeys [][]byte, err error) {
+
+	if cfg.keyring != "" {
+		ring, err := keyring.Load(cfg.keyring)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return ring.Derive("session"), ring.Derive("csrf"), nil
+	}
+
+	sessionKey := []byte(cfg.sessionSecret)
+	if len(sessionKey) == 0 {
+		sessionKey = securecookie.GenerateRandomKey(keyring.KeySize)
+	}
+
+	csrfKey := []byte(cfg.csrfKey)
+	if len(csrfKey) == 0 {
+		csrfKey = securecookie.GenerateRandomKey(keyring.KeySize)
+	}
+
+	return [][]byte{sessionKey}, [][]byte{csrfKey}, nil
+}
+
+// rotateKeyring implements "tempshare keyring rotate": it adds a new key to the keyring file
+// (creating it if it doesn't exist) and discards the oldest keys beyond -keep. Servers sign
+// with the new key once restarted, and still accept cookies signed with the older keys.
+func rotateKeyring(args []string, stdout io.Writer, stderr io.Writer) int {
+
+	flags := flag.NewFlagSet("keyring rotate", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	defaultPath, _ := lookupEnv("keyring")
+	path := flags.String("keyring", defaultPath, "Keyring file to rotate")
+	keep := flags.Int("keep", 3, "Number of keys to keep, including the new one")
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if *path == "" {
+		fmt.Fprintln(stderr, "-keyring is required")
+		return 2
+	}
+
+	ring, err := keyring.Load(*path)
+	if errors.Is(err, fs.ErrNotExist) {
+		ring = &keyring.Keyring{}
+	} else if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	if err := ring.Rotate(time.Now(), *keep); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	if err := ring.Save(*path); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	fmt.Fprintf(stdout, "added key %s to %s, which now holds %d keys\n", ring.Keys[0].ID, *path, len(ring.Keys))
+	return 0
+}
+
+// resignCSRFCookie re-signs the CSRF cookie cookieName signed with an older key of app.csrfKeys
+// with the newest one, since gorilla/csrf only verifies cookies with a single key. The token in
+// the cookie is unchanged, so the forms already rendered with it can still be submitted.
+func (app *application) resignCSRFCookie(cookieName string, next http.Handler) http.Handler {
+
+	if len(app.csrfKeys) < 2 {
+		return next
+	}
+
+	// The cookie is encoded the same way as gorilla/csrf encodes it
+	codecs := make([]securecookie.Codec, len(app.csrfKeys))
+	for i, key := range app.csrfKeys {
+		codec := securecookie.New(key, nil)
+		codec.SetSerializer(securecookie.JSONEncoder{})
+		codec.MaxAge(csrfMaxAge)
+		codecs[i] = codec
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		cookie, err := r.Cookie(cookieName)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var token []byte
+		if codecs[0].Decode(cookieName, cookie.Value, &token) == nil ||
+			securecookie.DecodeMulti(cookieName, cookie.Value, &token, codecs[1:]...) != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		encoded, err := codecs[0].Encode(cookieName, token)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Replace the cookie both in the request, for gorilla/csrf, and in the browser
+		cookies := r.Cookies()
+		r = r.Clone(r.Context())
+		r.Header.Del("Cookie")
+
+		for _, requestCookie := range cookies {
+			if requestCookie.Name == cookieName {
+				requestCookie.Value = encoded
+			}
+			r.AddCookie(requestCookie)
+		}
+
+		http.SetCookie(w, &http.Cookie{
+			Name:     cookieName,
+			Value:    encoded,
+			Path:     "/",
+			MaxAge:   csrfMaxAge,
+			Expires:  time.Now().Add(csrfMaxAge * time.Second),
+			HttpOnly: true,
+			Secure:   isHTTPS(r),
+		})
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/gorilla/securecookie"
+	"github.com/matthewlmitchell/tempshare/pkg/keyring"
+)
+
+func TestResignCSRFCookie(t *testing.T) {
+
+	newKey := []byte("0123456789abcdef0123456789abcdef")
+	oldKey := []byte("fedcba9876543210fedcba9876543210")
+	unknownKey := []byte("00000000000000000000000000000000")
+	token := []byte("the real CSRF token")
+
+	app := newTestApplication(t)
+	app.csrfKeys = [][]byte{newKey, oldKey}
+
+	encode := func(key []byte) string {
+		codec := securecookie.New(key, nil)
+		codec.SetSerializer(securecookie.JSONEncoder{})
+
+		encoded, err := codec.Encode(csrfCookieName, token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return encoded
+	}
+
+	testCases := []struct {
+		name           string
+		cookieKey      []byte
+		expectResigned bool
+	}{
+		{"Signed with the newest key", newKey, false},
+		{"Signed with an older key", oldKey, true},
+		{"Signed with an unknown key", unknownKey, false},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+
+			cookieValue := encode(testCase.cookieKey)
+
+			var receivedValue string
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				cookie, err := r.Cookie(csrfCookieName)
+				if err != nil {
+					t.Fatal(err)
+				}
+				receivedValue = cookie.Value
+			})
+
+			responseRecorder := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodPost, "/create", nil)
+			request.AddCookie(&http.Cookie{Name: "session", Value: "unchanged"})
+			request.AddCookie(&http.Cookie{Name: csrfCookieName, Value: cookieValue})
+
+			app.resignCSRFCookie(csrfCookieName, next).ServeHTTP(responseRecorder, request)
+
+			resigned := receivedValue != cookieValue
+			if resigned != testCase.expectResigned {
+				t.Fatalf("Expected the cookie to be re-signed: %v, received %v", testCase.expectResigned, resigned)
+			}
+
+			if !testCase.expectResigned {
+				return
+			}
+
+			// The re-signed cookie must hold the same token, signed with the newest key
+			codec := securecookie.New(newKey, nil)
+			codec.SetSerializer(securecookie.JSONEncoder{})
+
+			var decoded []byte
+			if err := codec.Decode(csrfCookieName, receivedValue, &decoded); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(decoded, token) {
+				t.Errorf("Expected token %s, received %s", token, decoded)
+			}
+
+			if setCookie := responseRecorder.Header().Get("Set-Cookie"); !bytes.Contains([]byte(setCookie), []byte(receivedValue)) {
+				t.Errorf("Expected the re-signed cookie to be sent to the browser, received %q", setCookie)
+			}
+		})
+	}
+}
+
+func TestRotateKeyring(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "keyring.json")
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+
+	for i := 0; i < 3; i++ {
+		if status := rotateKeyring([]string{"-keyring", path, "-keep", "2"}, stdout, stderr); status != 0 {
+			t.Fatalf("Expected exit status 0, received %d: %s", status, stderr)
+		}
+	}
+
+	ring, err := keyring.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ring.Keys) != 2 {
+		t.Fatalf("Expected 2 keys, received %d", len(ring.Keys))
+	}
+
+	sessionKeys, csrfKeys, err := loadKeys(&config{keyring: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessionKeys) != 2 || len(csrfKeys) != 2 {
+		t.Errorf("Expected 2 session and CSRF keys, received %d and %d", len(sessionKeys), len(csrfKeys))
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+	"golang.org/x/net/http2"
+)
+
+const (
+	maxConcurrentStreams = 250     // Streams a client may have open at once over HTTP/2 or HTTP/3
+	maxReadFrameSize     = 1 << 20 // Largest HTTP/2 frame accepted, in bytes
+)
+
+// configureHTTP2 enables HTTP/2 on srv, which must serve TLS, with limits on the
+// streams each connection may open
+func configureHTTP2(srv *http.Server) error {
+	return http2.ConfigureServer(srv, &http2.Server{
+		MaxConcurrentStreams: maxConcurrentStreams,
+		MaxReadFrameSize:     maxReadFrameSize,
+		IdleTimeout:          srv.IdleTimeout,
+	})
+}
+
+// newHTTP3Server returns a server for HTTP/3 over QUIC, on the UDP port with the same
+// number as the TCP port of the main server, with the same TLS config and handler
+func newHTTP3Server(addr string, tlsConfig *tls.Config, handler http.Handler, maxHeaderBytes int) *http3.Server {
+	return &http3.Server{
+		Addr:           addr,
+		TLSConfig:      http3.ConfigureTLSConfig(tlsConfig),
+		Handler:        handler,
+		MaxHeaderBytes: maxHeaderBytes,
+		QuicConfig: &quic.Config{
+			MaxIncomingStreams: maxConcurrentStreams,
+			MaxIdleTimeout:     time.Minute,
+		},
+	}
+}
+
+// advertiseHTTP3 adds an Alt-Svc header to responses, telling clients that they can switch
+// to HTTP/3. It stops once a graceful shutdown begins, so that clients fall back to the
+// connections which are drained rather than the QUIC ones which are closed.
+func (app *application) advertiseHTTP3(h3 *http3.Server, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.health.shuttingDown.Load() {
+			h3.SetQuicHeaders(w.Header())
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// newRedirectServer returns a plain HTTP server which permanently redirects every request
+// to the same URL over HTTPS, on httpsPort
+func newRedirectServer(addr string, httpsPort int) *http.Server {
+	return &http.Server{
+		Addr:         addr,
+		Handler:      redirectToHTTPS(httpsPort),
+		IdleTimeout:  time.Minute,
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 10 * time.Second,
+	}
+}
+
+func redirectToHTTPS(httpsPort int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		host := r.Host
+		if hostname, _, err := net.SplitHostPort(host); err == nil {
+			host = hostname
+		}
+		host = strings.Trim(host, "[]")
+
+		if host == "" {
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+
+		if httpsPort != 443 {
+			host = net.JoinHostPort(host, strconv.Itoa(httpsPort))
+		} else if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+
+		target := url.URL{
+			Scheme:   "https",
+			Host:     host,
+			Path:     r.URL.Path,
+			RawPath:  r.URL.RawPath,
+			RawQuery: r.URL.RawQuery,
+		}
+
+		http.Redirect(w, r, target.String(), http.StatusPermanentRedirect)
+	})
+}
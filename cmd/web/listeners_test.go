@@ -0,0 +1,145 @@
+package main
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/quic-go/quic-go/http3"
+)
+
+func TestRedirectToHTTPS(t *testing.T) {
+
+	testCases := []struct {
+		name             string
+		httpsPort        int
+		host             string
+		target           string
+		expectedCode     int
+		expectedLocation string
+	}{
+		{"Default port", 443, "tempshare.example.com", "/view?token=ABC", http.StatusPermanentRedirect, "https://tempshare.example.com/view?token=ABC"},
+		{"Host with a port", 443, "tempshare.example.com:80", "/", http.StatusPermanentRedirect, "https://tempshare.example.com/"},
+		{"Other HTTPS port", 4000, "localhost:8080", "/create", http.StatusPermanentRedirect, "https://localhost:4000/create"},
+		{"IPv6 host", 443, "[::1]:80", "/", http.StatusPermanentRedirect, "https://[::1]/"},
+		{"Missing host", 443, "", "/", http.StatusBadRequest, ""},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, testCase.target, nil)
+			request.Host = testCase.host
+
+			responseRecorder := httptest.NewRecorder()
+			redirectToHTTPS(testCase.httpsPort).ServeHTTP(responseRecorder, request)
+
+			if responseRecorder.Code != testCase.expectedCode {
+				t.Errorf("Expected status %d, received %d", testCase.expectedCode, responseRecorder.Code)
+			}
+
+			if location := responseRecorder.Header().Get("Location"); location != testCase.expectedLocation {
+				t.Errorf("Expected location %q, received %q", testCase.expectedLocation, location)
+			}
+		})
+	}
+}
+
+func TestHTTP2(t *testing.T) {
+
+	certPath, keyPath := writeTestCertificate(t, t.TempDir(), "localhost")
+
+	reloader, err := newCertReloader(certPath, keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Config.TLSConfig = newTLSConfig("1.2", reloader)
+	if err := configureHTTP2(server.Config); err != nil {
+		t.Fatal(err)
+	}
+	server.TLS = server.Config.TLSConfig
+	server.StartTLS()
+	defer server.Close()
+
+	client := &http.Client{Transport: &http.Transport{
+		ForceAttemptHTTP2: true,
+		TLSClientConfig:   &tls.Config{ServerName: "localhost", InsecureSkipVerify: true},
+	}}
+
+	response, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+
+	if response.ProtoMajor != 2 {
+		t.Errorf("Expected HTTP/2, received %s", response.Proto)
+	}
+}
+
+func TestHTTP3(t *testing.T) {
+
+	app := newTestApplication(t)
+	certPath, keyPath := writeTestCertificate(t, t.TempDir(), "localhost")
+
+	reloader, err := newCertReloader(certPath, keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("UDP unavailable: %v", err)
+	}
+	port := conn.LocalAddr().(*net.UDPAddr).Port
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	h3Srv := newHTTP3Server("127.0.0.1:"+strconv.Itoa(port), newTLSConfig("1.2", reloader), handler, 0)
+
+	go h3Srv.Serve(conn)
+	defer h3Srv.Close()
+
+	roundTripper := &http3.RoundTripper{TLSClientConfig: &tls.Config{ServerName: "localhost", InsecureSkipVerify: true}}
+	defer roundTripper.Close()
+
+	client := &http.Client{Transport: roundTripper, Timeout: 5 * time.Second}
+
+	response, err := client.Get("https://127.0.0.1:" + strconv.Itoa(port) + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+
+	if response.ProtoMajor != 3 {
+		t.Errorf("Expected HTTP/3, received %s", response.Proto)
+	}
+
+	testCases := []struct {
+		name         string
+		shuttingDown bool
+		expectAltSvc bool
+	}{
+		{"Serving", false, true},
+		{"Shutting down", true, false},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			app.health.shuttingDown.Store(testCase.shuttingDown)
+
+			responseRecorder := httptest.NewRecorder()
+			app.advertiseHTTP3(h3Srv, handler).ServeHTTP(responseRecorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			altSvc := responseRecorder.Header().Get("Alt-Svc")
+			if advertised := strings.Contains(altSvc, `h3=":`+strconv.Itoa(port)+`"`); advertised != testCase.expectAltSvc {
+				t.Errorf("Expected Alt-Svc: %v, received %q", testCase.expectAltSvc, altSvc)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/matthewlmitchell/tempshare/pkg/i18n"
+)
+
+// localize picks the locale that the request is answered in: the one chosen with the "lang"
+// query parameter (e.g. from the links in the footer), which is remembered in the session,
+// or else the best match for the Accept-Language header of the browser
+func (app *application) localize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		locales := app.locales()
+
+		if lang := r.URL.Query().Get("lang"); lang != "" {
+			if catalog := locales.Catalog(lang); catalog != nil {
+				app.session.Put(r, "locale", catalog.Locale())
+			}
+		}
+
+		catalog := locales.Match(app.session.GetString(r, "locale"), r.Header.Get("Accept-Language"))
+
+		w.Header().Set("Content-Language", catalog.Locale())
+		w.Header().Add("Vary", "Accept-Language")
+
+		ctx := context.WithValue(r.Context(), contextKeyLocale, catalog.Locale())
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// locales returns the message catalogs, reloaded first in development if a file has changed.
+// If they can't be reloaded, those loaded at startup are used, and the error is shown once
+// the page is rendered.
+func (app *application) locales() *i18n.Bundle {
+
+	cache, err := app.currentUI()
+	if err != nil {
+		return app.ui.locales
+	}
+
+	return cache.locales
+}
+
+// locale returns the locale picked for the request by localize
+func locale(r *http.Request) string {
+
+	if locale, ok := r.Context().Value(contextKeyLocale).(string); ok {
+		return locale
+	}
+
+	return i18n.DefaultLocale
+}
+
+// catalog returns the message catalog of the locale picked for the request, for translating
+// messages such as flashes, e.g. app.catalog(r).T("This link has %d uses remaining.", 2)
+func (app *application) catalog(r *http.Request) *i18n.Catalog {
+
+	locales := app.locales()
+
+	if catalog := locales.Catalog(locale(r)); catalog != nil {
+		return catalog
+	}
+
+	return locales.Default()
+}
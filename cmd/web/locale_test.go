@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matthewlmitchell/tempshare/pkg/models"
+)
+
+func TestLocalize(t *testing.T) {
+	app := newTestApplication(t)
+	testServ := newTestServer(t, app.routes(), false)
+	defer testServ.Close()
+
+	tests := []struct {
+		name           string
+		urlPath        string
+		acceptLanguage string
+		wantLanguage   string
+		wantBody       string
+	}{
+		{"No preference", "/about", "", "en", "About Us"},
+		{"Accept-Language", "/about", "de-DE,de;q=0.9", "de", "Über uns"},
+		{"Unavailable locale", "/about", "fr-FR", "en", "About Us"},
+		{"Override", "/about?lang=es", "de-DE,de;q=0.9", "es", "Acerca de nosotros"},
+		{"Override is remembered", "/about", "de-DE,de;q=0.9", "es", "Acerca de nosotros"},
+		{"Unavailable override", "/about?lang=fr", "de-DE,de;q=0.9", "es", "Acerca de nosotros"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			request, err := http.NewRequestWithContext(ctx, http.MethodGet, testServ.URL+tt.urlPath, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			request.Header.Set("Accept-Language", tt.acceptLanguage)
+
+			response, err := testServ.Client().Do(request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer response.Body.Close()
+
+			body, err := io.ReadAll(response.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if language := response.Header.Get("Content-Language"); language != tt.wantLanguage {
+				t.Errorf("Expected Content-Language %s, received %s", tt.wantLanguage, language)
+			}
+
+			if !strings.Contains(string(body), tt.wantBody) {
+				t.Errorf("Expected the body to contain %q", tt.wantBody)
+			}
+
+			if !strings.Contains(string(body), `<html lang="`+tt.wantLanguage+`">`) {
+				t.Errorf("Expected the lang attribute to be %s", tt.wantLanguage)
+			}
+		})
+	}
+}
+
+// Messages are the arguments of {{t "..."}} in templates, and of errors added to forms and
+// translated flashes in Go
+var (
+	templateMessageRX = regexp.MustCompile(`\{\{t "((?:[^"\\]|\\.)*)"`)
+	goMessageRX       = regexp.MustCompile(`(?:Errors\.Addf?\([\w"]+, |\.T\()"((?:[^"\\]|\\.)*)"`)
+)
+
+func TestCatalogsComplete(t *testing.T) {
+	app := newTestApplication(t)
+
+	messages := map[string]bool{}
+	for _, unavailable := range []error{models.ErrExpired, models.ErrViewLimitReached, models.ErrRevoked, nil} {
+		messages[unavailableMessage(unavailable)] = true
+	}
+
+	files, err := filepath.Glob("../../ui/html/*.tmpl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	goFiles, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files = append(files, goFiles...)
+	formsFiles, err := filepath.Glob("../../pkg/forms/*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files = append(files, formsFiles...)
+
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rx := goMessageRX
+		if strings.HasSuffix(file, ".tmpl") {
+			rx = templateMessageRX
+		}
+
+		for _, match := range rx.FindAllStringSubmatch(string(data), -1) {
+			messages[strings.ReplaceAll(match[1], `\n`, "\n")] = true
+		}
+	}
+
+	if len(messages) < 50 {
+		t.Fatalf("Expected to find the messages of the templates and handlers, found %d", len(messages))
+	}
+
+	for _, catalog := range app.ui.locales.Catalogs()[1:] {
+		for message := range messages {
+			if _, ok := catalog.Messages[message]; !ok {
+				t.Errorf("%s: no translation for %q", catalog.Locale(), message)
+			}
+		}
+	}
+}
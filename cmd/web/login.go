@@ -0,0 +1,380 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/matthewlmitchell/tempshare/pkg/auth"
+	"github.com/matthewlmitchell/tempshare/pkg/forms"
+	"github.com/matthewlmitchell/tempshare/pkg/models"
+	"github.com/matthewlmitchell/tempshare/pkg/models/mysql"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// Signing in to the admin pages takes two steps: the password, after which the ID of the admin
+// is kept in the session as pending, and then a TOTP code, after which it is kept as signed in.
+const (
+	sessionKeyAdmin        = "adminID"
+	sessionKeyGeneration   = "adminSessionGeneration" // See models.Admin.SessionGeneration
+	sessionKeyPendingAdmin = "pendingAdminID"
+	sessionKeyPendingSince = "pendingAdminSince" // Unix time, since the session can't hold a time.Time
+
+	// How long a pending admin has to enter a TOTP code before they have to enter their password
+	// again. Incorrect codes are counted by app.admins, see models.MaxTOTPAttempts.
+	pendingAdminTimeout = 5 * time.Minute
+
+	minAdminPasswordLength = 12
+)
+
+var usernameRX = regexp.MustCompile(`^[a-zA-Z0-9._-]{1,64}$`)
+
+// golangcollege/sessions always names its cookie "session", which would be shared with the
+// public pages served from the same host, since cookies don't tell ports apart. The cookie
+// of app.adminSession is renamed to adminSessionCookieName on its way in and out.
+const (
+	sessionCookieName      = "session"
+	adminSessionCookieName = "admin_session"
+)
+
+// enableAdminSession loads and saves app.adminSession. The admin pages are only served over
+// TLS, so its cookie is always Secure. Like every session, its data is kept in the request
+// context, so the helpers reading app.session (e.g. for the flash message) read it too.
+func (app *application) enableAdminSession(next http.Handler) http.Handler {
+
+	enable := app.adminSession.Enable(next)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		cookies := r.Cookies()
+		r = r.Clone(r.Context())
+		r.Header.Del("Cookie")
+
+		for _, cookie := range cookies {
+			switch cookie.Name {
+			case sessionCookieName:
+				continue
+			case adminSessionCookieName:
+				cookie.Name = sessionCookieName
+			}
+			r.AddCookie(cookie)
+		}
+
+		enable.ServeHTTP(&adminSessionWriter{ResponseWriter: w}, r)
+	})
+}
+
+// adminSessionWriter renames the session cookie set by app.adminSession before the headers are sent
+type adminSessionWriter struct {
+	http.ResponseWriter
+	renamed bool
+}
+
+func (w *adminSessionWriter) renameCookie() {
+
+	if w.renamed {
+		return
+	}
+	w.renamed = true
+
+	setCookies := w.Header()["Set-Cookie"]
+	for i, setCookie := range setCookies {
+		if strings.HasPrefix(setCookie, sessionCookieName+"=") {
+			setCookies[i] = adminSessionCookieName + strings.TrimPrefix(setCookie, sessionCookieName)
+		}
+	}
+}
+
+func (w *adminSessionWriter) WriteHeader(statusCode int) {
+	w.renameCookie()
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *adminSessionWriter) Write(b []byte) (int, error) {
+	w.renameCookie()
+	return w.ResponseWriter.Write(b)
+}
+
+// requireAdmin redirects clients which aren't signed in as an admin to the login page. The
+// account is looked up on every request, so that deleting it, or revoking its sessions when
+// the admin logs out, signs the admin out even if the session cookie is replayed.
+func (app *application) requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		id := app.adminSession.GetInt(r, sessionKeyAdmin)
+		if id == 0 {
+			http.Redirect(w, r, "/admin/login", http.StatusSeeOther)
+			return
+		}
+
+		admin, err := app.admins.Get(r.Context(), id)
+		if err != nil && !errors.Is(err, models.ErrNoRecord) {
+			app.serverError(w, r, err)
+			return
+		}
+
+		if err != nil || admin.SessionGeneration != app.adminSession.GetInt(r, sessionKeyGeneration) {
+			app.adminSession.Remove(r, sessionKeyAdmin)
+			app.adminSession.Remove(r, sessionKeyGeneration)
+			http.Redirect(w, r, "/admin/login", http.StatusSeeOther)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), contextKeyAdmin, admin)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// signedInAdmin returns the admin that requireAdmin found for the request, if any
+func signedInAdmin(r *http.Request) *models.Admin {
+
+	admin, _ := r.Context().Value(contextKeyAdmin).(*models.Admin)
+
+	return admin
+}
+
+func (app *application) adminLoginForm(w http.ResponseWriter, r *http.Request) {
+
+	app.render(w, r, "login.page.tmpl", &templateData{Form: forms.New(nil)})
+}
+
+func (app *application) adminLogin(w http.ResponseWriter, r *http.Request) {
+
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	data := &loginForm{}
+	if err := form.Bind(data); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if !form.Valid() {
+		app.render(w, r, "login.page.tmpl", &templateData{Form: form})
+		return
+	}
+
+	id, err := app.admins.Authenticate(r.Context(), data.Username, data.Password)
+	if errors.Is(err, models.ErrAccountLocked) {
+		app.loggerFrom(r.Context()).Warn("admin login to a locked account", "username", data.Username)
+		form.Errors.Add("generic", "This account is locked after too many incorrect attempts, please try again later")
+		app.render(w, r, "login.page.tmpl", &templateData{Form: form})
+		return
+	} else if errors.Is(err, models.ErrInvalidCredentials) {
+		app.loggerFrom(r.Context()).Warn("admin login failed", "username", data.Username)
+		form.Errors.Add("generic", "Username or password is incorrect")
+		app.render(w, r, "login.page.tmpl", &templateData{Form: form})
+		return
+	} else if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.adminSession.Remove(r, sessionKeyAdmin)
+	app.adminSession.Put(r, sessionKeyPendingAdmin, id)
+	app.adminSession.Put(r, sessionKeyPendingSince, int(time.Now().Unix()))
+
+	http.Redirect(w, r, "/admin/login/totp", http.StatusSeeOther)
+}
+
+// pendingAdmin returns the ID of the admin who entered their password but no TOTP code yet,
+// or 0 if there isn't one or they took too long
+func (app *application) pendingAdmin(r *http.Request) int {
+
+	id := app.adminSession.GetInt(r, sessionKeyPendingAdmin)
+	if id == 0 {
+		return 0
+	}
+
+	since := time.Unix(int64(app.adminSession.GetInt(r, sessionKeyPendingSince)), 0)
+	if time.Since(since) > pendingAdminTimeout {
+		app.clearPendingAdmin(r)
+		return 0
+	}
+
+	return id
+}
+
+func (app *application) clearPendingAdmin(r *http.Request) {
+
+	app.adminSession.Remove(r, sessionKeyPendingAdmin)
+	app.adminSession.Remove(r, sessionKeyPendingSince)
+}
+
+func (app *application) adminTOTPForm(w http.ResponseWriter, r *http.Request) {
+
+	if app.pendingAdmin(r) == 0 {
+		http.Redirect(w, r, "/admin/login", http.StatusSeeOther)
+		return
+	}
+
+	app.render(w, r, "totp.page.tmpl", &templateData{Form: forms.New(nil)})
+}
+
+func (app *application) adminTOTP(w http.ResponseWriter, r *http.Request) {
+
+	id := app.pendingAdmin(r)
+	if id == 0 {
+		app.adminSession.Put(r, "flash", app.catalog(r).T("Your login has expired, please enter your password again."))
+		http.Redirect(w, r, "/admin/login", http.StatusSeeOther)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	data := &totpForm{}
+	if err := form.Bind(data); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if !form.Valid() {
+		app.render(w, r, "totp.page.tmpl", &templateData{Form: form})
+		return
+	}
+
+	err = app.admins.VerifyTOTP(r.Context(), id, data.Code)
+	if errors.Is(err, models.ErrAccountLocked) {
+		app.loggerFrom(r.Context()).Warn("admin account locked after incorrect TOTP codes", "admin_id", id)
+		app.clearPendingAdmin(r)
+		app.adminSession.Put(r, "flash", app.catalog(r).T("Too many incorrect codes. This account is locked for %d minutes.", int(models.TOTPLockout.Minutes())))
+		http.Redirect(w, r, "/admin/login", http.StatusSeeOther)
+		return
+	} else if errors.Is(err, models.ErrInvalidCredentials) {
+		app.loggerFrom(r.Context()).Warn("admin TOTP code rejected", "admin_id", id)
+		form.Errors.Add("code", "This code is incorrect or has already been used")
+		app.render(w, r, "totp.page.tmpl", &templateData{Form: form})
+		return
+	} else if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	admin, err := app.admins.Get(r.Context(), id)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.clearPendingAdmin(r)
+	app.adminSession.Put(r, sessionKeyAdmin, id)
+	app.adminSession.Put(r, sessionKeyGeneration, admin.SessionGeneration)
+	app.loggerFrom(r.Context()).Info("admin logged in", "admin_id", id)
+
+	http.Redirect(w, r, "/admin", http.StatusSeeOther)
+}
+
+// adminLogout signs the admin out of every session, not only this one, since a session cookie
+// can't be revoked by itself
+func (app *application) adminLogout(w http.ResponseWriter, r *http.Request) {
+
+	if err := app.admins.RevokeSessions(r.Context(), signedInAdmin(r).ID); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.adminSession.Remove(r, sessionKeyAdmin)
+	app.adminSession.Remove(r, sessionKeyGeneration)
+	app.adminSession.Put(r, "flash", app.catalog(r).T("You have been logged out."))
+
+	http.Redirect(w, r, "/admin/login", http.StatusSeeOther)
+}
+
+// createAdmin implements "tempshare admin create [flags] <username>": it reads the password
+// of the new admin from stdin, creates the account in the database set by the usual config,
+// and prints the TOTP secret to set up an authenticator app with
+func createAdmin(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+
+	cfg, flags, _, err := loadConfig(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	} else if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+
+	if flags.NArg() != 1 {
+		fmt.Fprintln(stderr, "usage: tempshare admin create [flags] <username>")
+		return 2
+	}
+
+	if cfg.DB.dsn == "" {
+		fmt.Fprintln(stderr, "db-dsn is required")
+		return 2
+	}
+
+	db, err := connectToDatabase(cfg, noop.NewTracerProvider())
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	defer db.Close()
+
+	fmt.Fprint(stderr, "Password: ")
+
+	if err := bootstrapAdmin(context.Background(), &mysql.AdminModel{DB: db}, flags.Arg(0), stdin, stdout); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	return 0
+}
+
+// bootstrapAdmin creates the admin username, with the password on the first line of stdin
+// and a new TOTP secret
+func bootstrapAdmin(ctx context.Context, admins interface {
+	Insert(context.Context, string, string, string) error
+}, username string, stdin io.Reader, stdout io.Writer) error {
+
+	if !usernameRX.MatchString(username) {
+		return errors.New("username must be 1 to 64 letters, digits, dots, dashes or underscores")
+	}
+
+	scanner := bufio.NewScanner(stdin)
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+		return errors.New("no password given on stdin")
+	}
+
+	password := strings.TrimRight(scanner.Text(), "\r")
+	if utf8.RuneCountInString(password) < minAdminPasswordLength {
+		return fmt.Errorf("password must be at least %d characters", minAdminPasswordLength)
+	}
+
+	secret, err := auth.NewTOTPSecret()
+	if err != nil {
+		return err
+	}
+
+	err = admins.Insert(ctx, username, password, secret)
+	if errors.Is(err, models.ErrDuplicateUsername) {
+		return fmt.Errorf("admin %s already exists", username)
+	} else if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "created admin %s\n", username)
+	fmt.Fprintf(stdout, "set up an authenticator app with the TOTP secret %s\n", secret)
+	fmt.Fprintf(stdout, "or with a QR code of %s\n", auth.TOTPURI("TempShare", username, secret))
+
+	return nil
+}
@@ -0,0 +1,393 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matthewlmitchell/tempshare/pkg/auth"
+	"github.com/matthewlmitchell/tempshare/pkg/models"
+	"github.com/matthewlmitchell/tempshare/pkg/models/mock"
+)
+
+// currentTOTP returns the current TOTP code of the mock admin
+func currentTOTP(t *testing.T) string {
+
+	code, err := auth.TOTP(mock.MockAdminTOTPSecret, auth.TOTPStep(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return code
+}
+
+// loginAdmin signs the client of the test server in as the mock admin
+func loginAdmin(t *testing.T, testServ *testServer) {
+
+	_, _, body := testServ.get(t, "/admin/login")
+	statusCode, header, _ := testServ.postForm(t, "/admin/login", url.Values{
+		"username":           {"alice"},
+		"password":           {mock.MockAdminPassword},
+		"gorilla.csrf.Token": {extractCSRFToken(t, body)},
+	})
+	if statusCode != http.StatusSeeOther || header.Get("Location") != "/admin/login/totp" {
+		t.Fatalf("Expected to be asked for a TOTP code, received %d %s", statusCode, header.Get("Location"))
+	}
+
+	_, _, body = testServ.get(t, "/admin/login/totp")
+	statusCode, header, _ = testServ.postForm(t, "/admin/login/totp", url.Values{
+		"code":               {currentTOTP(t)},
+		"gorilla.csrf.Token": {extractCSRFToken(t, body)},
+	})
+	if statusCode != http.StatusSeeOther || header.Get("Location") != "/admin" {
+		t.Fatalf("Expected to be signed in, received %d %s", statusCode, header.Get("Location"))
+	}
+}
+
+func TestAdminLogin(t *testing.T) {
+
+	testCases := []struct {
+		name             string
+		username         string
+		password         string
+		code             string // Sent if the password is accepted
+		expectedCode     int
+		expectedLocation string
+		expectedBody     []byte
+	}{
+		{
+			name:             "Valid credentials",
+			username:         "alice",
+			password:         mock.MockAdminPassword,
+			expectedCode:     http.StatusSeeOther,
+			expectedLocation: "/admin",
+		},
+		{
+			name:         "Incorrect password",
+			username:     "alice",
+			password:     "hunter2",
+			expectedCode: http.StatusOK,
+			expectedBody: []byte("Username or password is incorrect"),
+		},
+		{
+			name:         "Unknown username",
+			username:     "bob",
+			password:     mock.MockAdminPassword,
+			expectedCode: http.StatusOK,
+			expectedBody: []byte("Username or password is incorrect"),
+		},
+		{
+			name:         "Blank password",
+			username:     "alice",
+			expectedCode: http.StatusOK,
+			expectedBody: []byte("This field must not be blank"),
+		},
+		{
+			name:         "Incorrect code",
+			username:     "alice",
+			password:     mock.MockAdminPassword,
+			code:         "000000",
+			expectedCode: http.StatusOK,
+			expectedBody: []byte("This code is incorrect or has already been used"),
+		},
+		{
+			name:         "Malformed code",
+			username:     "alice",
+			password:     mock.MockAdminPassword,
+			code:         "abcdef",
+			expectedCode: http.StatusOK,
+			expectedBody: []byte("This field is invalid."),
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			testServ := newTestServer(t, app.adminRoutes(), false)
+			defer testServ.Close()
+
+			_, _, body := testServ.get(t, "/admin/login")
+			statusCode, header, body := testServ.postForm(t, "/admin/login", url.Values{
+				"username":           {testCase.username},
+				"password":           {testCase.password},
+				"gorilla.csrf.Token": {extractCSRFToken(t, body)},
+			})
+
+			if statusCode == http.StatusSeeOther {
+				code := testCase.code
+				if code == "" {
+					code = currentTOTP(t)
+				}
+
+				_, _, body = testServ.get(t, header.Get("Location"))
+				statusCode, header, body = testServ.postForm(t, "/admin/login/totp", url.Values{
+					"code":               {code},
+					"gorilla.csrf.Token": {extractCSRFToken(t, body)},
+				})
+			}
+
+			if statusCode != testCase.expectedCode {
+				t.Fatalf("Expected status %d, received %d", testCase.expectedCode, statusCode)
+			}
+			if location := header.Get("Location"); location != testCase.expectedLocation {
+				t.Errorf("Expected redirect to %q, received %q", testCase.expectedLocation, location)
+			}
+			if !bytes.Contains(body, testCase.expectedBody) {
+				t.Errorf("Expected %s in response body, received %s", testCase.expectedBody, body)
+			}
+
+			// Only a complete login gives access to the dashboard
+			statusCode, _, _ = testServ.get(t, "/admin")
+			if signedIn := testCase.expectedLocation == "/admin"; signedIn != (statusCode == http.StatusOK) {
+				t.Errorf("Expected signed in to be %t, received status %d for the dashboard", signedIn, statusCode)
+			}
+		})
+	}
+}
+
+func TestAdminTOTPAttempts(t *testing.T) {
+
+	app := newTestApplication(t)
+	testServ := newTestServer(t, app.adminRoutes(), false)
+	defer testServ.Close()
+
+	// The TOTP page can't be used without entering the password first
+	statusCode, header, _ := testServ.get(t, "/admin/login/totp")
+	if statusCode != http.StatusSeeOther || header.Get("Location") != "/admin/login" {
+		t.Fatalf("Expected a redirect to the login page, received %d %s", statusCode, header.Get("Location"))
+	}
+
+	_, _, body := testServ.get(t, "/admin/login")
+	token := extractCSRFToken(t, body)
+	testServ.postForm(t, "/admin/login", url.Values{"username": {"alice"}, "password": {mock.MockAdminPassword}, "gorilla.csrf.Token": {token}})
+
+	// The cookies issued after the password are replayed before every code, which must not
+	// reset the count of incorrect codes
+	serverURL, _ := url.Parse(testServ.URL + "/")
+	cookies := testServ.Client().Jar.Cookies(serverURL)
+
+	for attempt := 1; attempt <= models.MaxTOTPAttempts; attempt++ {
+		testServ.Client().Jar.SetCookies(serverURL, cookies)
+		statusCode, header, body = testServ.postForm(t, "/admin/login/totp", url.Values{"code": {"000000"}, "gorilla.csrf.Token": {token}})
+
+		if attempt < models.MaxTOTPAttempts && (statusCode != http.StatusOK || !bytes.Contains(body, []byte("This code is incorrect"))) {
+			t.Fatalf("Expected attempt %d to be rejected, received %d", attempt, statusCode)
+		}
+	}
+
+	if statusCode != http.StatusSeeOther || header.Get("Location") != "/admin/login" {
+		t.Fatalf("Expected a redirect to the login page, received %d %s", statusCode, header.Get("Location"))
+	}
+
+	// Even the correct code is refused while the account is locked, whichever session it comes from
+	testServ.Client().Jar.SetCookies(serverURL, cookies)
+	statusCode, header, _ = testServ.postForm(t, "/admin/login/totp", url.Values{"code": {currentTOTP(t)}, "gorilla.csrf.Token": {token}})
+	if statusCode != http.StatusSeeOther || header.Get("Location") != "/admin/login" {
+		t.Errorf("Expected a redirect to the login page, received %d %s", statusCode, header.Get("Location"))
+	}
+
+	_, _, body = testServ.get(t, "/admin/login")
+	if !bytes.Contains(body, []byte("This account is locked for 15 minutes.")) {
+		t.Errorf("Expected to be told the account is locked, received %s", body)
+	}
+}
+
+func TestAdminPasswordAttempts(t *testing.T) {
+
+	app := newTestApplication(t)
+	testServ := newTestServer(t, app.adminRoutes(), false)
+	defer testServ.Close()
+
+	_, _, body := testServ.get(t, "/admin/login")
+	token := extractCSRFToken(t, body)
+
+	for attempt := 1; attempt <= models.MaxPasswordAttempts; attempt++ {
+		statusCode, _, body := testServ.postForm(t, "/admin/login", url.Values{"username": {"alice"}, "password": {"incorrect password"}, "gorilla.csrf.Token": {token}})
+
+		expected := []byte("Username or password is incorrect")
+		if attempt == models.MaxPasswordAttempts {
+			expected = []byte("This account is locked after too many incorrect attempts")
+		}
+		if statusCode != http.StatusOK || !bytes.Contains(body, expected) {
+			t.Fatalf("Expected attempt %d to be told %s, received %d", attempt, expected, statusCode)
+		}
+	}
+
+	// Even the correct password is refused while the account is locked
+	statusCode, _, body := testServ.postForm(t, "/admin/login", url.Values{"username": {"alice"}, "password": {mock.MockAdminPassword}, "gorilla.csrf.Token": {token}})
+	if statusCode != http.StatusOK || !bytes.Contains(body, []byte("This account is locked after too many incorrect attempts")) {
+		t.Errorf("Expected to be told the account is locked, received %d", statusCode)
+	}
+}
+
+func TestAdminLoginRateLimit(t *testing.T) {
+
+	app := newTestApplication(t)
+	testServ := newTestServer(t, app.adminRoutes(), false)
+	defer testServ.Close()
+
+	_, _, body := testServ.get(t, "/admin/login")
+	token := extractCSRFToken(t, body)
+
+	// Unknown usernames are never locked, so only the client's address limits their attempts
+	for attempt := 1; attempt <= loginAttemptsPerMinute; attempt++ {
+		statusCode, _, _ := testServ.postForm(t, "/admin/login", url.Values{"username": {"mallory"}, "password": {"incorrect password"}, "gorilla.csrf.Token": {token}})
+		if statusCode != http.StatusOK {
+			t.Fatalf("Expected attempt %d to be allowed, received %d", attempt, statusCode)
+		}
+	}
+
+	statusCode, header, _ := testServ.postForm(t, "/admin/login", url.Values{"username": {"alice"}, "password": {mock.MockAdminPassword}, "gorilla.csrf.Token": {token}})
+	if statusCode != http.StatusTooManyRequests || header.Get("Retry-After") == "" {
+		t.Errorf("Expected status %d with Retry-After, received %d", http.StatusTooManyRequests, statusCode)
+	}
+
+	// The login page itself isn't limited
+	if statusCode, _, _ := testServ.get(t, "/admin/login"); statusCode != http.StatusOK {
+		t.Errorf("Expected status %d, received %d", http.StatusOK, statusCode)
+	}
+}
+
+func TestAdminCookies(t *testing.T) {
+
+	app := newTestApplication(t)
+	testServ := newTestServer(t, app.adminRoutes(), false)
+	defer testServ.Close()
+
+	// A session cookie of the public pages on the same host is neither read nor overwritten
+	serverURL, _ := url.Parse(testServ.URL + "/")
+	publicCookies := []*http.Cookie{{Name: sessionCookieName, Value: "public"}, {Name: csrfCookieName, Value: "public"}}
+	testServ.Client().Jar.SetCookies(serverURL, publicCookies)
+
+	loginAdmin(t, testServ)
+
+	cookies := map[string]string{}
+	for _, cookie := range testServ.Client().Jar.Cookies(serverURL) {
+		cookies[cookie.Name] = cookie.Value
+	}
+
+	for _, publicCookie := range publicCookies {
+		if cookies[publicCookie.Name] != publicCookie.Value {
+			t.Errorf("Expected the %s cookie of the public pages to be left alone, received %q", publicCookie.Name, cookies[publicCookie.Name])
+		}
+	}
+	for _, name := range []string{adminSessionCookieName, adminCSRFCookieName} {
+		if cookies[name] == "" {
+			t.Errorf("Expected a %s cookie, received %v", name, cookies)
+		}
+	}
+}
+
+func TestAdminPagesNeedTLS(t *testing.T) {
+
+	app := newTestApplication(t)
+	app.serverConfig.plainHTTP = true
+
+	testServ := newTestServer(t, app.adminRoutes(), false)
+	defer testServ.Close()
+
+	testCases := []struct {
+		path         string
+		expectedCode int
+	}{
+		{"/admin", http.StatusNotFound},
+		{"/admin/login", http.StatusNotFound},
+		{"/metrics", http.StatusOK},
+		{"/healthz", http.StatusOK},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.path, func(t *testing.T) {
+			statusCode, _, _ := testServ.get(t, testCase.path)
+			if statusCode != testCase.expectedCode {
+				t.Errorf("Expected status %d, received %d", testCase.expectedCode, statusCode)
+			}
+		})
+	}
+}
+
+func TestAdminLogout(t *testing.T) {
+
+	app := newTestApplication(t)
+	testServ := newTestServer(t, app.adminRoutes(), false)
+	defer testServ.Close()
+
+	loginAdmin(t, testServ)
+
+	// The cookies of the signed in session are replayed after logging out
+	serverURL, _ := url.Parse(testServ.URL + "/")
+	cookies := testServ.Client().Jar.Cookies(serverURL)
+
+	statusCode, _, body := testServ.get(t, "/admin")
+	if statusCode != http.StatusOK {
+		t.Fatalf("Expected status %d, received %d", http.StatusOK, statusCode)
+	}
+	if !bytes.Contains(body, []byte("Log out alice")) {
+		t.Errorf("Expected the admin to be shown as signed in, received %s", body)
+	}
+
+	statusCode, header, _ := testServ.postForm(t, "/admin/logout", url.Values{"gorilla.csrf.Token": {extractCSRFToken(t, body)}})
+	if statusCode != http.StatusSeeOther || header.Get("Location") != "/admin/login" {
+		t.Fatalf("Expected a redirect to the login page, received %d %s", statusCode, header.Get("Location"))
+	}
+
+	statusCode, header, _ = testServ.get(t, "/admin")
+	if statusCode != http.StatusSeeOther || header.Get("Location") != "/admin/login" {
+		t.Errorf("Expected a redirect to the login page, received %d %s", statusCode, header.Get("Location"))
+	}
+
+	testServ.Client().Jar.SetCookies(serverURL, cookies)
+	statusCode, header, _ = testServ.get(t, "/admin")
+	if statusCode != http.StatusSeeOther || header.Get("Location") != "/admin/login" {
+		t.Errorf("Expected the replayed session to be signed out, received %d %s", statusCode, header.Get("Location"))
+	}
+
+	// Logging in again starts a session of the new generation
+	loginAdmin(t, testServ)
+	if statusCode, _, _ = testServ.get(t, "/admin"); statusCode != http.StatusOK {
+		t.Errorf("Expected status %d, received %d", http.StatusOK, statusCode)
+	}
+}
+
+func TestBootstrapAdmin(t *testing.T) {
+
+	testCases := []struct {
+		name          string
+		username      string
+		stdin         string
+		expectedError string
+	}{
+		{"Valid", "bob", "correct horse battery staple\n", ""},
+		{"Windows line ending", "bob", "correct horse battery staple\r\n", ""},
+		{"Existing username", "alice", "correct horse battery staple\n", "admin alice already exists"},
+		{"Short password", "bob", "hunter2\n", "password must be at least 12 characters"},
+		{"No password", "bob", "", "no password given on stdin"},
+		{"Invalid username", "bob smith", "correct horse battery staple\n", "username must be"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			stdout := &bytes.Buffer{}
+
+			err := bootstrapAdmin(context.Background(), &mock.AdminModel{}, testCase.username, strings.NewReader(testCase.stdin), stdout)
+
+			if testCase.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), testCase.expectedError) {
+					t.Errorf("Expected error %q, received %v", testCase.expectedError, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(stdout.String(), "otpauth://totp/TempShare:bob?") {
+				t.Errorf("Expected the TOTP URI to be printed, received %s", stdout)
+			}
+		})
+	}
+}
@@ -1,55 +1,160 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"flag"
-	"html/template"
-	"log"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"sync"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/golangcollege/sessions"
-	"github.com/gorilla/securecookie"
+	"github.com/matthewlmitchell/tempshare/pkg/keyring"
+	"github.com/matthewlmitchell/tempshare/pkg/logging"
+	"github.com/matthewlmitchell/tempshare/pkg/mailer"
 	"github.com/matthewlmitchell/tempshare/pkg/models"
 	"github.com/matthewlmitchell/tempshare/pkg/models/mysql"
+	"github.com/matthewlmitchell/tempshare/pkg/notify"
+	"github.com/matthewlmitchell/tempshare/pkg/webhook"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const version = "0.0.0001"
 
 type config struct {
-	port int    // For specifying port for the HTTP server to run on
-	env  string // For launching server in development, staging, or production environment
-	DB   struct {
+	file           string // YAML file the config was read from, if any
+	port           int    // For specifying port for the HTTP server to run on
+	adminAddr      string // Address of the admin listener serving /metrics and /admin, disabled if empty
+	env            string // For launching server in development, staging, or production environment
+	baseURL        string // Scheme and host that share links are formatted with, e.g.: https://tempshare.example.com
+	plainHTTP      bool   // Serve plain HTTP, behind a proxy which terminates TLS
+	unixSocket     string // Unix socket to listen on instead of the port, if set
+	trustedProxies string // Comma separated CIDRs of the proxies whose X-Forwarded-* headers are trusted
+	redirectAddr   string // Address of the plain HTTP listener redirecting to HTTPS, disabled if empty
+	http3          bool   // Serve HTTP/3 over QUIC on the UDP port matching port
+	TLS            struct {
+		certFile   string
+		keyFile    string
+		minVersion string // "1.2" or "1.3"
+	}
+
+	keyring       string // Keyring file that the session and CSRF keys are derived from
+	sessionSecret string // Key of the cookie session store, if keyring isn't set
+	csrfKey       string // Key used to generate CSRF tokens, if keyring isn't set
+	reCAPTCHA     struct {
+		siteKey string
+		secret  string
+	}
+
+	DB struct {
 		dsn                string
 		maxOpenConnections int
 		maxIdleConnections int
 		maxIdleTime        string
 	}
+	SMTP struct {
+		host     string
+		port     int
+		username string
+		password string
+		sender   string
+		insecure bool // Allows sending mail without STARTTLS, e.g. to a relay on localhost
+	}
+	notifyWebhook string // URL that view notifications are POSTed to, disabled if empty
+	webhooksFile  string // JSON file listing the destinations of lifecycle event webhooks
+	captchaCheck  bool   // Whether /readyz requires the reCAPTCHA API to be reachable
+	otlpEndpoint  string // URL of the OTLP/HTTP collector that traces are exported to, disabled if empty
+	uiDir         string // Directory of templates and static files replacing those compiled in, if set
+	rateLimit     int    // Requests a minute allowed from each client IP address, unlimited if 0
 }
 
 type application struct {
-	errorLog      *log.Logger
-	infoLog       *log.Logger
-	session       *sessions.Session
-	serverConfig  config
-	httpsClient   *http.Client
-	templateCache map[string]*template.Template
-	tempShare     interface {
-		New(string, string, string) (*models.TempShare, error)
-		Insert([]byte, string, string, int) error
-		Get(string) (*models.TempShare, error)
-		Update(string) error
+	logger         *slog.Logger
+	metrics        *metrics
+	tracerProvider trace.TracerProvider
+	health         health
+	session        *sessions.Session
+	adminSession   *sessions.Session // Session of the admin pages, in a cookie of its own, see enableAdminSession
+	csrfKeys       [][]byte          // Newest first, the rest only verify cookies signed before a key rotation
+	trustedProxies []*net.IPNet
+	background     sync.WaitGroup // Background loops and the work they started, see runEvery
+	rateLimiter    *rateLimiter   // Nil if requests aren't rate limited
+	loginLimiter   *rateLimiter   // Limits the passwords submitted to the admin login page
+	serverConfig   config
+	httpsClient    *http.Client
+	ui             *uiCache
+	devUI          *devUI // Reloads the templates and static files in development, nil otherwise
+	mailer         *mailer.Queue
+	notifiers      []notify.Notifier
+
+	database interface {
+		PingContext(context.Context) error
+	}
+
+	webhookDestinations []*webhook.Destination
+	webhookClient       *http.Client
+	webhooks            interface {
+		Enqueue(context.Context, string, string, []byte) error
+		Claim(context.Context, int, time.Duration) ([]*models.Delivery, error)
+		Delivered(context.Context, int64) error
+		Retry(context.Context, int64, time.Duration) error
+		Fail(context.Context, int64) error
+	}
+
+	admins interface {
+		Insert(context.Context, string, string, string) error
+		Authenticate(context.Context, string, string) (int, error)
+		VerifyTOTP(context.Context, int, string) error
+		Get(context.Context, int) (*models.Admin, error)
+		RevokeSessions(context.Context, int) error
+	}
+
+	requests interface {
+		New(context.Context, int) (*models.Request, error)
+		Pending(context.Context, string) (*models.Request, error)
+		Fulfil(context.Context, string, string) error
+		Get(context.Context, string) (*models.Request, error)
+	}
+
+	tempShare interface {
+		New(context.Context, string, int, int, string, bool, time.Time) (*models.TempShare, error)
+		Insert(context.Context, []byte, []byte, []byte, string, int, int, string, time.Time) error
+		NewSplit(context.Context, string, int, int, int, int, time.Time) ([]*models.TempShare, error)
+		NewMulti(context.Context, string, int, int, string, []string, time.Time) (string, []*models.TempShare, error)
+		Get(context.Context, string, string) (*models.TempShare, error)
+		Combine(context.Context, []string) (string, []*models.TempShare, error)
+		GetManaged(context.Context, string) (*models.TempShare, error)
+		GetRecipients(context.Context, string) ([]*models.TempShare, error)
+		Update(context.Context, string) error
+		Revoke(context.Context, string) (*models.TempShare, error)
+		RevokeRecipient(context.Context, string, string) (*models.TempShare, error)
+		SweepExpired(context.Context) ([]*models.TempShare, error)
+		AddView(context.Context, []byte, string) error
+		Views(context.Context, []byte) ([]*models.View, error)
+		Stats(context.Context, int) (*models.Stats, error)
 	}
 }
 
-func connectToDatabase(dsn string) (*sql.DB, error) {
-	db, err := sql.Open("mysql", dsn)
+func connectToDatabase(cfg *config, tracerProvider trace.TracerProvider) (*sql.DB, error) {
+	db, err := openDatabase(cfg.DB.dsn, tracerProvider)
 	if err != nil {
 		return nil, err
 	}
 
+	// The duration has already been checked by config.validate
+	maxIdleTime, _ := time.ParseDuration(cfg.DB.maxIdleTime)
+
+	db.SetMaxOpenConns(cfg.DB.maxOpenConnections)
+	db.SetMaxIdleConns(cfg.DB.maxIdleConnections)
+	db.SetConnMaxIdleTime(maxIdleTime)
+
 	// Verify that our connection to the database is alive
 	if err = db.Ping(); err != nil {
 		return nil, err
@@ -60,54 +165,163 @@ func connectToDatabase(dsn string) (*sql.DB, error) {
 
 func main() {
 
-	var servConfig config
+	// "tempshare config check" prints the effective config and validates it, without starting the server
+	if len(os.Args) > 2 && os.Args[1] == "config" && os.Args[2] == "check" {
+		os.Exit(checkConfig(os.Args[3:], os.Stdout, os.Stderr))
+	}
 
-	flag.IntVar(&servConfig.port, "port", 4000, "HTTP network address")
-	flag.StringVar(&servConfig.env, "env", "development", "Environment (development|staging|production)")
+	// "tempshare keyring rotate" adds a new session and CSRF key to the keyring
+	if len(os.Args) > 2 && os.Args[1] == "keyring" && os.Args[2] == "rotate" {
+		os.Exit(rotateKeyring(os.Args[3:], os.Stdout, os.Stderr))
+	}
 
-	flag.StringVar(&servConfig.DB.dsn, "db-dsn", os.Getenv("TEMPSHARE_DSN"), "Specifies the MySQL database data source name (dsn)")
-	flag.StringVar(&servConfig.DB.maxIdleTime, "db-max-idle-time", "5m", "MySQL maximum time allowed for an idle connection")
-	flag.IntVar(&servConfig.DB.maxIdleConnections, "db-max-idle-conns", 25, "MySQL maximum number of idle connections")
-	flag.IntVar(&servConfig.DB.maxOpenConnections, "db-max-open-conns", 25, "MySQL maximum number of open connections")
+	// "tempshare admin create" creates an admin account, e.g. the first one
+	if len(os.Args) > 2 && os.Args[1] == "admin" && os.Args[2] == "create" {
+		os.Exit(createAdmin(os.Args[3:], os.Stdin, os.Stdout, os.Stderr))
+	}
 
-	// Generate a 32-bit key for securing our cookie session store
-	secret := flag.String("secret", string(securecookie.GenerateRandomKey(32)), "Cookie store session secret")
+	servConfig, _, _, err := loadConfig(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	} else if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
 
-	// We must parse all command line arguments before they can be used
-	flag.Parse()
+	logger := logging.New(os.Stdout, slog.LevelInfo)
 
-	errorLog := log.New(os.Stderr, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
-	infoLog := log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
+	if err := servConfig.validate(); err != nil {
+		logger.Error("invalid config", "error", err)
+		os.Exit(1)
+	}
 
-	db, err := connectToDatabase(servConfig.DB.dsn)
+	tracerProvider, shutdownTracer, err := initializeTracer(context.Background(), servConfig.otlpEndpoint)
 	if err != nil {
-		errorLog.Fatal(err)
+		logger.Error("failed to initialize tracing", "error", err)
+		os.Exit(1)
 	}
 
-	templateCache, err := initTemplateCache("./ui/html/")
+	db, err := connectToDatabase(servConfig, tracerProvider)
 	if err != nil {
-		errorLog.Fatal(err)
+		logger.Error("failed to connect to database", "error", err)
+		os.Exit(1)
 	}
 
-	session := sessions.New([]byte(*secret))
+	development := servConfig.env == "development"
+	uiFS := uiFiles(servConfig.uiDir, development)
+
+	loadedUI, err := loadUI(uiFS)
+	if err != nil {
+		logger.Error("failed to load templates and static files", "error", err)
+		os.Exit(1)
+	}
+
+	// The list has already been checked by config.validate
+	trustedProxies, _ := parseTrustedProxies(servConfig.trustedProxies)
+
+	sessionKeys, csrfKeys, err := loadKeys(servConfig)
+	if err != nil {
+		logger.Error("failed to load keys", "error", err)
+		os.Exit(1)
+	}
+
+	session := sessions.New(sessionKeys[0], sessionKeys[1:]...)
 	session.Lifetime = 12 * time.Hour
 	session.Secure = true
 	session.SameSite = http.SameSiteLaxMode
 
+	// Admins are logged in with keys of their own, and for no longer than the session lifetime
+	adminSessionKeys := keyring.Derive(sessionKeys, "admin session")
+	adminSession := sessions.New(adminSessionKeys[0], adminSessionKeys[1:]...)
+	adminSession.Lifetime = 12 * time.Hour
+	adminSession.Secure = true
+	adminSession.SameSite = http.SameSiteLaxMode
+
 	app := &application{
-		errorLog:      errorLog,
-		infoLog:       infoLog,
-		session:       session,
-		serverConfig:  servConfig,
-		templateCache: templateCache,
-		tempShare:     &mysql.TempShareModel{DB: db},
+		logger:         logger,
+		metrics:        newMetrics(),
+		tracerProvider: tracerProvider,
+		session:        session,
+		adminSession:   adminSession,
+		csrfKeys:       csrfKeys,
+		trustedProxies: trustedProxies,
+		serverConfig:   *servConfig,
+		ui:             loadedUI,
+		database:       db,
+		admins:         &mysql.AdminModel{DB: db},
+		webhooks:       &mysql.WebhookModel{DB: db},
+		requests:       &mysql.RequestModel{DB: db},
+		tempShare:      &mysql.TempShareModel{DB: db},
+		loginLimiter:   newRateLimiter(loginAttemptsPerMinute),
+	}
+
+	if servConfig.rateLimit > 0 {
+		app.rateLimiter = newRateLimiter(servConfig.rateLimit)
+	}
+
+	// Templates and static files are reloaded when they change, and template errors shown
+	if development {
+		app.devUI = &devUI{fsys: uiFS}
+	}
+
+	app.metrics.registerDB(db)
+
+	// Our own certificate is only trusted when the server serves it
+	clientCert := servConfig.TLS.certFile
+	if servConfig.plainHTTP {
+		clientCert = ""
+	}
+
+	if err := app.initializeClient(clientCert); err != nil {
+		app.logger.Error("failed to initialize HTTPS client", "error", err)
+		os.Exit(1)
+	}
+
+	mailFS, _ := fs.Sub(uiFS, "mail")
+
+	if err := app.initializeMailer(mailFS); err != nil {
+		app.logger.Error("failed to initialize mailer", "error", err)
+		os.Exit(1)
 	}
 
-	if err := app.initializeClient("./tls/cert.pem"); err != nil {
-		app.errorLog.Fatalln(err)
+	app.initializeNotifiers()
+
+	backgroundCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+
+	if servConfig.webhooksFile != "" {
+		app.webhookDestinations, err = webhook.LoadDestinations(servConfig.webhooksFile)
+		if err != nil {
+			app.logger.Error("failed to load webhook destinations", "error", err)
+			os.Exit(1)
+		}
+
+		app.webhookClient = &http.Client{
+			Transport: app.httpsClient.Transport,
+			Timeout:   webhookTimeout,
+		}
+
+		app.startWebhookWorkers(backgroundCtx)
 	}
 
-	if err := app.initializeServer(); err != nil {
-		app.errorLog.Fatalln(err)
+	app.startExpirySweeper(backgroundCtx)
+
+	err = app.initializeServer()
+
+	// The server has stopped, so stop the background loops too, and wait for what they're doing
+	stopBackground()
+	app.background.Wait()
+
+	// Export the spans of the last requests before exiting
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := shutdownTracer(ctx); err != nil {
+		app.logger.Error("failed to flush traces", "error", err)
+	}
+
+	if err != nil {
+		app.logger.Error("server stopped", "error", err)
+		os.Exit(1)
 	}
 }
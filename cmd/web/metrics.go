@@ -0,0 +1,170 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/matthewlmitchell/tempshare/pkg/recaptcha"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// metrics holds the Prometheus collectors of the application. They are registered with their
+// own registry rather than the global one, so every application (e.g. in tests) has its own.
+type metrics struct {
+	registry        *prometheus.Registry
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	sharesCreated   *prometheus.CounterVec
+	sharesViewed    prometheus.Counter
+	sharesExpired   prometheus.Counter
+	captcha         *prometheus.CounterVec
+	captchaDuration prometheus.Histogram
+	rateLimited     prometheus.Counter
+}
+
+func newMetrics() *metrics {
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "tempshare_http_requests_total",
+			Help: "Number of HTTP requests handled, by route, method and status code.",
+		}, []string{"route", "method", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "tempshare_http_request_duration_seconds",
+			Help:    "Time taken to handle HTTP requests, by route and method.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route", "method"}),
+		sharesCreated: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "tempshare_shares_created_total",
+			Help: "Number of TempShares created, by kind (single, split or multi).",
+		}, []string{"kind"}),
+		sharesViewed: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "tempshare_shares_viewed_total",
+			Help: "Number of times a TempShare (or part of a split secret) was opened.",
+		}),
+		sharesExpired: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "tempshare_shares_expired_total",
+			Help: "Number of TempShares found to have expired by the expiry sweep.",
+		}),
+		captcha: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "tempshare_captcha_verifications_total",
+			Help: "Number of reCAPTCHA verifications, by outcome (success, failure or error).",
+		}, []string{"outcome"}),
+		captchaDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "tempshare_captcha_verification_duration_seconds",
+			Help:    "Time taken to verify a reCAPTCHA response with Google.",
+			Buckets: prometheus.DefBuckets,
+		}),
+		rateLimited: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "tempshare_rate_limited_requests_total",
+			Help: "Number of requests rejected because their client exceeded the rate limit.",
+		}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.requestDuration,
+		m.sharesCreated,
+		m.sharesViewed,
+		m.sharesExpired,
+		m.captcha,
+		m.captchaDuration,
+		m.rateLimited,
+	)
+
+	return m
+}
+
+// registerDB exports the connection pool statistics of db (sql.DB.Stats) as gauges
+func (m *metrics) registerDB(db *sql.DB) {
+	m.registry.MustRegister(collectors.NewDBStatsCollector(db, "tempshare"))
+}
+
+// counterTotals returns the values of the counter called name, summed by the value of its
+// label (or under "" if label is empty), e.g. the shares created by kind since the server started
+func (m *metrics) counterTotals(name string, label string) (map[string]float64, error) {
+
+	families, err := m.registry.Gather()
+	if err != nil {
+		return nil, err
+	}
+
+	totals := map[string]float64{}
+
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+
+		for _, metric := range family.GetMetric() {
+			key := ""
+			for _, pair := range metric.GetLabel() {
+				if pair.GetName() == label {
+					key = pair.GetValue()
+				}
+			}
+
+			totals[key] += metric.GetCounter().GetValue()
+		}
+	}
+
+	return totals, nil
+}
+
+// instrumentRoute counts requests and measures their latency by the route pattern that matched
+// them, rather than the path, so that tokens never end up in a label. It must be installed with
+// chi's Use so that the route pattern is known once the request has been handled.
+// The span of the request is named after the route pattern for the same reason.
+func (app *application) instrumentRoute(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+
+		next.ServeHTTP(rec, r)
+
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+
+		route := "unmatched"
+		if routeContext := chi.RouteContext(r.Context()); routeContext != nil && routeContext.RoutePattern() != "" {
+			route = routeContext.RoutePattern()
+		}
+
+		span := trace.SpanFromContext(r.Context())
+		span.SetName(r.Method + " " + route)
+		span.SetAttributes(semconv.HTTPRoute(route))
+
+		app.metrics.requests.WithLabelValues(route, r.Method, strconv.Itoa(rec.status)).Inc()
+		app.metrics.requestDuration.WithLabelValues(route, r.Method).Observe(time.Since(start).Seconds())
+	})
+}
+
+// verifyCaptcha verifies a "g-recaptcha-response" form value with recaptcha.VerifyRecaptcha,
+// recording the outcome and latency of the verification
+func (app *application) verifyCaptcha(r *http.Request, response string) (bool, error) {
+
+	start := time.Now()
+	success, err := recaptcha.VerifyRecaptcha(app.serverConfig.env, app.serverConfig.reCAPTCHA.secret, app.httpsClient, r, response)
+	app.metrics.captchaDuration.Observe(time.Since(start).Seconds())
+
+	switch {
+	case err != nil:
+		app.metrics.captcha.WithLabelValues("error").Inc()
+	case success:
+		app.metrics.captcha.WithLabelValues("success").Inc()
+	default:
+		app.metrics.captcha.WithLabelValues("failure").Inc()
+	}
+
+	return success, err
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	app := newTestApplication(t)
+
+	testServ := newTestServer(t, app.routes(), false)
+	defer testServ.Close()
+
+	testServ.get(t, "/view?token=MUPPH5PDKV7AGCUAAEERL5ARIXICVVGYLRIV365X5XSV3EKISAXQ")
+	testServ.get(t, "/does-not-exist")
+
+	adminServ := newTestServer(t, app.adminRoutes(), false)
+	defer adminServ.Close()
+
+	statusCode, _, responseBody := adminServ.get(t, "/metrics")
+	if statusCode != http.StatusOK {
+		t.Fatalf("Expected status %d, received %d", http.StatusOK, statusCode)
+	}
+
+	expectedValues := [][]byte{
+		[]byte(`tempshare_http_requests_total{method="GET",route="/view",status="200"} 1`),
+		[]byte(`tempshare_http_requests_total{method="GET",route="unmatched",status="404"} 1`),
+		[]byte(`tempshare_http_request_duration_seconds_count{method="GET",route="/view"} 1`),
+		[]byte(`go_goroutines`),
+	}
+
+	for _, expectedValue := range expectedValues {
+		if !bytes.Contains(responseBody, expectedValue) {
+			t.Errorf("Expected %s in response body, received %s", expectedValue, responseBody)
+		}
+	}
+
+	if bytes.Contains(responseBody, []byte("MUPPH5PDKV7AGCUAAEERL5ARIXICVVGYLRIV365X5XSV3EKISAXQ")) {
+		t.Errorf("Expected no tokens in the metrics, received %s", responseBody)
+	}
+}
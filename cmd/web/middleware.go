@@ -1,41 +1,68 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
 	"fmt"
 	"net/http"
-	"os"
+	"time"
 
 	"github.com/gorilla/csrf"
+	"github.com/matthewlmitchell/tempshare/pkg/logging"
 )
 
-func noCSRF(next http.Handler) http.Handler {
-	// To generate the secret key for CSRF token generation evaluate the following:
-	//   base64.StdEncoding.EncodeToString(securecookie.GenerateRandomKey(32))
-	csrfHandler := csrf.Protect(
-		[]byte(os.Getenv("TEMPSHARE_CSRF_KEY")),
-		csrf.HttpOnly(true),
-		csrf.Path("/"),
-		csrf.Secure(true),
-	)(next)
-
-	return csrfHandler
+func (app *application) noCSRF(next http.Handler) http.Handler {
+	return app.protectCSRF(csrfCookieName, next)
+}
+
+// noAdminCSRF protects the admin pages like noCSRF, with a cookie of their own so that it
+// doesn't overwrite the cookie of the public pages served from the same host
+func (app *application) noAdminCSRF(next http.Handler) http.Handler {
+	return app.protectCSRF(adminCSRFCookieName, next)
+}
+
+func (app *application) protectCSRF(cookieName string, next http.Handler) http.Handler {
+	// CSRF tokens are generated with the newest key of app.csrfKeys, see loadKeys.
+	// The Secure attribute of the cookie depends on how the client connected, see isHTTPS.
+	protect := func(secure bool) http.Handler {
+		return csrf.Protect(
+			app.csrfKeys[0],
+			csrf.CookieName(cookieName),
+			csrf.MaxAge(csrfMaxAge),
+			csrf.HttpOnly(true),
+			csrf.Path("/"),
+			csrf.Secure(secure),
+		)(next)
+	}
+
+	secure, insecure := protect(true), protect(false)
+
+	return app.resignCSRFCookie(cookieName, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isHTTPS(r) {
+			secure.ServeHTTP(w, r)
+			return
+		}
+
+		insecure.ServeHTTP(w, r)
+	}))
 }
 
 // recoverPanic defines a deferred function that will run in the event of a panic
 // on the application's main thread, which will attempt to automatically recover from the
-// panic, log the error to app.errorLog, and close the connection to the client as code 500
+// panic, log the error, and close the connection to the client as code 500
 func (app *application) recoverPanic(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
 		// This deferred function will run when a panic occurs
 		defer func() {
 			// If a panic is detected in our main thread: close the connection,
-			// log the error to our app.errorLog logger, then forward the client
+			// log the error, then forward the client
 			// to a generic code 500 error page
 			if err := recover(); err != nil {
 				w.Header().Set("Connection", "close")
 
-				app.serverError(w, fmt.Errorf("%s", err))
+				app.serverError(w, r, fmt.Errorf("%s", err))
 			}
 		}()
 
@@ -43,25 +70,143 @@ func (app *application) recoverPanic(next http.Handler) http.Handler {
 	})
 }
 
-// logRequest logs where the request came from, the protocol of the request and its HTTP method,
-// and what URL the request was for to our app.infoLog, then forwards the client's request
-// to the next http.Handler
+// requestID gives every request a random ID, which is returned to the client in the X-Request-ID
+// header and carried by the request's context, so that everything logged while handling the
+// request (including by background work it starts) can be tied back to it.
+func requestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		id := logging.NewRequestID()
+		w.Header().Set("X-Request-ID", id)
+
+		next.ServeHTTP(w, r.WithContext(logging.WithRequestID(r.Context(), id)))
+	})
+}
+
+// statusRecorder wraps an http.ResponseWriter to capture the status code and size of the response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+
+	n, err := rec.ResponseWriter.Write(b)
+	rec.size += n
+
+	return n, err
+}
+
+// logRequest forwards the client's request to the next http.Handler, then logs where the request
+// came from, its protocol, method and path, and the status, size and latency of the response.
+// Query strings are logged with the values of tokens and other sensitive fields redacted,
+// and form values are never logged.
 func (app *application) logRequest(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
-		// Log where the request came from, the protocol of the request and its HTTP method,
-		// and what URL the request was for
-		app.infoLog.Printf("%s - %s %s %s", r.RemoteAddr, r.Proto, r.Method, r.URL)
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
 
-		next.ServeHTTP(w, r)
+		next.ServeHTTP(rec, r)
+
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+
+		app.loggerFrom(r.Context()).Info("request",
+			"remote", r.RemoteAddr,
+			"proto", r.Proto,
+			"method", r.Method,
+			"path", r.URL.Path,
+			"query", logging.RedactQuery(r.URL.Query()),
+			"status", rec.status,
+			"size", rec.size,
+			"duration_ms", time.Since(start).Milliseconds(),
+		)
 	})
 }
 
+// contentSecurityPolicy only allows scripts carrying the nonce of the response, and the scripts
+// they load ('strict-dynamic', needed by reCAPTCHA). 'unsafe-inline' and https: are only
+// fallbacks for browsers without nonce support, which ignore them otherwise.
+const contentSecurityPolicy = "default-src 'self'; " +
+	"script-src 'nonce-%s' 'strict-dynamic' 'unsafe-inline' https:; " +
+	"style-src 'self'; font-src 'self'; " +
+	"img-src 'self' data:; " +
+	"frame-src https://www.google.com/recaptcha/ https://recaptcha.google.com/recaptcha/; " +
+	"object-src 'none'; base-uri 'none'; form-action 'self'; frame-ancestors 'none'; " +
+	"report-uri /csp-report; report-to csp"
+
+// secureHeaders sets the security headers of every response, with a CSP nonce generated
+// for the request (see cspNonce) which the scripts of its page must carry
 func secureHeaders(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("X-XSS-Protection", "1; mode=block")
+
+		nonce := make([]byte, 16)
+		if _, err := rand.Read(nonce); err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		encodedNonce := base64.RawURLEncoding.EncodeToString(nonce)
+
+		w.Header().Set("Content-Security-Policy", fmt.Sprintf(contentSecurityPolicy, encodedNonce))
+		w.Header().Set("Reporting-Endpoints", `csp="/csp-report"`)
+
+		// Tokens are part of the URLs of share pages, so they must never be sent to other sites
+		w.Header().Set("Referrer-Policy", "no-referrer")
+		w.Header().Set("Permissions-Policy", "camera=(), microphone=(), geolocation=(), payment=(), usb=(), interest-cohort=()")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
 		w.Header().Set("X-Frame-Options", "deny")
 
+		// The XSS auditor of old browsers can be abused to remove scripts, the CSP replaces it
+		w.Header().Set("X-XSS-Protection", "0")
+
+		// Browsers ignore HSTS over plain HTTP, and it would be wrong if a proxy serves HTTP anyway
+		if isHTTPS(r) {
+			w.Header().Set("Strict-Transport-Security", "max-age=63072000; includeSubDomains")
+		}
+
+		ctx := context.WithValue(r.Context(), contextKeyCSPNonce, encodedNonce)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// cspNonce returns the CSP nonce of the request, generated by secureHeaders
+func cspNonce(r *http.Request) string {
+	nonce, _ := r.Context().Value(contextKeyCSPNonce).(string)
+	return nonce
+}
+
+// maxFormSize is the most a form of the pages may send, well above what the largest of them
+// (a text file uploaded to the create page) needs
+const maxFormSize = 64 << 10
+
+// limitBody stops reading the body of a request after maxFormSize, before it is parsed by
+// gorilla/csrf or a handler
+func limitBody(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, maxFormSize)
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// noStore stops browsers and proxies from caching pages, which may show shared secrets and
+// the links to them
+func noStore(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Pragma", "no-cache")
+
 		next.ServeHTTP(w, r)
 	})
 }
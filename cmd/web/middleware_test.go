@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/matthewlmitchell/tempshare/pkg/logging"
+)
+
+func TestLogRequest(t *testing.T) {
+	app := newTestApplication(t)
+
+	logBuffer := &bytes.Buffer{}
+	app.logger = logging.New(logBuffer, slog.LevelInfo)
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		app.loggerFrom(r.Context()).Info("handled", "token", r.URL.Query().Get("token"))
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	responseRecorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, "/view?token=MUPPH5PDKV7AGCUAAEERL5ARIXICVVGYLRIV365X5XSV3EKISAXQ", nil)
+
+	requestID(app.logRequest(next)).ServeHTTP(responseRecorder, request)
+
+	id := responseRecorder.Header().Get("X-Request-ID")
+	if id == "" {
+		t.Fatal("Expected an X-Request-ID header")
+	}
+
+	if strings.Contains(logBuffer.String(), "MUPPH5PDKV7AGCUAAEERL5ARIXICVVGYLRIV365X5XSV3EKISAXQ") {
+		t.Errorf("Expected the token to be redacted, received %s", logBuffer.String())
+	}
+
+	lines := strings.Split(strings.TrimSpace(logBuffer.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 log entries, received %d", len(lines))
+	}
+
+	for _, line := range lines {
+		entry := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatal(err)
+		}
+
+		if entry["request_id"] != id {
+			t.Errorf("Expected request_id %s, received %v", id, entry["request_id"])
+		}
+	}
+
+	accessLog := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(lines[1]), &accessLog); err != nil {
+		t.Fatal(err)
+	}
+
+	if accessLog["status"] != float64(http.StatusTeapot) {
+		t.Errorf("Expected status %d, received %v", http.StatusTeapot, accessLog["status"])
+	}
+	if accessLog["path"] != "/view" {
+		t.Errorf("Expected path %s, received %v", "/view", accessLog["path"])
+	}
+	if _, ok := accessLog["duration_ms"]; !ok {
+		t.Error("Expected duration_ms in the access log")
+	}
+}
+
+func TestSecureHeaders(t *testing.T) {
+
+	var nonces []string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nonces = append(nonces, cspNonce(r))
+	})
+
+	tests := []struct {
+		name     string
+		https    bool
+		wantHSTS bool
+	}{
+		{"HTTPS", true, true},
+		{"Plain HTTP", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			responseRecorder := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodGet, "/", nil)
+			request = request.WithContext(context.WithValue(request.Context(), contextKeyHTTPS, tt.https))
+
+			secureHeaders(next).ServeHTTP(responseRecorder, request)
+
+			nonce := nonces[len(nonces)-1]
+			if nonce == "" {
+				t.Fatal("Expected a CSP nonce in the request context")
+			}
+
+			csp := responseRecorder.Header().Get("Content-Security-Policy")
+			if !strings.Contains(csp, "'nonce-"+nonce+"'") {
+				t.Errorf("Expected the CSP to allow the nonce %s, received %q", nonce, csp)
+			}
+
+			if referrer := responseRecorder.Header().Get("Referrer-Policy"); referrer != "no-referrer" {
+				t.Errorf("Expected Referrer-Policy no-referrer, received %q", referrer)
+			}
+
+			if hsts := responseRecorder.Header().Get("Strict-Transport-Security"); (hsts != "") != tt.wantHSTS {
+				t.Errorf("Expected HSTS %t, received %q", tt.wantHSTS, hsts)
+			}
+		})
+	}
+
+	if nonces[0] == nonces[1] {
+		t.Error("Expected a different nonce for each request")
+	}
+}
+
+func TestCSPNonceInPages(t *testing.T) {
+	app := newTestApplication(t)
+	testServ := newTestServer(t, app.routes(), false)
+	defer testServ.Close()
+
+	statusCode, header, body := testServ.get(t, "/create")
+	if statusCode != http.StatusOK {
+		t.Fatalf("Expected status %d, received %d", http.StatusOK, statusCode)
+	}
+
+	if cacheControl := header.Get("Cache-Control"); cacheControl != "no-store" {
+		t.Errorf("Expected Cache-Control no-store, received %q", cacheControl)
+	}
+
+	matches := regexp.MustCompile(`'nonce-([^']+)'`).FindStringSubmatch(header.Get("Content-Security-Policy"))
+	if matches == nil {
+		t.Fatal("Expected a nonce in the CSP")
+	}
+
+	scripts := regexp.MustCompile(`<script[^>]*>`).FindAll(body, -1)
+	if len(scripts) == 0 {
+		t.Fatal("Expected scripts in the page")
+	}
+
+	for _, script := range scripts {
+		if !bytes.Contains(script, []byte(`nonce="`+matches[1]+`"`)) {
+			t.Errorf("Expected the script to carry the nonce, received %s", script)
+		}
+	}
+}
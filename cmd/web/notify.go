@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/matthewlmitchell/tempshare/pkg/mailer"
+	"github.com/matthewlmitchell/tempshare/pkg/models"
+	"github.com/matthewlmitchell/tempshare/pkg/notify"
+	"github.com/matthewlmitchell/tempshare/pkg/webhook"
+)
+
+// browserFamilies maps a substring of a User-Agent header to a browser family.
+// Order matters, since e.g. Chrome and Edge both also identify as Safari.
+var browserFamilies = []struct {
+	match  string
+	family string
+}{
+	{"Edg/", "Edge"},
+	{"OPR/", "Opera"},
+	{"Firefox/", "Firefox"},
+	{"Chrome/", "Chrome"},
+	{"Safari/", "Safari"},
+	{"curl/", "curl"},
+}
+
+// mailWorkers is the number of goroutines sending queued emails
+const mailWorkers = 2
+
+// initializeMailer parses the email templates in fsys and starts the workers of the
+// mail queue, if an SMTP server has been configured.
+func (app *application) initializeMailer(fsys fs.FS) error {
+
+	if app.serverConfig.SMTP.host == "" {
+		return nil
+	}
+
+	smtpMailer, err := mailer.New(fsys,
+		app.serverConfig.SMTP.host,
+		app.serverConfig.SMTP.port,
+		app.serverConfig.SMTP.username,
+		app.serverConfig.SMTP.password,
+		app.serverConfig.SMTP.sender,
+	)
+	if err != nil {
+		return err
+	}
+	smtpMailer.AllowInsecure = app.serverConfig.SMTP.insecure
+
+	app.mailer = mailer.NewQueue(smtpMailer, 100, app.logger)
+
+	for i := 0; i < mailWorkers; i++ {
+		app.runInBackground(app.mailer.Work)
+	}
+
+	return nil
+}
+
+// initializeNotifiers appends a notify.Notifier to app.notifiers for every
+// notification channel that has been configured.
+func (app *application) initializeNotifiers() {
+
+	if app.mailer != nil {
+		app.notifiers = append(app.notifiers, &notify.SMTPNotifier{Queue: app.mailer})
+	}
+
+	if app.serverConfig.notifyWebhook != "" {
+		app.notifiers = append(app.notifiers, &notify.WebhookNotifier{
+			URL:    app.serverConfig.notifyWebhook,
+			Client: app.httpsClient,
+		})
+	}
+}
+
+// clientInfo returns coarse information about the client making a request: the browser
+// family from the User-Agent header and the client's IP address truncated to its /24 (IPv4)
+// or /48 (IPv6) network, so that a creator can't precisely identify who opened their TempShare.
+func clientInfo(r *http.Request) string {
+
+	browser := "Unknown client"
+	userAgent := r.UserAgent()
+	for _, browserFamily := range browserFamilies {
+		if strings.Contains(userAgent, browserFamily.match) {
+			browser = browserFamily.family
+			break
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return browser
+	}
+
+	var network *net.IPNet
+	if ip.To4() != nil {
+		network = &net.IPNet{IP: ip.Mask(net.CIDRMask(24, 32)), Mask: net.CIDRMask(24, 32)}
+	} else {
+		network = &net.IPNet{IP: ip.Mask(net.CIDRMask(48, 128)), Mask: net.CIDRMask(48, 128)}
+	}
+
+	return fmt.Sprintf("%s from %s", browser, network.String())
+}
+
+// recordView stores a view of tempShare in its view history, then emits a notify.Event
+// to every configured notifier and queues the viewed (and possibly exhausted) webhook events.
+// This happens in the background so that a slow SMTP server or webhook can't delay the
+// response to the recipient.
+func (app *application) recordView(r *http.Request, tempShare *models.TempShare) {
+
+	event := &notify.Event{
+		Share:          hex.EncodeToString(tempShare.URLToken[:8]),
+		Recipient:      tempShare.Notify,
+		Viewed:         time.Now().UTC(),
+		Client:         clientInfo(r),
+		ViewsRemaining: tempShare.ViewLimit - tempShare.Views - 1,
+	}
+
+	app.metrics.sharesViewed.Inc()
+
+	// The request is over by the time the background work runs, but its ID is still useful
+	ctx := context.WithoutCancel(r.Context())
+
+	app.runInBackground(func() {
+		if err := app.tempShare.AddView(ctx, tempShare.URLToken, event.Client); err != nil {
+			app.loggerFrom(ctx).Error("failed to record view", "error", err)
+		}
+
+		for _, notifier := range app.notifiers {
+			if err := notifier.Notify(event); err != nil {
+				app.loggerFrom(ctx).Error("failed to send view notification", "error", err)
+			}
+		}
+
+		// tempShare holds the view count from before this view was consumed
+		viewed := *tempShare
+		viewed.Views++
+
+		app.emitEvent(ctx, webhook.EventViewed, &viewed, event.Client)
+		if viewed.Views >= viewed.ViewLimit {
+			app.emitEvent(ctx, webhook.EventExhausted, &viewed, "")
+		}
+	})
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientInfo(t *testing.T) {
+
+	testCases := []struct {
+		testName       string
+		remoteAddr     string
+		userAgent      string
+		expectedOutput string
+	}{
+		{
+			testName:       "IPv4 Firefox",
+			remoteAddr:     "192.0.2.57:51234",
+			userAgent:      "Mozilla/5.0 (X11; Linux x86_64; rv:97.0) Gecko/20100101 Firefox/97.0",
+			expectedOutput: "Firefox from 192.0.2.0/24",
+		},
+		{
+			testName:       "IPv6 Chrome",
+			remoteAddr:     "[2001:db8:1234:5678::1]:443",
+			userAgent:      "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/98.0.4758.102 Safari/537.36",
+			expectedOutput: "Chrome from 2001:db8:1234::/48",
+		},
+		{
+			testName:       "Edge is not Chrome",
+			remoteAddr:     "198.51.100.7:1000",
+			userAgent:      "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/98.0.4758.102 Safari/537.36 Edg/98.0.1108.56",
+			expectedOutput: "Edge from 198.51.100.0/24",
+		},
+		{
+			testName:       "Unknown client without address",
+			remoteAddr:     "",
+			userAgent:      "",
+			expectedOutput: "Unknown client",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.testName, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/view", nil)
+			r.RemoteAddr = testCase.remoteAddr
+			r.Header.Set("User-Agent", testCase.userAgent)
+
+			realOutput := clientInfo(r)
+
+			if realOutput != testCase.expectedOutput {
+				t.Errorf("Expected %s, received %s", testCase.expectedOutput, realOutput)
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+type contextKey string
+
+const (
+	contextKeyHTTPS    = contextKey("https")
+	contextKeyCSPNonce = contextKey("cspNonce")
+	contextKeyLocale   = contextKey("locale")
+	contextKeyAdmin    = contextKey("admin")
+)
+
+// parseTrustedProxies parses a comma separated list of CIDRs (or single addresses)
+// of the proxies whose X-Forwarded-* headers are trusted
+func parseTrustedProxies(list string) ([]*net.IPNet, error) {
+
+	var proxies []*net.IPNet
+
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid proxy address %q", entry)
+			}
+
+			bits := 8 * net.IPv4len
+			if ip.To4() == nil {
+				bits = 8 * net.IPv6len
+			}
+			entry = fmt.Sprintf("%s/%d", entry, bits)
+		}
+
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, err
+		}
+		proxies = append(proxies, network)
+	}
+
+	return proxies, nil
+}
+
+// trustedProxy reports whether the address addr (with or without a port) belongs to a trusted proxy
+func (app *application) trustedProxy(addr string) bool {
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+
+	for _, network := range app.trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// forwardedHeaders replaces the remote address of requests sent by a trusted proxy with the
+// address of the client from X-Forwarded-For, and records whether the client connected to
+// the proxy over HTTPS from X-Forwarded-Proto. The headers of other requests are ignored,
+// since anyone could set them. Connections over the unix socket can only come from the
+// proxy in front of the server, so they are always trusted.
+func (app *application) forwardedHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		https := r.TLS != nil
+		r = r.Clone(r.Context())
+
+		if app.serverConfig.unixSocket != "" || app.trustedProxy(r.RemoteAddr) {
+			if clientAddr := app.forwardedFor(r); clientAddr != "" {
+				r.RemoteAddr = net.JoinHostPort(clientAddr, "0")
+			}
+
+			// Each proxy appends the scheme it received, so the last one was set by the trusted proxy
+			if values := forwardedValues(r, "X-Forwarded-Proto"); len(values) > 0 {
+				https = strings.EqualFold(values[len(values)-1], "https")
+			}
+		}
+
+		ctx := context.WithValue(r.Context(), contextKeyHTTPS, https)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// forwardedFor returns the address of the client from X-Forwarded-For, which is the last one
+// not added by a trusted proxy, since the addresses before it could have been set by the client
+func (app *application) forwardedFor(r *http.Request) string {
+
+	addresses := forwardedValues(r, "X-Forwarded-For")
+
+	for i := len(addresses) - 1; i >= 0; i-- {
+		ip := net.ParseIP(addresses[i])
+		if ip == nil {
+			return ""
+		}
+
+		if i == 0 || !app.trustedProxy(addresses[i]) {
+			return ip.String()
+		}
+	}
+
+	return ""
+}
+
+// forwardedValues returns the comma separated values of every header named key
+func forwardedValues(r *http.Request, key string) []string {
+
+	var values []string
+
+	for _, header := range r.Header.Values(key) {
+		for _, value := range strings.Split(header, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+	}
+
+	return values
+}
+
+// isHTTPS reports whether the client connected over HTTPS, either to the server
+// or to the trusted proxy in front of it
+func isHTTPS(r *http.Request) bool {
+
+	if https, ok := r.Context().Value(contextKeyHTTPS).(bool); ok {
+		return https
+	}
+
+	return r.TLS != nil
+}
+
+// enableSession loads and saves the session like app.session.Enable, but only sets the
+// Secure attribute of the session cookie if the client connected over HTTPS
+func (app *application) enableSession(next http.Handler) http.Handler {
+
+	insecureSession := *app.session
+	insecureSession.Secure = false
+
+	secure := app.session.Enable(next)
+	insecure := insecureSession.Enable(next)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isHTTPS(r) {
+			secure.ServeHTTP(w, r)
+			return
+		}
+
+		insecure.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestForwardedHeaders(t *testing.T) {
+
+	app := newTestApplication(t)
+
+	trustedProxies, err := parseTrustedProxies("10.0.0.0/8, 192.168.1.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.trustedProxies = trustedProxies
+
+	testCases := []struct {
+		name               string
+		remoteAddr         string
+		forwardedFor       string
+		forwardedProto     string
+		expectedRemoteAddr string
+		expectedHTTPS      bool
+	}{
+		{"Untrusted peer", "203.0.113.7:1234", "198.51.100.1", "https", "203.0.113.7:1234", false},
+		{"Trusted proxy", "10.1.2.3:1234", "198.51.100.1", "https", "198.51.100.1:0", true},
+		{"Trusted proxy over HTTP", "10.1.2.3:1234", "198.51.100.1", "http", "198.51.100.1:0", false},
+		{"Spoofed address before the client", "10.1.2.3:1234", "192.0.2.66, 198.51.100.1", "https", "198.51.100.1:0", true},
+		{"Chain of trusted proxies", "192.168.1.1:1234", "198.51.100.1, 10.9.9.9", "https", "198.51.100.1:0", true},
+		{"Invalid address", "10.1.2.3:1234", "unknown", "https", "10.1.2.3:1234", true},
+		{"Last scheme wins", "10.1.2.3:1234", "198.51.100.1", "https, http", "198.51.100.1:0", false},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+
+			var remoteAddr string
+			var https bool
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				remoteAddr = r.RemoteAddr
+				https = isHTTPS(r)
+			})
+
+			request := httptest.NewRequest(http.MethodGet, "/", nil)
+			request.RemoteAddr = testCase.remoteAddr
+			request.Header.Set("X-Forwarded-For", testCase.forwardedFor)
+			request.Header.Set("X-Forwarded-Proto", testCase.forwardedProto)
+
+			app.forwardedHeaders(next).ServeHTTP(httptest.NewRecorder(), request)
+
+			if remoteAddr != testCase.expectedRemoteAddr {
+				t.Errorf("Expected remote address %s, received %s", testCase.expectedRemoteAddr, remoteAddr)
+			}
+			if https != testCase.expectedHTTPS {
+				t.Errorf("Expected HTTPS: %v, received %v", testCase.expectedHTTPS, https)
+			}
+		})
+	}
+}
+
+func TestSecureCookies(t *testing.T) {
+
+	app := newTestApplication(t)
+
+	trustedProxies, err := parseTrustedProxies("127.0.0.1, ::1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.trustedProxies = trustedProxies
+
+	// A plain HTTP server, as run behind a proxy which terminates TLS
+	testServ := httptest.NewServer(app.routes())
+	defer testServ.Close()
+
+	testCases := []struct {
+		name           string
+		forwardedProto string
+		expectSecure   bool
+	}{
+		{"Client connected over HTTPS", "https", true},
+		{"Client connected over HTTP", "http", false},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+
+			request, err := http.NewRequest(http.MethodGet, testServ.URL+"/create", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			request.Header.Set("X-Forwarded-Proto", testCase.forwardedProto)
+
+			response, err := testServ.Client().Do(request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			response.Body.Close()
+
+			var found bool
+			for _, cookie := range response.Header.Values("Set-Cookie") {
+				if !strings.HasPrefix(cookie, csrfCookieName+"=") {
+					continue
+				}
+
+				found = true
+				if secure := strings.Contains(cookie, "; Secure"); secure != testCase.expectSecure {
+					t.Errorf("Expected Secure: %v, received %s", testCase.expectSecure, cookie)
+				}
+			}
+
+			if !found {
+				t.Error("Expected a CSRF cookie")
+			}
+		})
+	}
+}
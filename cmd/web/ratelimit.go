@@ -0,0 +1,171 @@
+package main
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// How long a client is remembered after its last request, longer if any of its requests
+// were rejected so that it shows up on the admin dashboard
+const (
+	rateLimiterIdle     = 10 * time.Minute
+	rateLimiterRejected = 24 * time.Hour
+)
+
+// loginAttemptsPerMinute is how many passwords each client IP address may submit to the
+// admin login page a minute, whether or not the rate-limit setting is set. Incorrect passwords
+// are also counted per account by app.admins, see models.MaxPasswordAttempts.
+const loginAttemptsPerMinute = 10
+
+// rateLimiter limits the rate of requests from each client IP address, and counts the
+// requests it rejected from each of them
+type rateLimiter struct {
+	limit rate.Limit
+	burst int
+
+	mu        sync.Mutex
+	clients   map[string]*rateLimitedClient
+	lastSweep time.Time
+}
+
+type rateLimitedClient struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+	rejected int
+}
+
+// newRateLimiter allows each client perMinute requests a minute, in bursts of up to perMinute
+func newRateLimiter(perMinute int) *rateLimiter {
+	return &rateLimiter{
+		limit:   rate.Limit(float64(perMinute) / 60),
+		burst:   perMinute,
+		clients: map[string]*rateLimitedClient{},
+	}
+}
+
+// allow reports whether a request from ip may be handled now
+func (l *rateLimiter) allow(ip string, now time.Time) bool {
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Clients which have gone quiet are forgotten, so that the map doesn't grow with every address seen
+	if now.Sub(l.lastSweep) > time.Minute {
+		for key, client := range l.clients {
+			idle := rateLimiterIdle
+			if client.rejected > 0 {
+				idle = rateLimiterRejected
+			}
+			if now.Sub(client.lastSeen) > idle {
+				delete(l.clients, key)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	client, ok := l.clients[ip]
+	if !ok {
+		client = &rateLimitedClient{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.clients[ip] = client
+	}
+	client.lastSeen = now
+
+	if !client.limiter.AllowN(now, 1) {
+		client.rejected++
+		return false
+	}
+
+	return true
+}
+
+// retryAfter returns how many seconds it takes a client to be allowed another request
+func (l *rateLimiter) retryAfter() int {
+	return int(math.Ceil(1 / float64(l.limit)))
+}
+
+// rejectedClient is a client IP address and the number of its requests which were rejected
+type rejectedClient struct {
+	IP       string
+	Rejected int
+	LastSeen time.Time
+}
+
+// top returns the n clients with the most rejected requests, most first
+func (l *rateLimiter) top(n int) []rejectedClient {
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var clients []rejectedClient
+	for ip, client := range l.clients {
+		if client.rejected > 0 {
+			clients = append(clients, rejectedClient{IP: ip, Rejected: client.rejected, LastSeen: client.lastSeen})
+		}
+	}
+
+	sort.Slice(clients, func(i, j int) bool {
+		if clients[i].Rejected != clients[j].Rejected {
+			return clients[i].Rejected > clients[j].Rejected
+		}
+		return clients[i].IP < clients[j].IP
+	})
+
+	if len(clients) > n {
+		clients = clients[:n]
+	}
+
+	return clients
+}
+
+// rateLimit responds with 429 Too Many Requests to clients sending requests faster than
+// allowed by the rate-limit setting
+func (app *application) rateLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if app.rateLimiter != nil && !app.allowRequest(w, r, app.rateLimiter) {
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// limitLogins responds with 429 Too Many Requests to clients submitting passwords to the admin
+// login page faster than loginAttemptsPerMinute
+func (app *application) limitLogins(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if app.loginLimiter != nil && !app.allowRequest(w, r, app.loginLimiter) {
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// allowRequest reports whether limiter allows the request, and responds with 429 Too Many
+// Requests if not. Clients are told apart by their IP address, which forwardedHeaders has
+// already taken from trusted proxies.
+func (app *application) allowRequest(w http.ResponseWriter, r *http.Request, limiter *rateLimiter) bool {
+
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+
+	if !limiter.allow(ip, time.Now()) {
+		app.metrics.rateLimited.Inc()
+		w.Header().Set("Retry-After", strconv.Itoa(limiter.retryAfter()))
+		app.clientError(w, http.StatusTooManyRequests)
+		return false
+	}
+
+	return true
+}
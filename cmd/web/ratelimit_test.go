@@ -0,0 +1,131 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+
+	start := time.Date(2022, 2, 21, 12, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name            string
+		requests        []string      // Client IP addresses, one request each
+		after           time.Duration // How long after start the last request is sent
+		expectedAllowed bool          // Whether the last request is allowed
+		expectedTop     []rejectedClient
+	}{
+		{
+			name:            "Within the burst",
+			requests:        []string{"192.0.2.1", "192.0.2.1"},
+			expectedAllowed: true,
+		},
+		{
+			name:            "Burst exceeded",
+			requests:        []string{"192.0.2.1", "192.0.2.1", "192.0.2.1"},
+			expectedAllowed: false,
+			expectedTop:     []rejectedClient{{IP: "192.0.2.1", Rejected: 1, LastSeen: start}},
+		},
+		{
+			name:            "Clients are limited separately",
+			requests:        []string{"192.0.2.1", "192.0.2.1", "192.0.2.1", "192.0.2.1", "192.0.2.2", "192.0.2.2", "192.0.2.2"},
+			expectedAllowed: false,
+			expectedTop: []rejectedClient{
+				{IP: "192.0.2.1", Rejected: 2, LastSeen: start},
+				{IP: "192.0.2.2", Rejected: 1, LastSeen: start},
+			},
+		},
+		{
+			name:            "Allowed again after waiting",
+			requests:        []string{"192.0.2.1", "192.0.2.1", "192.0.2.1", "192.0.2.1"},
+			after:           time.Minute,
+			expectedAllowed: true,
+			expectedTop:     []rejectedClient{{IP: "192.0.2.1", Rejected: 1, LastSeen: start.Add(time.Minute)}},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			limiter := newRateLimiter(2)
+
+			var allowed bool
+			for i, ip := range testCase.requests {
+				now := start
+				if i == len(testCase.requests)-1 {
+					now = start.Add(testCase.after)
+				}
+				allowed = limiter.allow(ip, now)
+			}
+
+			if allowed != testCase.expectedAllowed {
+				t.Errorf("Expected allowed to be %t, received %t", testCase.expectedAllowed, allowed)
+			}
+
+			top := limiter.top(dashboardClients)
+			if len(top) != len(testCase.expectedTop) {
+				t.Fatalf("Expected %v, received %v", testCase.expectedTop, top)
+			}
+			for i := range top {
+				if top[i] != testCase.expectedTop[i] {
+					t.Errorf("Expected %v, received %v", testCase.expectedTop[i], top[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimiterForgetsIdleClients(t *testing.T) {
+
+	start := time.Date(2022, 2, 21, 12, 0, 0, 0, time.UTC)
+	limiter := newRateLimiter(1)
+
+	limiter.allow("192.0.2.1", start)
+	limiter.allow("192.0.2.2", start)
+	limiter.allow("192.0.2.2", start)
+
+	// Only the client which was rejected is remembered past rateLimiterIdle
+	limiter.allow("192.0.2.3", start.Add(rateLimiterIdle+time.Minute))
+	if _, ok := limiter.clients["192.0.2.1"]; ok {
+		t.Error("Expected the idle client to be forgotten")
+	}
+	if _, ok := limiter.clients["192.0.2.2"]; !ok {
+		t.Error("Expected the rejected client to be remembered")
+	}
+
+	limiter.allow("192.0.2.3", start.Add(rateLimiterRejected+time.Minute))
+	if _, ok := limiter.clients["192.0.2.2"]; ok {
+		t.Error("Expected the rejected client to be forgotten after a day")
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+
+	app := newTestApplication(t)
+	app.rateLimiter = newRateLimiter(2)
+
+	testServ := newTestServer(t, app.routes(), false)
+	defer testServ.Close()
+
+	for i := 0; i < 2; i++ {
+		statusCode, _, _ := testServ.get(t, "/about")
+		if statusCode != http.StatusOK {
+			t.Fatalf("Expected status %d, received %d", http.StatusOK, statusCode)
+		}
+	}
+
+	statusCode, header, _ := testServ.get(t, "/about")
+	if statusCode != http.StatusTooManyRequests {
+		t.Fatalf("Expected status %d, received %d", http.StatusTooManyRequests, statusCode)
+	}
+	if header.Get("Retry-After") != "30" {
+		t.Errorf("Expected Retry-After 30, received %q", header.Get("Retry-After"))
+	}
+
+	// The health checks aren't rate limited, so they work when a client is being rejected
+	statusCode, _, _ = testServ.get(t, "/healthz")
+	if statusCode != http.StatusOK {
+		t.Errorf("Expected status %d, received %d", http.StatusOK, statusCode)
+	}
+}
@@ -5,16 +5,18 @@ import (
 
 	"github.com/go-chi/chi"
 	"github.com/justinas/alice"
-	"github.com/schollz/httpfileserver"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 func (app *application) routes() http.Handler {
 
-	// TODO: Add rate limiting to our dynamicMiddlware, before enabling http session management
-	standardMiddleware := alice.New(app.recoverPanic, app.logRequest, secureHeaders)
-	dynamicMiddleware := alice.New(app.session.Enable, noCSRF)
+	standardMiddleware := alice.New(app.forwardedHeaders, app.traceRequest, requestID, app.logRequest, app.recoverPanic, secureHeaders)
+	dynamicMiddleware := alice.New(noStore, limitBody, app.rateLimit, app.enableSession, app.localize, app.noCSRF)
+	apiMiddleware := alice.New(noStore, limitBody, app.rateLimit)
 
 	mux := chi.NewRouter()
+	mux.Use(app.instrumentRoute)
+
 	mux.Get("/", dynamicMiddleware.ThenFunc(app.home).(http.HandlerFunc))
 	mux.Get("/create", dynamicMiddleware.ThenFunc(app.createTempShareForm).(http.HandlerFunc))
 	mux.Post("/create", dynamicMiddleware.ThenFunc(app.createTempShare).(http.HandlerFunc))
@@ -22,11 +24,63 @@ func (app *application) routes() http.Handler {
 	mux.Get("/view", dynamicMiddleware.ThenFunc(app.viewTempShareForm).(http.HandlerFunc))
 	mux.Post("/view", dynamicMiddleware.ThenFunc(app.viewTempShare).(http.HandlerFunc))
 
+	mux.Get("/combine", dynamicMiddleware.ThenFunc(app.combineTempShareForm).(http.HandlerFunc))
+	mux.Post("/combine", dynamicMiddleware.ThenFunc(app.combineTempShare).(http.HandlerFunc))
+
+	mux.Get("/request", dynamicMiddleware.ThenFunc(app.createRequestForm).(http.HandlerFunc))
+	mux.Post("/request", dynamicMiddleware.ThenFunc(app.createRequest).(http.HandlerFunc))
+	mux.Get("/respond", dynamicMiddleware.ThenFunc(app.respondRequestForm).(http.HandlerFunc))
+	mux.Post("/respond", dynamicMiddleware.ThenFunc(app.respondRequest).(http.HandlerFunc))
+	mux.Get("/collect", dynamicMiddleware.ThenFunc(app.collectRequestForm).(http.HandlerFunc))
+	mux.Post("/collect", dynamicMiddleware.ThenFunc(app.collectRequest).(http.HandlerFunc))
+
+	mux.Get("/manage", dynamicMiddleware.ThenFunc(app.manageTempShare).(http.HandlerFunc))
+	mux.Post("/manage/revoke", dynamicMiddleware.ThenFunc(app.revokeTempShare).(http.HandlerFunc))
+
+	mux.Post("/api/create", apiMiddleware.ThenFunc(app.apiCreateTempShare).(http.HandlerFunc))
+	mux.Post("/api/combine", apiMiddleware.ThenFunc(app.apiCombineTempShare).(http.HandlerFunc))
+
 	mux.Get("/about", dynamicMiddleware.ThenFunc(app.about).(http.HandlerFunc))
 
+	// Probes and CSP reports don't need sessions or CSRF protection
+	mux.Post("/csp-report", app.cspReport)
+	mux.Get("/healthz", app.healthz)
+	mux.Get("/readyz", app.readyz)
+
 	// TODO: Add rate limiting to the http file server
-	fileServer := httpfileserver.New("/static/", "./ui/static/")
-	mux.Get("/static/*", http.StripPrefix("/static", fileServer).(http.HandlerFunc))
+	mux.Get("/static/*", http.StripPrefix("/static", app.staticFiles()).(http.HandlerFunc))
 
 	return standardMiddleware.Then(mux)
 }
+
+// adminRoutes are served on the separate admin listener, which should only be reachable
+// from inside the deployment (e.g. by the Prometheus server scraping /metrics, the
+// orchestrator probing /healthz and /readyz, or an operator viewing the dashboard at /admin).
+// It serves TLS with the certificate of the main listener, unless the server runs plain HTTP
+// behind a proxy, in which case the admin pages aren't served since logging in would send
+// passwords in the clear.
+func (app *application) adminRoutes() http.Handler {
+
+	mux := chi.NewRouter()
+	mux.Get("/metrics", promhttp.HandlerFor(app.metrics.registry, promhttp.HandlerOpts{}).(http.HandlerFunc))
+	mux.Get("/healthz", app.healthz)
+	mux.Get("/readyz", app.readyz)
+
+	if !app.serverConfig.plainHTTP {
+		// The admin pages take logging in with a password and a TOTP code, except the login pages.
+		// Their session and CSRF cookies are separate from those of the public pages.
+		adminMiddleware := alice.New(requestID, app.logRequest, secureHeaders, noStore, app.rateLimit, app.enableAdminSession, app.localize, app.noAdminCSRF)
+		signedInMiddleware := adminMiddleware.Append(app.requireAdmin)
+
+		mux.Get("/admin/login", adminMiddleware.ThenFunc(app.adminLoginForm).(http.HandlerFunc))
+		mux.Post("/admin/login", adminMiddleware.Append(app.limitLogins).ThenFunc(app.adminLogin).(http.HandlerFunc))
+		mux.Get("/admin/login/totp", adminMiddleware.ThenFunc(app.adminTOTPForm).(http.HandlerFunc))
+		mux.Post("/admin/login/totp", adminMiddleware.ThenFunc(app.adminTOTP).(http.HandlerFunc))
+		mux.Post("/admin/logout", signedInMiddleware.ThenFunc(app.adminLogout).(http.HandlerFunc))
+
+		mux.Get("/admin", signedInMiddleware.ThenFunc(app.adminDashboard).(http.HandlerFunc))
+		mux.Get("/static/*", http.StripPrefix("/static", app.staticFiles()).(http.HandlerFunc))
+	}
+
+	return alice.New(app.recoverPanic).Then(mux)
+}
@@ -6,42 +6,115 @@ import (
 	"crypto/x509"
 	"errors"
 	"fmt"
+	"io/fs"
 	"io/ioutil"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
 )
 
 // initializeServer defines the necessary settings for TLS in the tls.Config struct, configures
-// the http.Server struct, then starts the server with support for graceful shutdown
+// the http.Server struct, then starts the server with support for graceful shutdown.
+// In plain HTTP mode TLS is left to the proxy in front of the server.
 func (app *application) initializeServer() error {
 
-	tlsConfig := &tls.Config{
-		MinVersion:               tls.VersionTLS12,
-		CurvePreferences:         []tls.CurveID{tls.CurveP521, tls.CurveP384, tls.CurveP256},
-		PreferServerCipherSuites: true,
-		CipherSuites: []uint16{
-			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
-			tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
-			tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
-			tls.TLS_RSA_WITH_AES_256_CBC_SHA,
-		},
+	var tlsConfig *tls.Config
+	if !app.serverConfig.plainHTTP {
+		certificates, err := newCertReloader(app.serverConfig.TLS.certFile, app.serverConfig.TLS.keyFile)
+		if err != nil {
+			return err
+		}
+		app.watchCertificates(certificates)
+
+		tlsConfig = newTLSConfig(app.serverConfig.TLS.minVersion, certificates)
 	}
 
 	srv := &http.Server{
 		Addr:           fmt.Sprintf(":%d", app.serverConfig.port),
-		ErrorLog:       app.errorLog,
+		ErrorLog:       slog.NewLogLogger(app.logger.Handler(), slog.LevelError),
 		Handler:        app.routes(),
 		TLSConfig:      tlsConfig,
-		TLSNextProto:   make(map[string]func(*http.Server, *tls.Conn, http.Handler), 0),
 		IdleTimeout:    time.Minute,
 		ReadTimeout:    5 * time.Second,
 		WriteTimeout:   10 * time.Second,
 		MaxHeaderBytes: 520192, // 0.5MB minus 4096 bytes that Go adds on top automatically
 	}
 
+	// HTTP/2, HTTP/3 and the redirect listener only apply when the server terminates TLS itself
+	var h3Srv *http3.Server
+	var redirectSrv *http.Server
+	if tlsConfig != nil {
+		if err := configureHTTP2(srv); err != nil {
+			return err
+		}
+
+		if app.serverConfig.http3 {
+			h3Srv = newHTTP3Server(srv.Addr, tlsConfig, srv.Handler, srv.MaxHeaderBytes)
+			srv.Handler = app.advertiseHTTP3(h3Srv, srv.Handler)
+
+			app.runInBackground(func() {
+				app.logger.Info("starting HTTP/3 server", "addr", h3Srv.Addr)
+
+				if err := h3Srv.ListenAndServe(); !errors.Is(err, quic.ErrServerClosed) && !errors.Is(err, http.ErrServerClosed) {
+					app.logger.Error("HTTP/3 server stopped", "error", err)
+				}
+			})
+		}
+
+		if app.serverConfig.redirectAddr != "" {
+			redirectSrv = newRedirectServer(app.serverConfig.redirectAddr, app.serverConfig.port)
+			redirectSrv.ErrorLog = srv.ErrorLog
+
+			app.runInBackground(func() {
+				app.logger.Info("starting redirect server", "addr", redirectSrv.Addr)
+
+				if err := redirectSrv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+					app.logger.Error("redirect server stopped", "error", err)
+				}
+			})
+		}
+	}
+
+	// The admin listener serves TLS along with the main listener, and the admin pages only then,
+	// see adminRoutes. It must not be exposed outside the deployment either way.
+	var adminSrv *http.Server
+	if app.serverConfig.adminAddr != "" {
+		adminSrv = &http.Server{
+			Addr:         app.serverConfig.adminAddr,
+			ErrorLog:     srv.ErrorLog,
+			Handler:      app.adminRoutes(),
+			IdleTimeout:  time.Minute,
+			ReadTimeout:  5 * time.Second,
+			WriteTimeout: 10 * time.Second,
+		}
+		if tlsConfig != nil {
+			adminSrv.TLSConfig = tlsConfig.Clone()
+		} else {
+			app.logger.Warn("admin pages disabled, since the admin listener can't serve TLS in plain HTTP mode")
+		}
+
+		app.runInBackground(func() {
+			app.logger.Info("starting admin server", "addr", adminSrv.Addr, "tls", adminSrv.TLSConfig != nil)
+
+			var err error
+			if adminSrv.TLSConfig != nil {
+				err = adminSrv.ListenAndServeTLS("", "")
+			} else {
+				err = adminSrv.ListenAndServe()
+			}
+			if !errors.Is(err, http.ErrServerClosed) {
+				app.logger.Error("admin server stopped", "error", err)
+			}
+		})
+	}
+
 	// This channel will be used to receive any errors from inside the shutdown goroutine
 	shutdownError := make(chan error)
 
@@ -56,12 +129,30 @@ func (app *application) initializeServer() error {
 		// Block on the channel until a signal is received, then store it in a variable
 		sig := <-quit
 
-		app.infoLog.Println("Shutting down server with signal", sig.String())
+		app.logger.Info("shutting down server", "signal", sig.String())
+
+		// Fail readiness checks first, and give the orchestrator time to notice
+		// and stop routing new traffic here before connections are drained
+		app.health.shuttingDown.Store(true)
+		time.Sleep(shutdownDrainDelay)
 
 		// This context will timeout after 20 seconds have passed
 		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
 		defer cancel()
 
+		if adminSrv != nil {
+			adminSrv.Shutdown(ctx)
+		}
+
+		if redirectSrv != nil {
+			redirectSrv.Shutdown(ctx)
+		}
+
+		// Clients stopped being sent to HTTP/3 when the drain delay began, see advertiseHTTP3
+		if h3Srv != nil {
+			h3Srv.Close()
+		}
+
 		// Attempt to gracefully shutdown the server within the 20 second timeout context
 		// srv.Shutdown() will return any errors if necessary, which will be sent into our
 		// shutdownError channel outside of the goroutine
@@ -70,55 +161,95 @@ func (app *application) initializeServer() error {
 		// The goroutine will then exit successfully (status code 0)
 	}()
 
-	app.infoLog.Printf("Starting server at :%d in %s mode", app.serverConfig.port, app.serverConfig.env)
+	listener, err := app.listen(srv.Addr)
+	if err != nil {
+		return err
+	}
+
+	app.logger.Info("starting server", "addr", listener.Addr().String(), "tls", tlsConfig != nil, "env", app.serverConfig.env)
 
-	// Start the server and look for any errors. If the error is not related to the server being shutdown, return it
-	if err := srv.ListenAndServeTLS("./tls/cert.pem", "./tls/key.pem"); !errors.Is(err, http.ErrServerClosed) {
+	// Start the server and look for any errors. If the error is not related to the server being shutdown, return it.
+	// The certificate is served by tlsConfig.GetCertificate, so that it can be reloaded.
+	if tlsConfig != nil {
+		err = srv.ServeTLS(listener, "", "")
+	} else {
+		err = srv.Serve(listener)
+	}
+	if !errors.Is(err, http.ErrServerClosed) {
 		return err
 	}
 
 	// Block on the error channel until a value is received, if it is non-nil return it
-	err := <-shutdownError
+	err = <-shutdownError
 	if err != nil {
 		return err
 	}
 
 	// Since the value received from err was nil (if it was non-nil we wouldn't reach this line),
 	// print to the console that the server shutdown was successful.
-	app.infoLog.Printf("Stopped server at %s\n", srv.Addr)
+	app.logger.Info("stopped server", "addr", srv.Addr)
 
 	return nil
 }
 
+// listen listens on the unix socket if one is configured, or on the TCP address addr otherwise.
+// A socket left behind by a previous run is removed first.
+func (app *application) listen(addr string) (net.Listener, error) {
+
+	path := app.serverConfig.unixSocket
+	if path == "" {
+		return net.Listen("tcp", addr)
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Let the proxy connect if it runs as another user of the same group
+	if err := os.Chmod(path, 0660); err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	return listener, nil
+}
+
 // initializeClient creates a new HTTPS client using our public cert, and appends
 // it to our main application struct.
 // This client is only used for sending backend requests to APIs,
 // e.g.: sending a POST request to the reCAPTCHA API for verifying recaptcha responses
 func (app *application) initializeClient(certDir string) error {
 
-	publicCert, err := ioutil.ReadFile(certDir)
+	// Append our public cert to our pre-existing system certificate pool, if there is one
+	rootCAs, err := x509.SystemCertPool()
 	if err != nil {
 		return err
 	}
 
-	// Append our public cert to our pre-existing system certificate pool
-	rootCAs, err := x509.SystemCertPool()
-	if err != nil {
-		return err
+	if certDir != "" {
+		publicCert, err := ioutil.ReadFile(certDir)
+		if err != nil {
+			return err
+		}
+		rootCAs.AppendCertsFromPEM(publicCert)
 	}
-	rootCAs.AppendCertsFromPEM(publicCert)
 
 	// InsecureSkipVerify set to false ensures that we must verify
 	// that our request's response actually came from the server we requested it from.
 	// If this is set to true, we do not verify the request came from who we think it did,
 	// which allows for man-in-the-middle attacks.
 	httpsClient := &http.Client{
-		Transport: &http.Transport{
+		Transport: app.traceTransport(&http.Transport{
 			TLSClientConfig: &tls.Config{
 				RootCAs:            rootCAs,
 				InsecureSkipVerify: false,
 			},
-		},
+		}),
 	}
 
 	// Append the http.Client to our application struct.
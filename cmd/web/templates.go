@@ -2,10 +2,12 @@ package main
 
 import (
 	"html/template"
-	"path/filepath"
+	"io/fs"
+	"path"
 	"time"
 
 	"github.com/matthewlmitchell/tempshare/pkg/forms"
+	"github.com/matthewlmitchell/tempshare/pkg/i18n"
 	"github.com/matthewlmitchell/tempshare/pkg/models"
 )
 
@@ -13,12 +15,22 @@ type templateData struct {
 	CurrentYear int
 	SiteKey     string
 	CSRFToken   string
+	CSPNonce    string
 	Flash       string
+	MailEnabled bool
 	TempShare   *models.TempShare
+	Recipients  []*models.TempShare
+	Request     *models.Request
+	Views       []*models.View
 	Form        *forms.Form
+	Dashboard   *dashboard
+	Admin       *models.Admin   // Admin signed in to the admin pages, if any
+	Locale      string          // Locale the page is displayed in, e.g. "de"
+	Locales     []*i18n.Catalog // Every locale the page can be displayed in
 }
 
-// FormattedDate accepts a time.Time and returns the time adjusted to UTC.
+// FormattedDate accepts a time.Time and returns the time adjusted to UTC, in English for
+// emails and logs (pages use the date format of their locale, see i18n.Catalog.Date).
 // If the time given is zero, an empty string is returned
 func FormattedDate(t time.Time) string {
 	if t.IsZero() {
@@ -28,47 +40,48 @@ func FormattedDate(t time.Time) string {
 	return t.UTC().Format("Jan 02 2006 at 15:04")
 }
 
+// list returns its arguments as a slice, for ranging over a fixed set of values in a template
+func list(values ...string) []string {
+	return values
+}
+
 // This template.FuncMap{} allows us to call Golang functions
 // inside our template files, e.g.: {{formattedDate .VariableName}}
 var functions = template.FuncMap{
 	"formattedDate": FormattedDate,
+	"list":          list,
 }
 
-// initTemplateCache accepts a directory and returns a map that points
-// file names to parsed template.Template objects.
-func initTemplateCache(dir string) (map[string]*template.Template, error) {
+// initTemplateCache accepts a file system holding the templates at its root, the static assets
+// they link to and the catalog they are translated with, and returns a map that points file
+// names to parsed template.Template objects.
+func initTemplateCache(fsys fs.FS, static *assets, catalog *i18n.Catalog) (map[string]*template.Template, error) {
 
 	// Create a new map to hold templates as a cache
 	cache := map[string]*template.Template{}
 
-	// Search the directory and return all filepaths ending in ".page.tmpl"
-	pages, err := filepath.Glob(filepath.Join(dir, "*.page.tmpl"))
+	// Search the file system and return all filepaths ending in ".page.tmpl"
+	pages, err := fs.Glob(fsys, "*.page.tmpl")
 	if err != nil {
 		return nil, err
 	}
 
-	// For every page template found in the directory:
+	// For every page template found in the file system:
 	for _, page := range pages {
-		fileName := filepath.Base(page)
-
-		// .New(name).Funcs(functions).ParseFiles(page) will be used
-		// when we have some functions that need to be executed inside of the template
-		// functions is a template.FuncMap{}
-
-		// Create a new HTML template with the filename above
-		templateParsed, err := template.New(fileName).Funcs(functions).ParseFiles(page)
-		if err != nil {
-			return nil, err
-		}
-
-		// Locate any layout templates, parse them, and add them to the set of templates
-		templateParsed, err = templateParsed.ParseGlob(filepath.Join(dir, "*.layout.tmpl"))
-		if err != nil {
-			return nil, err
-		}
-
-		// The same as above, but for partial templates
-		templateParsed, err = templateParsed.ParseGlob(filepath.Join(dir, "*.partial.tmpl"))
+		fileName := path.Base(page)
+
+		// Create a new HTML template with the filename above, with our template functions,
+		// {{asset "css/main.css"}} returning the cache busting URL of a static file, and
+		// {{t "Home"}} and {{formattedDate .Created}} translating text and dates.
+		// Any layout and partial templates are parsed into the same set of templates.
+		templateParsed, err := template.New(fileName).
+			Funcs(functions).
+			Funcs(template.FuncMap{
+				"asset":         static.url,
+				"t":             catalog.Translate,
+				"formattedDate": catalog.Date,
+			}).
+			ParseFS(fsys, page, "*.layout.tmpl", "*.partial.tmpl")
 		if err != nil {
 			return nil, err
 		}
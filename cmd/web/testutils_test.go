@@ -1,21 +1,30 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"fmt"
 	"html"
+	"io"
 	"io/ioutil"
-	"log"
+	"log/slog"
+	"mime/multipart"
 	"net/http"
 	"net/http/cookiejar"
 	"net/http/httptest"
+	"net/textproto"
 	"net/url"
 	"regexp"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/golangcollege/sessions"
 	"github.com/gorilla/securecookie"
+	"github.com/matthewlmitchell/tempshare/pkg/logging"
 	"github.com/matthewlmitchell/tempshare/pkg/models/mock"
+	"github.com/matthewlmitchell/tempshare/ui"
+	"go.opentelemetry.io/otel/trace/noop"
 )
 
 type testServer struct {
@@ -26,7 +35,7 @@ var regexCSRFToken = regexp.MustCompile(`<input type="hidden" name="gorilla.csrf
 
 func newTestApplication(t *testing.T) *application {
 
-	templateCache, err := initTemplateCache("./../../ui/html")
+	loadedUI, err := loadUI(ui.Files)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -36,14 +45,27 @@ func newTestApplication(t *testing.T) *application {
 	session.Secure = true
 	session.SameSite = http.SameSiteLaxMode
 
+	adminSession := sessions.New(securecookie.GenerateRandomKey(32))
+	adminSession.Lifetime = 12 * time.Hour
+	adminSession.Secure = true
+	adminSession.SameSite = http.SameSiteLaxMode
+
 	// TODO: Add database support
 	return &application{
-		errorLog:      log.New(ioutil.Discard, "", 0),
-		infoLog:       log.New(ioutil.Discard, "", 0),
-		session:       session,
-		serverConfig:  config{env: "testing"},
-		templateCache: templateCache,
-		tempShare:     &mock.TempShareModel{},
+		logger:         logging.New(io.Discard, slog.LevelInfo),
+		metrics:        newMetrics(),
+		tracerProvider: noop.NewTracerProvider(),
+		session:        session,
+		adminSession:   adminSession,
+		csrfKeys:       [][]byte{[]byte("0123456789abcdef0123456789abcdef")},
+		serverConfig:   config{env: "testing", baseURL: "https://placeholder.com"},
+		ui:             loadedUI,
+		database:       &mockDatabase{},
+		admins:         &mock.AdminModel{},
+		webhooks:       &mock.WebhookModel{},
+		requests:       &mock.RequestModel{},
+		tempShare:      &mock.TempShareModel{},
+		loginLimiter:   newRateLimiter(loginAttemptsPerMinute),
 	}
 }
 
@@ -128,6 +150,69 @@ func (ts *testServer) postForm(t *testing.T, urlPath string, data url.Values) (i
 	return response.StatusCode, response.Header, responseBody
 }
 
+// postMultipart posts data as multipart/form-data, along with a file of the given content type
+// uploaded as the form field fileField, if content isn't nil
+func (ts *testServer) postJSON(t *testing.T, urlPath string, contentType string, body string) (int, http.Header, []byte) {
+
+	response, err := ts.Client().Post(ts.URL+urlPath, contentType, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	responseBody, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer response.Body.Close()
+
+	return response.StatusCode, response.Header, responseBody
+}
+
+func (ts *testServer) postMultipart(t *testing.T, urlPath string, data url.Values, fileField string, contentType string, content []byte) (int, http.Header, []byte) {
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+
+	for name, values := range data {
+		for _, value := range values {
+			if err := writer.WriteField(name, value); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	if content != nil {
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="secret.txt"`, fileField))
+		header.Set("Content-Type", contentType)
+
+		part, err := writer.CreatePart(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Write(content)
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	response, err := ts.Client().Post(ts.URL+urlPath, writer.FormDataContentType(), body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	responseBody, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer response.Body.Close()
+
+	return response.StatusCode, response.Header, responseBody
+}
+
 func extractCSRFToken(t *testing.T, response []byte) string {
 
 	// FindSubmatch returns [][]byte with index being the entire
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+
+	"github.com/XSAM/otelsql"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// propagator extracts the trace context (and baggage) of incoming requests from their headers
+var propagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+// initializeTracer returns a TracerProvider which exports spans to the OTLP/HTTP collector at
+// endpoint (e.g. http://localhost:4318), or one which records nothing if endpoint is empty.
+// The returned function flushes any spans which haven't been exported yet.
+func initializeTracer(ctx context.Context, endpoint string) (trace.TracerProvider, func(context.Context) error, error) {
+
+	if endpoint == "" {
+		return noop.NewTracerProvider(), func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(endpoint))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName("tempshare"),
+		semconv.ServiceVersion(version),
+	))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tracerProvider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+
+	return tracerProvider, tracerProvider.Shutdown, nil
+}
+
+// openDatabase opens db with every query traced as a child span of the span in its context,
+// i.e. the span of the request that the TempShareModel (or other model) method was called for
+func openDatabase(dsn string, tracerProvider trace.TracerProvider) (*sql.DB, error) {
+	return otelsql.Open("mysql", dsn,
+		otelsql.WithTracerProvider(tracerProvider),
+		otelsql.WithAttributes(semconv.DBSystemMySQL),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{
+			DisableErrSkip:       true,
+			OmitConnResetSession: true,
+			OmitRows:             true,
+		}),
+	)
+}
+
+// traceRequest starts a span for each request, continuing the trace of the caller if its
+// headers carry one. The span is renamed after the matched route by instrumentRoute.
+// Probes are not traced, since they would drown out everything else.
+func (app *application) traceRequest(next http.Handler) http.Handler {
+	return otelhttp.NewHandler(next, "HTTP request",
+		otelhttp.WithTracerProvider(app.tracerProvider),
+		otelhttp.WithPropagators(propagator),
+		otelhttp.WithFilter(func(r *http.Request) bool {
+			return r.URL.Path != "/healthz" && r.URL.Path != "/readyz"
+		}),
+	)
+}
+
+// traceTransport traces the outbound requests made with base, e.g. to the reCAPTCHA API.
+// The trace context isn't sent along with them, since they leave the deployment.
+func (app *application) traceTransport(base http.RoundTripper) http.RoundTripper {
+	return otelhttp.NewTransport(base,
+		otelhttp.WithTracerProvider(app.tracerProvider),
+		otelhttp.WithPropagators(propagation.NewCompositeTextMapPropagator()),
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return r.Method + " " + r.URL.Host
+		}),
+	)
+}
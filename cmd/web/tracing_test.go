@@ -0,0 +1,103 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTraceRequest(t *testing.T) {
+	app := newTestApplication(t)
+
+	exporter := tracetest.NewInMemoryExporter()
+	app.tracerProvider = sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	testCases := []struct {
+		name          string
+		urlPath       string
+		expectedSpans int
+		expectedName  string
+	}{
+		{"Traced route", "/view?token=MUPPH5PDKV7AGCUAAEERL5ARIXICVVGYLRIV365X5XSV3EKISAXQ", 1, "GET /view"},
+		{"Unmatched route", "/does-not-exist", 1, "GET unmatched"},
+		{"Probe", "/healthz", 0, ""},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			exporter.Reset()
+
+			responseRecorder := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodGet, testCase.urlPath, nil)
+			request.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+			app.routes().ServeHTTP(responseRecorder, request)
+
+			spans := exporter.GetSpans()
+			if len(spans) != testCase.expectedSpans {
+				t.Fatalf("Expected %d spans, received %d", testCase.expectedSpans, len(spans))
+			}
+			if testCase.expectedSpans == 0 {
+				return
+			}
+
+			span := spans[0]
+			if span.Name != testCase.expectedName {
+				t.Errorf("Expected span name %s, received %s", testCase.expectedName, span.Name)
+			}
+
+			// The span must continue the trace of the incoming request
+			if traceID := span.SpanContext.TraceID().String(); traceID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+				t.Errorf("Expected trace ID %s, received %s", "4bf92f3577b34da6a3ce929d0e0e4736", traceID)
+			}
+			if parentID := span.Parent.SpanID().String(); parentID != "00f067aa0ba902b7" {
+				t.Errorf("Expected parent span ID %s, received %s", "00f067aa0ba902b7", parentID)
+			}
+
+			for _, attr := range span.Attributes {
+				if strings.Contains(attr.Value.Emit(), "MUPPH5PDKV7AGCUAAEERL5ARIXICVVGYLRIV365X5XSV3EKISAXQ") {
+					t.Errorf("Expected no tokens in the span attributes, received %s=%s", attr.Key, attr.Value.Emit())
+				}
+			}
+		})
+	}
+}
+
+func TestTraceTransport(t *testing.T) {
+	app := newTestApplication(t)
+
+	exporter := tracetest.NewInMemoryExporter()
+	app.tracerProvider = sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	var traceparent string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+	}))
+	defer upstream.Close()
+
+	client := &http.Client{Transport: app.traceTransport(http.DefaultTransport)}
+
+	response, err := client.Get(upstream.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("Expected 1 span, received %d", len(spans))
+	}
+
+	expectedName := "GET " + strings.TrimPrefix(upstream.URL, "http://")
+	if spans[0].Name != expectedName {
+		t.Errorf("Expected span name %s, received %s", expectedName, spans[0].Name)
+	}
+
+	if traceparent != "" {
+		t.Errorf("Expected no trace context to be sent, received %s", traceparent)
+	}
+}
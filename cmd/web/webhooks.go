@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/matthewlmitchell/tempshare/pkg/models"
+	"github.com/matthewlmitchell/tempshare/pkg/webhook"
+)
+
+const (
+	webhookPollInterval = 5 * time.Second  // How often the delivery queue is checked for due deliveries
+	webhookBatchSize    = 10               // Maximum number of deliveries attempted concurrently per poll
+	webhookLease        = 2 * time.Minute  // How long a claimed delivery is hidden from other workers
+	expirySweepInterval = 1 * time.Minute  // How often expired TempShares are looked for
+	webhookTimeout      = 10 * time.Second // Timeout of a single delivery attempt
+)
+
+// emitEvent queues a webhook.Event about tempShare for every destination subscribed to eventType.
+// The event is persisted in the delivery queue rather than sent directly, so it survives
+// a restart of the server and is retried by the webhook workers if delivery fails.
+func (app *application) emitEvent(ctx context.Context, eventType string, tempShare *models.TempShare, client string) {
+
+	if len(app.webhookDestinations) == 0 {
+		return
+	}
+
+	payload, err := json.Marshal(&webhook.Event{
+		Type:           eventType,
+		Share:          hex.EncodeToString(tempShare.URLToken[:8]),
+		Time:           time.Now().UTC(),
+		Client:         client,
+		ViewsRemaining: tempShare.ViewLimit - tempShare.Views,
+	})
+	if err != nil {
+		app.loggerFrom(ctx).Error("failed to encode webhook event", "error", err)
+		return
+	}
+
+	for _, destination := range app.webhookDestinations {
+		if !destination.Subscribed(eventType) {
+			continue
+		}
+
+		if err := app.webhooks.Enqueue(ctx, destination.Name, eventType, payload); err != nil {
+			app.loggerFrom(ctx).Error("failed to queue webhook event", "destination", destination.Name, "event", eventType, "error", err)
+		}
+	}
+}
+
+// startWebhookWorkers starts a background loop which periodically claims due deliveries
+// from the queue and attempts each of them in its own goroutine, until ctx is done.
+func (app *application) startWebhookWorkers(ctx context.Context) {
+
+	if len(app.webhookDestinations) == 0 {
+		return
+	}
+
+	app.runEvery(ctx, "webhooks", webhookPollInterval, func() {
+		deliveries, err := app.webhooks.Claim(context.Background(), webhookBatchSize, webhookLease)
+		if err != nil {
+			app.logger.Error("failed to claim webhook deliveries", "error", err)
+			return
+		}
+
+		// Deliveries in flight are waited for on shutdown too, rather than left to their lease
+		for _, delivery := range deliveries {
+			delivery := delivery
+			app.background.Add(1)
+			app.runInBackground(func() {
+				defer app.background.Done()
+				app.attemptDelivery(delivery)
+			})
+		}
+	})
+}
+
+// startExpirySweeper starts a background loop which periodically sweeps the database for
+// TempShares which have expired, clearing their text and emitting an event for each, until
+// ctx is done.
+func (app *application) startExpirySweeper(ctx context.Context) {
+
+	app.runEvery(ctx, "expiry sweeper", expirySweepInterval, func() {
+		expired, err := app.tempShare.SweepExpired(context.Background())
+		if err != nil {
+			app.logger.Error("failed to sweep expired shares", "error", err)
+			return
+		}
+
+		app.metrics.sharesExpired.Add(float64(len(expired)))
+
+		for _, tempShare := range expired {
+			app.emitEvent(context.Background(), webhook.EventExpired, tempShare, "")
+		}
+	})
+}
+
+// attemptDelivery sends a single delivery to its destination, then records the outcome:
+// delivered, rescheduled with exponential backoff, or failed after webhook.MaxAttempts.
+func (app *application) attemptDelivery(delivery *models.Delivery) {
+
+	ctx := context.Background()
+	logger := app.logger.With("delivery", delivery.ID, "destination", delivery.Destination)
+
+	var destination *webhook.Destination
+	for _, candidate := range app.webhookDestinations {
+		if candidate.Name == delivery.Destination {
+			destination = candidate
+			break
+		}
+	}
+
+	// The destination may have been removed from the configuration since the event was queued
+	if destination == nil {
+		logger.Error("unknown webhook destination")
+		if err := app.webhooks.Fail(ctx, delivery.ID); err != nil {
+			logger.Error("failed to record webhook failure", "error", err)
+		}
+		return
+	}
+
+	err := webhook.Deliver(app.webhookClient, destination, delivery.ID, delivery.Event, delivery.Payload)
+	if err == nil {
+		if err := app.webhooks.Delivered(ctx, delivery.ID); err != nil {
+			logger.Error("failed to record webhook delivery", "error", err)
+		}
+		return
+	}
+
+	logger.Warn("webhook delivery failed", "attempt", delivery.Attempts+1, "error", err)
+
+	if delivery.Attempts+1 >= webhook.MaxAttempts {
+		err = app.webhooks.Fail(ctx, delivery.ID)
+	} else {
+		err = app.webhooks.Retry(ctx, delivery.ID, webhook.Backoff(delivery.Attempts))
+	}
+	if err != nil {
+		logger.Error("failed to record webhook failure", "error", err)
+	}
+}
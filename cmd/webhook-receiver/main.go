@@ -0,0 +1,61 @@
+// webhook-receiver is a minimal server for verifying TempShare webhook deliveries locally.
+// It checks the signature of every delivery against the shared secret and logs the event.
+//
+// Usage:
+//
+//	go run ./cmd/webhook-receiver -addr :8081 -secret <destination secret>
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/matthewlmitchell/tempshare/pkg/webhook"
+)
+
+func main() {
+	addr := flag.String("addr", ":8081", "HTTP network address")
+	secret := flag.String("secret", os.Getenv("TEMPSHARE_WEBHOOK_SECRET"), "Secret shared with the webhook destination")
+	flag.Parse()
+
+	errorLog := log.New(os.Stderr, "ERROR\t", log.Ldate|log.Ltime)
+	infoLog := log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
+
+	if *secret == "" {
+		errorLog.Fatalln("a secret is required to verify deliveries")
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		payload, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+
+		err = webhook.Verify(*secret, r.Header.Get("X-TempShare-Signature"), payload, 5*time.Minute)
+		if err != nil {
+			errorLog.Printf("delivery %s rejected: %s", r.Header.Get("X-TempShare-Delivery"), err)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		infoLog.Printf("delivery %s (%s): %s", r.Header.Get("X-TempShare-Delivery"), r.Header.Get("X-TempShare-Event"), payload)
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	infoLog.Printf("Listening for webhook deliveries at %s", *addr)
+
+	errorLog.Fatal(http.ListenAndServe(*addr, mux))
+}
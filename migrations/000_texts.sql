@@ -0,0 +1,9 @@
+-- The schema before any migration, for new databases: apply every file of migrations/ in order
+CREATE TABLE IF NOT EXISTS texts (
+    urltoken BINARY(32) NOT NULL PRIMARY KEY,
+    text TEXT NOT NULL,
+    created DATETIME NOT NULL,
+    expires DATETIME NOT NULL,
+    views INTEGER NOT NULL,
+    viewlimit INTEGER NOT NULL
+);
@@ -0,0 +1,17 @@
+-- Management links and the view history of each share, with notifications to its creator
+
+-- Shares created before this migration have no management link, so they are given a random
+-- token which nobody knows the plain text of
+ALTER TABLE texts ADD COLUMN managetoken BINARY(32) NULL AFTER urltoken;
+UPDATE texts SET managetoken = UNHEX(SHA2(CONCAT(HEX(urltoken), RAND()), 256)) WHERE managetoken IS NULL;
+ALTER TABLE texts MODIFY COLUMN managetoken BINARY(32) NOT NULL,
+    ADD UNIQUE INDEX idx_texts_managetoken (managetoken);
+
+ALTER TABLE texts ADD COLUMN notify VARCHAR(254) NOT NULL DEFAULT '' AFTER text;
+
+CREATE TABLE IF NOT EXISTS views (
+    urltoken BINARY(32) NOT NULL,
+    viewed DATETIME NOT NULL,
+    client VARCHAR(255) NOT NULL,
+    INDEX idx_views_urltoken (urltoken)
+);
@@ -0,0 +1,20 @@
+-- Revocation, expiry notifications and the persistent queue of webhook deliveries
+
+ALTER TABLE texts ADD COLUMN revoked BOOLEAN NOT NULL DEFAULT FALSE AFTER viewlimit,
+    ADD COLUMN expirednotified BOOLEAN NOT NULL DEFAULT FALSE AFTER revoked;
+
+-- Shares which expired before this migration aren't reported as expired by the sweeper
+UPDATE texts SET expirednotified = TRUE WHERE expires <= UTC_TIMESTAMP();
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    destination VARCHAR(255) NOT NULL,
+    event VARCHAR(32) NOT NULL,
+    payload TEXT NOT NULL,
+    attempts INTEGER NOT NULL,
+    nextattempt DATETIME NOT NULL,
+    created DATETIME NOT NULL,
+    delivered DATETIME NULL,
+    failed BOOLEAN NOT NULL DEFAULT FALSE,
+    INDEX idx_webhook_deliveries_nextattempt (nextattempt)
+);
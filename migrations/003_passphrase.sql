@@ -0,0 +1,3 @@
+-- Split delivery, with a passphrase required to view
+
+ALTER TABLE texts ADD COLUMN passphrase VARBINARY(32) NOT NULL DEFAULT '' AFTER managetoken;
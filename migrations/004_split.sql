@@ -0,0 +1,4 @@
+-- Secrets split into parts with Shamir's secret sharing, each part a row linked by its group
+
+ALTER TABLE texts ADD COLUMN sharegroup VARBINARY(16) NOT NULL DEFAULT '' AFTER text,
+    ADD COLUMN threshold INTEGER NOT NULL DEFAULT 0 AFTER sharegroup;
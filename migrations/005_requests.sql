@@ -0,0 +1,12 @@
+-- Reverse shares, requesting a secret from someone else
+
+CREATE TABLE IF NOT EXISTS requests (
+    uploadtoken BINARY(32) NOT NULL PRIMARY KEY,
+    readtoken BINARY(32) NOT NULL,
+    text TEXT NULL,
+    created DATETIME NOT NULL,
+    expires DATETIME NOT NULL,
+    fulfilled DATETIME NULL,
+    viewed BOOLEAN NOT NULL DEFAULT FALSE,
+    UNIQUE INDEX idx_requests_readtoken (readtoken)
+);
@@ -0,0 +1,3 @@
+-- Time-locked shares, which can't be opened before notbefore
+
+ALTER TABLE texts ADD COLUMN notbefore DATETIME NULL AFTER expires;
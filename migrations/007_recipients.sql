@@ -0,0 +1,10 @@
+-- Shares sent to several named recipients, whose text is stored once in payloads
+
+ALTER TABLE texts ADD COLUMN recipient VARCHAR(64) NOT NULL DEFAULT '' AFTER threshold;
+
+CREATE TABLE IF NOT EXISTS payloads (
+    sharegroup VARBINARY(16) NOT NULL PRIMARY KEY,
+    managetoken BINARY(32) NOT NULL,
+    text TEXT NOT NULL,
+    UNIQUE INDEX idx_payloads_managetoken (managetoken)
+);
@@ -0,0 +1,16 @@
+-- Local admin accounts of the dashboard, with the counts of incorrect passwords and TOTP codes
+-- which lock them, and the generation of their sessions which logging out increments
+
+CREATE TABLE IF NOT EXISTS admins (
+    id INTEGER NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    username VARCHAR(64) NOT NULL,
+    passwordhash VARCHAR(255) NOT NULL,
+    totpsecret VARCHAR(64) NOT NULL,
+    lasttotpstep BIGINT NOT NULL DEFAULT 0,
+    failedtotpattempts INTEGER NOT NULL DEFAULT 0,
+    failedpasswordattempts INTEGER NOT NULL DEFAULT 0,
+    lockeduntil DATETIME NULL,
+    sessiongeneration INTEGER NOT NULL DEFAULT 0,
+    created DATETIME NOT NULL,
+    UNIQUE INDEX idx_admins_username (username)
+);
@@ -0,0 +1,182 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// Cheap parameters, so that the tests don't take a second per hash
+var testParams = &Argon2idParams{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestPassword(t *testing.T) {
+
+	hash, err := HashPassword("correct horse battery staple", testParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=1$") {
+		t.Errorf("Unexpected hash %q", hash)
+	}
+
+	otherHash, _ := HashPassword("correct horse battery staple", testParams)
+	if hash == otherHash {
+		t.Error("Expected hashes of the same password to be salted differently")
+	}
+
+	testCases := []struct {
+		name     string
+		hash     string
+		password string
+		expected bool
+		valid    bool
+	}{
+		{"Correct password", hash, "correct horse battery staple", true, true},
+		{"Incorrect password", hash, "Correct horse battery staple", false, true},
+		{"Empty password", hash, "", false, true},
+		{"Not argon2id", strings.Replace(hash, "argon2id", "argon2i", 1), "correct horse battery staple", false, false},
+		{"Wrong version", strings.Replace(hash, "v=19", "v=16", 1), "correct horse battery staple", false, false},
+		{"Truncated", hash[:strings.LastIndex(hash, "$")], "correct horse battery staple", false, false},
+		{"Empty", "", "", false, false},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			match, err := CheckPassword(testCase.hash, testCase.password)
+			if (err == nil) != testCase.valid {
+				t.Fatalf("Expected valid to be %t, received %v", testCase.valid, err)
+			}
+
+			if match != testCase.expected {
+				t.Errorf("Expected %t, received %t", testCase.expected, match)
+			}
+		})
+	}
+}
+
+// The secret and codes are those of RFC 6238 appendix B, truncated to six digits
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestMaxConcurrentHashes(t *testing.T) {
+
+	// Every slot is taken, as if MaxConcurrentHashes passwords were being hashed
+	for i := 0; i < MaxConcurrentHashes; i++ {
+		hashSlots <- struct{}{}
+	}
+
+	hashed := make(chan struct{})
+	go func() {
+		HashPassword("correct horse battery staple", testParams)
+		close(hashed)
+	}()
+
+	select {
+	case <-hashed:
+		t.Fatal("Expected the password to wait for a free slot")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	<-hashSlots
+
+	select {
+	case <-hashed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the password to be hashed once a slot was free")
+	}
+
+	for i := 1; i < MaxConcurrentHashes; i++ {
+		<-hashSlots
+	}
+}
+
+func TestTOTP(t *testing.T) {
+
+	testCases := []struct {
+		time     int64
+		expected string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.expected, func(t *testing.T) {
+			code, err := TOTP(rfcSecret, TOTPStep(time.Unix(testCase.time, 0)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if code != testCase.expected {
+				t.Errorf("Expected %s, received %s", testCase.expected, code)
+			}
+		})
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+
+	now := time.Unix(1111111109, 0)
+
+	testCases := []struct {
+		name         string
+		code         string
+		expectedStep int64
+		valid        bool
+	}{
+		{"Current code", "081804", TOTPStep(now), true},
+		{"Spaces are ignored", "081 804", TOTPStep(now), true},
+		{"Previous code", "", TOTPStep(now) - 1, true},
+		{"Next code", "", TOTPStep(now) + 1, true},
+		{"Too old", "", TOTPStep(now) - 2, false},
+		{"Wrong code", "123456", 0, false},
+		{"Too short", "81804", 0, false},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			code := testCase.code
+			if code == "" {
+				code, _ = TOTP(rfcSecret, testCase.expectedStep)
+			}
+
+			step, ok := ValidateTOTP(rfcSecret, code, now)
+			if ok != testCase.valid {
+				t.Fatalf("Expected valid to be %t, received %t", testCase.valid, ok)
+			}
+
+			if ok && step != testCase.expectedStep {
+				t.Errorf("Expected step %d, received %d", testCase.expectedStep, step)
+			}
+		})
+	}
+}
+
+func TestNewTOTPSecret(t *testing.T) {
+
+	secret, err := NewTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(secret) != 32 {
+		t.Errorf("Expected a 32 character secret, received %q", secret)
+	}
+
+	if _, err := TOTP(secret, 1); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+
+	expected := "otpauth://totp/TempShare:alice?digits=6&issuer=TempShare&period=30&secret=" + rfcSecret
+
+	if uri := TOTPURI("TempShare", "alice", rfcSecret); uri != expected {
+		t.Errorf("Expected %s, received %s", expected, uri)
+	}
+}
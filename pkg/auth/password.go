@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+var ErrInvalidHash = errors.New("auth: invalid password hash")
+
+// Argon2idParams are the cost parameters passwords are hashed with. They are stored in each
+// hash, so that they can be raised without invalidating the hashes made before.
+type Argon2idParams struct {
+	Memory      uint32 // In KiB
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultParams follow the second recommended option of RFC 9106, scaled down to 64 MiB
+var DefaultParams = &Argon2idParams{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 2,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// MaxConcurrentHashes is how many passwords are hashed at once. Each hash takes the memory
+// of its parameters, 64 MiB by default, so further hashes wait until one has finished.
+const MaxConcurrentHashes = 4
+
+var hashSlots = make(chan struct{}, MaxConcurrentHashes)
+
+// idKey derives the Argon2id key of password, once a slot in hashSlots is free
+func idKey(password string, salt []byte, params *Argon2idParams) []byte {
+
+	hashSlots <- struct{}{}
+	defer func() { <-hashSlots }()
+
+	return argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+}
+
+// HashPassword hashes password with Argon2id and a random salt, encoded in the PHC string
+// format, e.g.: $argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>
+func HashPassword(password string, params *Argon2idParams) (string, error) {
+
+	salt := make([]byte, params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := idKey(password, salt, params)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, params.Memory, params.Iterations,
+		params.Parallelism, base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// CheckPassword reports whether password matches the hash made by HashPassword, in
+// constant time. An error is only returned if the hash can't be decoded.
+func CheckPassword(hash string, password string) (bool, error) {
+
+	params, salt, key, err := decodeHash(hash)
+	if err != nil {
+		return false, err
+	}
+
+	otherKey := idKey(password, salt, params)
+
+	return subtle.ConstantTimeCompare(key, otherKey) == 1, nil
+}
+
+func decodeHash(hash string) (*Argon2idParams, []byte, []byte, error) {
+
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return nil, nil, nil, ErrInvalidHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, nil, nil, ErrInvalidHash
+	}
+
+	params := &Argon2idParams{}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return nil, nil, nil, ErrInvalidHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return nil, nil, nil, ErrInvalidHash
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return nil, nil, nil, ErrInvalidHash
+	}
+
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+
+	return params, salt, key, nil
+}
@@ -0,0 +1,359 @@
+package forms
+
+import (
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TimeLayouts are the accepted formats of time.Time fields. The first is what a
+// datetime-local input submits, the second is for scripted clients. Times without
+// a zone are taken to be UTC.
+var TimeLayouts = []string{"2006-01-02T15:04", time.RFC3339}
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	byteSizeType = reflect.TypeOf(ByteSize(0))
+	timeType     = reflect.TypeOf(time.Time{})
+	fileType     = reflect.TypeOf((*multipart.FileHeader)(nil))
+)
+
+// Bind copies the values of the form into the fields of dst, which must be a pointer to a
+// struct, validating them on the way. A field is bound if it has a form tag naming the form
+// field, followed by its rules, e.g. `form:"expires,required,oneof=1 3 7"`:
+//
+//	required      the field must not be blank, or for files, must be uploaded
+//	oneof=a b c   the field must be one of the values separated by spaces
+//	min=n, max=n  the length of strings in characters, the value of numbers, durations and
+//	              sizes, the number of values of slices, or the size of files (max only)
+//	email         the field must be an email address
+//	types=a/b c/d the content type of files, as declared by the client, must be one of those
+//	              separated by spaces
+//	pattern=re    the field must match the regular expression re, which must be the last rule
+//	              since it may contain commas
+//
+// The fields of dst may be a string, bool, int, time.Duration, ByteSize, time.Time (in one
+// of TimeLayouts), []string or *multipart.FileHeader. An error message is added to the form
+// for every invalid field, which is left as it was. Bind only returns an error if dst or
+// one of its tags can't be used, which is a bug rather than a bad request.
+func (f *Form) Bind(dst interface{}) error {
+
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("forms: Bind needs a pointer to a struct, not %T", dst)
+	}
+	v = v.Elem()
+
+	for i := 0; i < v.NumField(); i++ {
+		tag, ok := v.Type().Field(i).Tag.Lookup("form")
+		if !ok || tag == "-" {
+			continue
+		}
+
+		name, rules := parseTag(tag)
+
+		var err error
+		if v.Field(i).Type() == fileType {
+			err = f.bindFile(v.Field(i), name, rules)
+		} else {
+			err = f.bindValue(v.Field(i), name, rules)
+		}
+		if err != nil {
+			return fmt.Errorf("forms: field %s: %w", v.Type().Field(i).Name, err)
+		}
+	}
+
+	return nil
+}
+
+type rule struct {
+	name string
+	arg  string
+}
+
+// parseTag splits a form tag into the name of the form field and its rules
+func parseTag(tag string) (string, []rule) {
+
+	name, rest, _ := strings.Cut(tag, ",")
+
+	var rules []rule
+	for rest != "" {
+		var item string
+		if strings.HasPrefix(rest, "pattern=") {
+			item, rest = rest, ""
+		} else {
+			item, rest, _ = strings.Cut(rest, ",")
+		}
+
+		ruleName, arg, _ := strings.Cut(item, "=")
+		rules = append(rules, rule{name: ruleName, arg: arg})
+	}
+
+	return name, rules
+}
+
+// bindValue validates and parses the values of the form field name into field
+func (f *Form) bindValue(field reflect.Value, name string, rules []rule) error {
+
+	// Rules on the submitted text are checked before it is parsed, the others after
+	for _, rule := range rules {
+		switch rule.name {
+		case "required":
+			f.Required(name)
+		case "oneof":
+			f.PermittedValues(name, strings.Fields(rule.arg)...)
+		case "email":
+			f.MaxLength(name, 254)
+			f.MatchesPattern(name, EmailRX)
+		case "pattern":
+			pattern, err := compilePattern(rule.arg)
+			if err != nil {
+				return err
+			}
+			f.MatchesPattern(name, pattern)
+		case "min", "max":
+		default:
+			return fmt.Errorf("unknown rule %q", rule.name)
+		}
+	}
+
+	// A value which is already invalid isn't parsed, so only one error is reported for it
+	if len(f.Errors[name]) > 0 {
+		return nil
+	}
+
+	var parsed reflect.Value
+	var err error
+
+	if field.Kind() == reflect.Slice {
+		if field.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported type %s", field.Type())
+		}
+		parsed = reflect.ValueOf(append([]string(nil), f.Values[name]...))
+	} else {
+		parsed, err = f.parseValue(field.Type(), name, f.Get(name))
+		if err != nil || len(f.Errors[name]) > 0 {
+			return err
+		}
+	}
+
+	for _, rule := range rules {
+		if rule.name != "min" && rule.name != "max" {
+			continue
+		}
+
+		if err := f.checkBound(parsed, name, rule); err != nil {
+			return err
+		}
+	}
+
+	if len(f.Errors[name]) == 0 {
+		field.Set(parsed.Convert(field.Type()))
+	}
+
+	return nil
+}
+
+// parseValue parses value into the type t, returning the zero value if it is blank.
+// If value can't be parsed, an error message is added to the form field name.
+func (f *Form) parseValue(t reflect.Type, name string, value string) (reflect.Value, error) {
+
+	parsed := reflect.New(t).Elem()
+
+	switch {
+	case t == timeType:
+		if value == "" {
+			return parsed, nil
+		}
+		for _, layout := range TimeLayouts {
+			if date, err := time.Parse(layout, value); err == nil {
+				return reflect.ValueOf(date.UTC()), nil
+			}
+		}
+		f.Errors.Add(name, "This field must be a valid date and time")
+		return parsed, nil
+
+	case t == durationType:
+		if value == "" {
+			return parsed, nil
+		}
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			f.Errors.Add(name, "This field must be a duration, such as 90m or 24h")
+			return parsed, nil
+		}
+		return reflect.ValueOf(duration), nil
+
+	case t == byteSizeType:
+		if value == "" {
+			return parsed, nil
+		}
+		size, err := ParseByteSize(value)
+		if err != nil {
+			f.Errors.Add(name, "This field must be a size, such as 512KB or 10MB")
+			return parsed, nil
+		}
+		return reflect.ValueOf(size), nil
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		parsed.SetString(value)
+
+	case reflect.Bool:
+		if value == "" {
+			return parsed, nil
+		}
+		// Checkboxes submit "on" when they are checked
+		checked, err := strconv.ParseBool(value)
+		if value == "on" {
+			checked, err = true, nil
+		}
+		if err != nil {
+			f.Errors.Add(name, "This field is invalid.")
+			return parsed, nil
+		}
+		parsed.SetBool(checked)
+
+	case reflect.Int:
+		if value == "" {
+			return parsed, nil
+		}
+		number, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			f.Errors.Add(name, "This field must be a whole number")
+			return parsed, nil
+		}
+		parsed.SetInt(int64(number))
+
+	default:
+		return parsed, fmt.Errorf("unsupported type %s", t)
+	}
+
+	return parsed, nil
+}
+
+// checkBound checks the min or max rule against a parsed value. Strings are checked with
+// MinLength and MaxLength, the others by comparing numbers.
+func (f *Form) checkBound(parsed reflect.Value, name string, rule rule) error {
+
+	switch parsed.Kind() {
+	case reflect.String, reflect.Slice:
+		bound, err := strconv.Atoi(rule.arg)
+		if err != nil {
+			return fmt.Errorf("invalid %s=%q", rule.name, rule.arg)
+		}
+
+		switch {
+		case parsed.Kind() == reflect.String && rule.name == "min":
+			f.MinLength(name, bound)
+		case parsed.Kind() == reflect.String:
+			f.MaxLength(name, bound)
+		case rule.name == "min" && parsed.Len() > 0 && parsed.Len() < bound:
+			f.Errors.Addf(name, "This field must have at least %d values", bound)
+		case rule.name == "max" && parsed.Len() > bound:
+			f.Errors.Addf(name, "This field must not have more than %d values", bound)
+		}
+
+	case reflect.Int, reflect.Int64:
+		// The bound is parsed like a value, into a form of its own
+		boundForm := New(nil)
+		bound, err := boundForm.parseValue(parsed.Type(), rule.name, rule.arg)
+		if err != nil || !boundForm.Valid() {
+			return fmt.Errorf("invalid %s=%q", rule.name, rule.arg)
+		}
+
+		// Blank fields are left to the required rule
+		if f.Get(name) == "" {
+			return nil
+		}
+
+		switch {
+		case rule.name == "min" && parsed.Int() < bound.Int():
+			f.Errors.Addf(name, "This field must be at least %s", rule.arg)
+		case rule.name == "max" && parsed.Int() > bound.Int():
+			f.Errors.Addf(name, "This field must be at most %s", rule.arg)
+		}
+
+	default:
+		return fmt.Errorf("%s can't be used with %s", rule.name, parsed.Type())
+	}
+
+	return nil
+}
+
+// bindFile validates the first file uploaded as the form field name and sets field to it
+func (f *Form) bindFile(field reflect.Value, name string, rules []rule) error {
+
+	var file *multipart.FileHeader
+	if files := f.Files[name]; len(files) > 0 {
+		file = files[0]
+	}
+
+	for _, rule := range rules {
+		switch rule.name {
+		case "required":
+			if file == nil {
+				f.Errors.Add(name, "This field must not be blank")
+			}
+
+		case "max":
+			size, err := ParseByteSize(rule.arg)
+			if err != nil {
+				return fmt.Errorf("invalid max=%q", rule.arg)
+			}
+			if file != nil && file.Size > int64(size) {
+				f.Errors.Addf(name, "This file must not be larger than %s", rule.arg)
+			}
+
+		case "types":
+			if file == nil {
+				continue
+			}
+			mediaType, _, _ := mime.ParseMediaType(file.Header.Get("Content-Type"))
+			permitted := false
+			for _, option := range strings.Fields(rule.arg) {
+				if mediaType == option {
+					permitted = true
+				}
+			}
+			if !permitted {
+				f.Errors.Add(name, "This type of file is not allowed")
+			}
+
+		default:
+			return fmt.Errorf("%s can't be used with files", rule.name)
+		}
+	}
+
+	if file != nil && len(f.Errors[name]) == 0 {
+		field.Set(reflect.ValueOf(file))
+	}
+
+	return nil
+}
+
+// Patterns are compiled once, since the same tags are bound on every request
+var patterns sync.Map
+
+func compilePattern(expr string) (*regexp.Regexp, error) {
+
+	if pattern, ok := patterns.Load(expr); ok {
+		return pattern.(*regexp.Regexp), nil
+	}
+
+	pattern, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	patterns.Store(expr, pattern)
+
+	return pattern, nil
+}
@@ -0,0 +1,344 @@
+package forms
+
+import (
+	"mime/multipart"
+	"net/textproto"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testForm struct {
+	Text     string        `form:"text,required,min=2,max=10"`
+	Days     int           `form:"days,oneof=1 3 7"`
+	Parts    int           `form:"parts,min=2,max=10"`
+	Email    string        `form:"email,email"`
+	Code     string        `form:"code,pattern=^[A-Z]{2,4}$"`
+	Checked  bool          `form:"checked"`
+	Delay    time.Duration `form:"delay,min=1m,max=24h"`
+	Limit    ByteSize      `form:"limit,max=1MB"`
+	At       time.Time     `form:"at"`
+	Tags     []string      `form:"tag,max=2"`
+	Ignored  string
+	Excluded string `form:"-"`
+}
+
+func TestBind(t *testing.T) {
+
+	testCases := []struct {
+		name          string
+		values        url.Values
+		expectedField string // The field expected to be invalid, if any
+		expectedError string
+		check         func(*testForm) bool
+	}{
+		{
+			name:   "Valid",
+			values: url.Values{"text": {"Hello"}, "days": {"3"}, "parts": {"5"}, "checked": {"on"}, "tag": {"a", "b"}},
+			check: func(data *testForm) bool {
+				return data.Text == "Hello" && data.Days == 3 && data.Parts == 5 && data.Checked && len(data.Tags) == 2
+			},
+		},
+		{
+			name:          "Missing required field",
+			values:        url.Values{},
+			expectedField: "text",
+			expectedError: "This field must not be blank",
+		},
+		{
+			name:          "String too long",
+			values:        url.Values{"text": {strings.Repeat("A", 11)}},
+			expectedField: "text",
+			expectedError: "This field must contain less than 10 characters",
+		},
+		{
+			name:          "Value not permitted",
+			values:        url.Values{"text": {"Hello"}, "days": {"2"}},
+			expectedField: "days",
+			expectedError: "This field is invalid.",
+		},
+		{
+			name:          "Not a number",
+			values:        url.Values{"text": {"Hello"}, "parts": {"five"}},
+			expectedField: "parts",
+			expectedError: "This field must be a whole number",
+		},
+		{
+			name:          "Number below minimum",
+			values:        url.Values{"text": {"Hello"}, "parts": {"1"}},
+			expectedField: "parts",
+			expectedError: "This field must be at least 2",
+		},
+		{
+			name:          "Number above maximum",
+			values:        url.Values{"text": {"Hello"}, "parts": {"11"}},
+			expectedField: "parts",
+			expectedError: "This field must be at most 10",
+		},
+		{
+			name:          "Invalid email",
+			values:        url.Values{"text": {"Hello"}, "email": {"bob@"}},
+			expectedField: "email",
+			expectedError: "This field is invalid.",
+		},
+		{
+			name:          "Pattern not matched",
+			values:        url.Values{"text": {"Hello"}, "code": {"abc"}},
+			expectedField: "code",
+			expectedError: "This field is invalid.",
+		},
+		{
+			name:   "Pattern matched",
+			values: url.Values{"text": {"Hello"}, "code": {"ABC"}},
+			check:  func(data *testForm) bool { return data.Code == "ABC" },
+		},
+		{
+			name:          "Invalid checkbox",
+			values:        url.Values{"text": {"Hello"}, "checked": {"maybe"}},
+			expectedField: "checked",
+			expectedError: "This field is invalid.",
+		},
+		{
+			name:   "Duration",
+			values: url.Values{"text": {"Hello"}, "delay": {"90m"}},
+			check:  func(data *testForm) bool { return data.Delay == 90*time.Minute },
+		},
+		{
+			name:          "Invalid duration",
+			values:        url.Values{"text": {"Hello"}, "delay": {"soon"}},
+			expectedField: "delay",
+			expectedError: "This field must be a duration, such as 90m or 24h",
+		},
+		{
+			name:          "Duration too long",
+			values:        url.Values{"text": {"Hello"}, "delay": {"48h"}},
+			expectedField: "delay",
+			expectedError: "This field must be at most 24h",
+		},
+		{
+			name:   "Byte size",
+			values: url.Values{"text": {"Hello"}, "limit": {"512KiB"}},
+			check:  func(data *testForm) bool { return data.Limit == 512<<10 },
+		},
+		{
+			name:          "Byte size too large",
+			values:        url.Values{"text": {"Hello"}, "limit": {"2MB"}},
+			expectedField: "limit",
+			expectedError: "This field must be at most 1MB",
+		},
+		{
+			name:   "Time",
+			values: url.Values{"text": {"Hello"}, "at": {"2030-01-02T15:04"}},
+			check:  func(data *testForm) bool { return data.At.Equal(time.Date(2030, 1, 2, 15, 4, 0, 0, time.UTC)) },
+		},
+		{
+			name:          "Invalid time",
+			values:        url.Values{"text": {"Hello"}, "at": {"tomorrow"}},
+			expectedField: "at",
+			expectedError: "This field must be a valid date and time",
+		},
+		{
+			name:          "Too many values",
+			values:        url.Values{"text": {"Hello"}, "tag": {"a", "b", "c"}},
+			expectedField: "tag",
+			expectedError: "This field must not have more than 2 values",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			form := New(testCase.values)
+			data := &testForm{}
+
+			if err := form.Bind(data); err != nil {
+				t.Fatal(err)
+			}
+
+			if testCase.expectedField == "" {
+				if !form.Valid() {
+					t.Fatalf("Expected a valid form, received %v", form.Errors)
+				}
+				if testCase.check != nil && !testCase.check(data) {
+					t.Errorf("Unexpected fields %+v", data)
+				}
+				return
+			}
+
+			message := form.Errors.Get(testCase.expectedField)
+			if message == nil {
+				t.Fatalf("Expected an error for %s, received %v", testCase.expectedField, form.Errors)
+			}
+			if message.String() != testCase.expectedError {
+				t.Errorf("Expected %q, received %q", testCase.expectedError, message.String())
+			}
+		})
+	}
+}
+
+func TestBindInvalidTags(t *testing.T) {
+
+	testCases := []struct {
+		name string
+		dst  interface{}
+	}{
+		{"Not a pointer", testForm{}},
+		{"Unknown rule", &struct {
+			Text string `form:"text,unknown"`
+		}{}},
+		{"Invalid bound", &struct {
+			Number int `form:"number,min=two"`
+		}{}},
+		{"Bound on a bool", &struct {
+			Checked bool `form:"checked,max=1"`
+		}{}},
+		{"Unsupported type", &struct {
+			Ratio float64 `form:"ratio"`
+		}{}},
+		{"Invalid pattern", &struct {
+			Code string `form:"code,pattern=["`
+		}{}},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			form := New(url.Values{"text": {"A"}, "number": {"1"}, "checked": {"on"}, "ratio": {"1"}, "code": {"A"}})
+
+			if err := form.Bind(testCase.dst); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
+
+func TestBindFile(t *testing.T) {
+
+	type uploadForm struct {
+		File *multipart.FileHeader `form:"file,required,max=1KB,types=text/plain"`
+	}
+
+	file := func(size int64, contentType string) *multipart.FileHeader {
+		return &multipart.FileHeader{
+			Filename: "secret.txt",
+			Size:     size,
+			Header:   textproto.MIMEHeader{"Content-Type": {contentType}},
+		}
+	}
+
+	testCases := []struct {
+		name          string
+		files         []*multipart.FileHeader
+		expectedError string
+	}{
+		{"Valid", []*multipart.FileHeader{file(100, "text/plain; charset=utf-8")}, ""},
+		{"Missing", nil, "This field must not be blank"},
+		{"Too large", []*multipart.FileHeader{file(2000, "text/plain")}, "This file must not be larger than 1KB"},
+		{"Wrong type", []*multipart.FileHeader{file(100, "image/png")}, "This type of file is not allowed"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			form := NewMultipart(&multipart.Form{
+				Value: map[string][]string{},
+				File:  map[string][]*multipart.FileHeader{"file": testCase.files},
+			})
+			data := &uploadForm{}
+
+			if err := form.Bind(data); err != nil {
+				t.Fatal(err)
+			}
+
+			if testCase.expectedError == "" {
+				if !form.Valid() || data.File != testCase.files[0] {
+					t.Errorf("Expected the file to be bound, received %v", form.Errors)
+				}
+				return
+			}
+
+			message := form.Errors.Get("file")
+			if message == nil || message.String() != testCase.expectedError {
+				t.Errorf("Expected %q, received %v", testCase.expectedError, message)
+			}
+			if data.File != nil {
+				t.Error("Expected an invalid file not to be bound")
+			}
+		})
+	}
+}
+
+func TestFromJSON(t *testing.T) {
+
+	testCases := []struct {
+		name     string
+		body     string
+		expected url.Values
+		valid    bool
+	}{
+		{"Members", `{"text": "Hello", "days": 3, "checked": true, "tag": ["a", "b"], "email": null}`,
+			url.Values{"text": {"Hello"}, "days": {"3"}, "checked": {"true"}, "tag": {"a", "b"}}, true},
+		{"Nested object", `{"text": {"a": "b"}}`, nil, false},
+		{"Not an object", `["text"]`, nil, false},
+		{"Malformed", `{"text": `, nil, false},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			form, err := FromJSON(strings.NewReader(testCase.body))
+			if (err == nil) != testCase.valid {
+				t.Fatalf("Expected valid to be %t, received %v", testCase.valid, err)
+			}
+			if !testCase.valid {
+				return
+			}
+
+			if form.Values.Encode() != testCase.expected.Encode() {
+				t.Errorf("Expected %v, received %v", testCase.expected, form.Values)
+			}
+
+			// JSON is bound just like a form
+			data := &testForm{}
+			if err := form.Bind(data); err != nil {
+				t.Fatal(err)
+			}
+			if !form.Valid() || data.Days != 3 || !data.Checked {
+				t.Errorf("Unexpected fields %+v, errors %v", data, form.Errors)
+			}
+		})
+	}
+}
+
+func TestByteSize(t *testing.T) {
+
+	testCases := []struct {
+		input    string
+		expected ByteSize
+		valid    bool
+		text     string
+	}{
+		{"512", 512, true, "512B"},
+		{"10KB", 10000, true, "10KB"},
+		{"10kib", 10240, true, "10KiB"},
+		{"1 MiB", 1 << 20, true, "1MiB"},
+		{"3GB", 3000000000, true, "3GB"},
+		{"1.5MB", 0, false, ""},
+		{"-1", 0, false, ""},
+		{"MB", 0, false, ""},
+		{"99999999999GiB", 0, false, ""},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.input, func(t *testing.T) {
+			size, err := ParseByteSize(testCase.input)
+			if (err == nil) != testCase.valid {
+				t.Fatalf("Expected valid to be %t, received %v", testCase.valid, err)
+			}
+
+			if size != testCase.expected {
+				t.Errorf("Expected %d, received %d", testCase.expected, size)
+			}
+			if testCase.valid && size.String() != testCase.text {
+				t.Errorf("Expected %q, received %q", testCase.text, size.String())
+			}
+		})
+	}
+}
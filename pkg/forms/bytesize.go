@@ -0,0 +1,62 @@
+package forms
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ByteSize is a number of bytes, parsed from sizes such as "512KB" or "10MiB"
+type ByteSize int64
+
+// Units of ByteSize, decimal and binary, from the largest to the smallest
+var byteUnits = []struct {
+	suffix string
+	size   ByteSize
+}{
+	{"GiB", 1 << 30},
+	{"MiB", 1 << 20},
+	{"KiB", 1 << 10},
+	{"GB", 1000 * 1000 * 1000},
+	{"MB", 1000 * 1000},
+	{"KB", 1000},
+	{"B", 1},
+}
+
+// ParseByteSize parses a whole number of bytes optionally followed by a unit, e.g. "512",
+// "512B", "10KB" or "10KiB". Units are case insensitive.
+func ParseByteSize(s string) (ByteSize, error) {
+
+	s = strings.TrimSpace(s)
+	number, unit := s, ByteSize(1)
+
+	for _, byteUnit := range byteUnits {
+		if len(s) > len(byteUnit.suffix) && strings.EqualFold(s[len(s)-len(byteUnit.suffix):], byteUnit.suffix) {
+			number, unit = strings.TrimSpace(s[:len(s)-len(byteUnit.suffix)]), byteUnit.size
+			break
+		}
+	}
+
+	n, err := strconv.ParseInt(number, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("forms: invalid size %q", s)
+	}
+
+	if n > int64(^uint64(0)>>1)/int64(unit) {
+		return 0, fmt.Errorf("forms: size %q is too large", s)
+	}
+
+	return ByteSize(n) * unit, nil
+}
+
+// String formats the size with the largest unit it is a whole number of, e.g. "10MiB"
+func (b ByteSize) String() string {
+
+	for _, byteUnit := range byteUnits {
+		if b != 0 && b%byteUnit.size == 0 {
+			return fmt.Sprintf("%d%s", b/byteUnit.size, byteUnit.suffix)
+		}
+	}
+
+	return fmt.Sprintf("%dB", int64(b))
+}
@@ -1,18 +1,28 @@
 package forms
 
-type errors map[string][]string
+import "github.com/matthewlmitchell/tempshare/pkg/i18n"
+
+// Error messages are kept untranslated along with their arguments, and translated into the
+// locale of the client when the form is displayed
+type errors map[string][]i18n.Message
 
 // Add appends an error message to the specified field in our errors map
 func (e errors) Add(field, message string) {
-	e[field] = append(e[field], message)
+	e[field] = append(e[field], i18n.Message{Format: message})
+}
+
+// Addf appends an error message formatted with args to the specified field in our errors map
+func (e errors) Addf(field, format string, args ...interface{}) {
+	e[field] = append(e[field], i18n.Message{Format: format, Args: args})
 }
 
-// Get retrieves the first error message of a given field from our errors map
-func (e errors) Get(field string) string {
+// Get retrieves the first error message of a given field from our errors map,
+// or nil if the field has no errors
+func (e errors) Get(field string) *i18n.Message {
 	es := e[field]
 	if len(es) == 0 {
-		return ""
+		return nil
 	}
 
-	return es[0]
-}
\ No newline at end of file
+	return &es[0]
+}
@@ -1,23 +1,44 @@
 package forms
 
 import (
-	"fmt"
+	"mime/multipart"
 	"net/url"
+	"regexp"
 	"strings"
 	"unicode/utf8"
+
+	"github.com/matthewlmitchell/tempshare/pkg/i18n"
 )
 
+// EmailRX is the pattern recommended by the W3C for validating email addresses
+// c.f. https://html.spec.whatwg.org/#valid-e-mail-address
+var EmailRX = regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$")
+
 type Form struct {
 	url.Values
+	Files  map[string][]*multipart.FileHeader
 	Errors errors
 }
 
 // New initializes a new Form struct given a set of values (url.Values)
 func New(data url.Values) *Form {
 	return &Form{
-		data,
-		errors(map[string][]string{}),
+		Values: data,
+		Errors: errors(map[string][]i18n.Message{}),
+	}
+}
+
+// NewMultipart initializes a new Form struct with the values and files of a parsed
+// multipart form, e.g. r.MultipartForm
+func NewMultipart(data *multipart.Form) *Form {
+	if data == nil {
+		return New(nil)
 	}
+
+	form := New(url.Values(data.Value))
+	form.Files = data.File
+
+	return form
 }
 
 // Required ensures that necessary form fields are non-empty
@@ -66,7 +87,7 @@ func (f *Form) MinLength(field string, minLength int) {
 	//		 for characters that take up more than one byte, e.g. high-ansi
 	strLength := utf8.RuneCountInString(value)
 	if strLength < minLength {
-		f.Errors.Add(field, fmt.Sprintf("This field must contain more than %d characters", minLength))
+		f.Errors.Addf(field, "This field must contain more than %d characters", minLength)
 	}
 }
 
@@ -80,7 +101,19 @@ func (f *Form) MaxLength(field string, maxLength int) {
 
 	strLength := utf8.RuneCountInString(value)
 	if strLength > maxLength {
-		f.Errors.Add(field, fmt.Sprintf("This field must contain less than %d characters", maxLength))
+		f.Errors.Addf(field, "This field must contain less than %d characters", maxLength)
+	}
+}
+
+// MatchesPattern asserts that the value in a given field matches the supplied regular expression
+func (f *Form) MatchesPattern(field string, pattern *regexp.Regexp) {
+	value := f.Get(field)
+	if value == "" {
+		return
+	}
+
+	if !pattern.MatchString(value) {
+		f.Errors.Add(field, "This field is invalid.")
 	}
 }
 
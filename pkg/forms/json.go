@@ -0,0 +1,49 @@
+package forms
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+)
+
+// FromJSON reads a JSON object from r into a Form, so that a JSON request can be bound into
+// the same struct, and validated by the same rules, as an HTML form. Every member of the object
+// is a form field: strings, numbers and booleans become its value, arrays of them become its
+// values, and null is left out.
+func FromJSON(r io.Reader) (*Form, error) {
+
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+
+	var object map[string]interface{}
+	if err := decoder.Decode(&object); err != nil {
+		return nil, fmt.Errorf("forms: %w", err)
+	}
+
+	values := url.Values{}
+
+	for name, member := range object {
+		items, ok := member.([]interface{})
+		if !ok {
+			items = []interface{}{member}
+		}
+
+		for _, item := range items {
+			switch item := item.(type) {
+			case nil:
+			case string:
+				values.Add(name, item)
+			case json.Number:
+				values.Add(name, item.String())
+			case bool:
+				values.Add(name, strconv.FormatBool(item))
+			default:
+				return nil, fmt.Errorf("forms: %q must be a string, number or boolean", name)
+			}
+		}
+	}
+
+	return New(values), nil
+}
@@ -0,0 +1,211 @@
+// Package i18n translates the text of the web interface using message catalogs, one per
+// locale, and picks the locale best matching a client's preferences.
+package i18n
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+	"time"
+
+	"golang.org/x/text/language"
+)
+
+// DefaultLocale is used when none of the locales a client accepts are available. Messages are
+// written in it, and used as the keys of the other catalogs, so its catalog holds no messages.
+const DefaultLocale = "en"
+
+// Message is a message format and its arguments, which are translated when it is displayed
+type Message struct {
+	Format string
+	Args   []interface{}
+}
+
+// String returns the message in the default locale
+func (m Message) String() string {
+	if len(m.Args) == 0 {
+		return m.Format
+	}
+
+	return fmt.Sprintf(m.Format, m.Args...)
+}
+
+// Catalog holds the translations of the messages into a single locale
+type Catalog struct {
+	Tag        language.Tag      `json:"-"`
+	Name       string            `json:"name"`       // Name of the language in itself, e.g. "Deutsch"
+	DateFormat string            `json:"dateFormat"` // Layout of time.Format, with "Jan" replaced by Months
+	Months     []string          `json:"months"`     // Abbreviated month names, if they aren't English
+	Messages   map[string]string `json:"messages"`
+}
+
+// Locale returns the BCP 47 tag of the catalog, e.g. "de"
+func (c *Catalog) Locale() string {
+	return c.Tag.String()
+}
+
+// T translates the message format and formats it with args, with times formatted by Date.
+// Formats without a translation are used as they are.
+func (c *Catalog) T(format string, args ...interface{}) string {
+
+	if translated, ok := c.Messages[format]; ok && translated != "" {
+		format = translated
+	}
+
+	if len(args) == 0 {
+		return format
+	}
+
+	localized := make([]interface{}, len(args))
+	for i, arg := range args {
+		if t, ok := arg.(time.Time); ok {
+			arg = c.Date(t)
+		}
+		localized[i] = arg
+	}
+
+	return fmt.Sprintf(format, localized...)
+}
+
+// Translate translates message, either a format string formatted with args or a Message,
+// for templates where both are displayed with {{t ...}}
+func (c *Catalog) Translate(message interface{}, args ...interface{}) string {
+
+	switch message := message.(type) {
+	case string:
+		return c.T(message, args...)
+	case Message:
+		return c.T(message.Format, message.Args...)
+	case *Message:
+		return c.T(message.Format, message.Args...)
+	}
+
+	return fmt.Sprint(message)
+}
+
+// Date formats t in UTC with the date format of the catalog, returning an empty string
+// if t is the zero time
+func (c *Catalog) Date(t time.Time) string {
+
+	if t.IsZero() {
+		return ""
+	}
+
+	t = t.UTC()
+	formatted := t.Format(c.DateFormat)
+
+	// time.Format only knows the English names of months
+	if len(c.Months) == 12 && strings.Contains(c.DateFormat, "Jan") {
+		formatted = strings.Replace(formatted, t.Format("Jan"), c.Months[t.Month()-1], 1)
+	}
+
+	return formatted
+}
+
+// Bundle holds the catalogs of every available locale
+type Bundle struct {
+	catalogs []*Catalog // The default locale first
+	matcher  language.Matcher
+}
+
+// Load reads a catalog from every "<locale>.json" file at the root of fsys, e.g. "de.json".
+// There must be one for DefaultLocale.
+func Load(fsys fs.FS) (*Bundle, error) {
+
+	files, err := fs.Glob(fsys, "*.json")
+	if err != nil {
+		return nil, err
+	}
+
+	bundle := &Bundle{}
+
+	for _, file := range files {
+		tag, err := language.Parse(strings.TrimSuffix(path.Base(file), ".json"))
+		if err != nil {
+			return nil, fmt.Errorf("i18n: %s: %w", file, err)
+		}
+
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+
+		catalog := &Catalog{Tag: tag}
+		if err := json.Unmarshal(data, catalog); err != nil {
+			return nil, fmt.Errorf("i18n: %s: %w", file, err)
+		}
+
+		if catalog.DateFormat == "" {
+			return nil, fmt.Errorf("i18n: %s has no dateFormat", file)
+		}
+
+		if tag.String() == DefaultLocale {
+			bundle.catalogs = append([]*Catalog{catalog}, bundle.catalogs...)
+		} else {
+			bundle.catalogs = append(bundle.catalogs, catalog)
+		}
+	}
+
+	if len(bundle.catalogs) == 0 || bundle.catalogs[0].Locale() != DefaultLocale {
+		return nil, fmt.Errorf("i18n: no catalog for the default locale %q", DefaultLocale)
+	}
+
+	tags := make([]language.Tag, len(bundle.catalogs))
+	for i, catalog := range bundle.catalogs {
+		tags[i] = catalog.Tag
+	}
+	bundle.matcher = language.NewMatcher(tags)
+
+	return bundle, nil
+}
+
+// Catalogs returns the catalogs of every locale, the default one first
+func (b *Bundle) Catalogs() []*Catalog {
+	return b.catalogs
+}
+
+// Default returns the catalog of DefaultLocale
+func (b *Bundle) Default() *Catalog {
+	return b.catalogs[0]
+}
+
+// Catalog returns the catalog of locale, e.g. "de", or nil if it isn't available
+func (b *Bundle) Catalog(locale string) *Catalog {
+
+	for _, catalog := range b.catalogs {
+		if catalog.Locale() == locale {
+			return catalog
+		}
+	}
+
+	return nil
+}
+
+// Match returns the catalog best matching preferences, in decreasing order of priority.
+// Each of them is either a locale or the value of an Accept-Language header, and empty or
+// invalid ones are skipped.
+func (b *Bundle) Match(preferences ...string) *Catalog {
+
+	var tags []language.Tag
+
+	for _, preference := range preferences {
+		preferred, _, err := language.ParseAcceptLanguage(preference)
+		if err != nil {
+			continue
+		}
+		tags = append(tags, preferred...)
+	}
+
+	if len(tags) == 0 {
+		return b.Default()
+	}
+
+	_, index, confidence := b.matcher.Match(tags...)
+	if confidence == language.No {
+		return b.Default()
+	}
+
+	return b.catalogs[index]
+}
@@ -0,0 +1,92 @@
+package i18n
+
+import (
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+var testCatalogs = fstest.MapFS{
+	"en.json": {Data: []byte(`{"name": "English", "dateFormat": "Jan 02 2006 at 15:04", "messages": {}}`)},
+	"de.json": {Data: []byte(`{
+		"name": "Deutsch",
+		"dateFormat": "02. Jan 2006, 15:04",
+		"months": ["Jan.", "Feb.", "März", "Apr.", "Mai", "Juni", "Juli", "Aug.", "Sept.", "Okt.", "Nov.", "Dez."],
+		"messages": {"This link has %d uses remaining.": "Dieser Link kann noch %d Mal verwendet werden.", "Available from: %s": "Verfügbar ab: %s"}
+	}`)},
+}
+
+func TestMatch(t *testing.T) {
+
+	bundle, err := Load(testCatalogs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		preferences []string
+		want        string
+	}{
+		{"No preferences", nil, "en"},
+		{"Accept-Language", []string{"", "de-AT,de;q=0.9,en;q=0.8"}, "de"},
+		{"Unavailable locale", []string{"", "fr-FR"}, "en"},
+		{"Fallback in Accept-Language", []string{"", "fr-FR,de;q=0.5"}, "de"},
+		{"Override", []string{"en", "de"}, "en"},
+		{"Invalid override", []string{"not a locale!", "de"}, "de"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := bundle.Match(tt.preferences...).Locale(); got != tt.want {
+				t.Errorf("Expected %s, received %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestCatalog(t *testing.T) {
+
+	bundle, err := Load(testCatalogs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	date := time.Date(2022, 3, 21, 12, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		locale  string
+		message interface{}
+		args    []interface{}
+		want    string
+	}{
+		{"Translated", "de", "This link has %d uses remaining.", []interface{}{2}, "Dieser Link kann noch 2 Mal verwendet werden."},
+		{"Untranslated", "de", "Invalid token", nil, "Invalid token"},
+		{"Default locale", "en", "This link has %d uses remaining.", []interface{}{2}, "This link has 2 uses remaining."},
+		{"Localized date", "de", "Available from: %s", []interface{}{date}, "Verfügbar ab: 21. März 2022, 12:30"},
+		{"English date", "en", "Available from: %s", []interface{}{date}, "Available from: Mar 21 2022 at 12:30"},
+		{"Message", "de", &Message{Format: "This link has %d uses remaining.", Args: []interface{}{1}}, nil, "Dieser Link kann noch 1 Mal verwendet werden."},
+		{"No arguments", "en", "100% secure", nil, "100% secure"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := bundle.Catalog(tt.locale).Translate(tt.message, tt.args...); got != tt.want {
+				t.Errorf("Expected %q, received %q", tt.want, got)
+			}
+		})
+	}
+
+	if got := bundle.Default().Date(time.Time{}); got != "" {
+		t.Errorf("Expected an empty string for the zero time, received %q", got)
+	}
+}
+
+func TestLoadWithoutDefault(t *testing.T) {
+
+	_, err := Load(fstest.MapFS{"de.json": testCatalogs["de.json"]})
+	if err == nil {
+		t.Error("Expected an error without a catalog for the default locale")
+	}
+}
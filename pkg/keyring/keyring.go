@@ -0,0 +1,145 @@
+package keyring
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// KeySize is the size in bytes of every key in a keyring, and of the keys derived from them
+const KeySize = 32
+
+var ErrEmpty = errors.New("keyring: no keys")
+
+// Key is a secret in a keyring. Secret is base64 encoded in the keyring file.
+type Key struct {
+	ID      string    `json:"id"`
+	Created time.Time `json:"created"`
+	Secret  []byte    `json:"secret"`
+}
+
+// Keyring is a list of keys, newest first. The newest key signs (or encrypts) new data,
+// and the older ones are only kept to verify data signed before the keyring was rotated.
+type Keyring struct {
+	Keys []Key `json:"keys"`
+}
+
+// Load reads the keyring in the JSON file at path
+func Load(path string) (*Keyring, error) {
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	keyring := &Keyring{}
+	if err := json.Unmarshal(data, keyring); err != nil {
+		return nil, fmt.Errorf("keyring: %s: %w", path, err)
+	}
+
+	if len(keyring.Keys) == 0 {
+		return nil, ErrEmpty
+	}
+
+	for _, key := range keyring.Keys {
+		if len(key.Secret) != KeySize {
+			return nil, fmt.Errorf("keyring: key %s is %d bytes, not %d", key.ID, len(key.Secret), KeySize)
+		}
+	}
+
+	return keyring, nil
+}
+
+// Save writes the keyring to the JSON file at path, readable only by its owner. The file is
+// replaced atomically, so servers starting at the same time never read half a keyring.
+func (keyring *Keyring) Save(path string) error {
+
+	data, err := json.MarshalIndent(keyring, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tempFile, err := os.CreateTemp(filepath.Dir(path), ".keyring-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tempFile.Name())
+
+	if _, err := tempFile.Write(data); err != nil {
+		tempFile.Close()
+		return err
+	}
+
+	if err := tempFile.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tempFile.Name(), path)
+}
+
+// Rotate adds a new random key which becomes the newest, then discards the oldest keys
+// so that at most keep remain
+func (keyring *Keyring) Rotate(now time.Time, keep int) error {
+
+	if keep < 1 {
+		return errors.New("keyring: at least one key must be kept")
+	}
+
+	secret := make([]byte, KeySize)
+	if _, err := rand.Read(secret); err != nil {
+		return err
+	}
+
+	id := make([]byte, 4)
+	if _, err := rand.Read(id); err != nil {
+		return err
+	}
+
+	key := Key{
+		ID:      hex.EncodeToString(id),
+		Created: now.UTC(),
+		Secret:  secret,
+	}
+
+	keyring.Keys = append([]Key{key}, keyring.Keys...)
+	if len(keyring.Keys) > keep {
+		keyring.Keys = keyring.Keys[:keep]
+	}
+
+	return nil
+}
+
+// Derive returns a key for purpose (e.g. "session" or "csrf") derived from each key
+// of the keyring, newest first, so that different uses never share a key
+func (keyring *Keyring) Derive(purpose string) [][]byte {
+
+	secrets := make([][]byte, len(keyring.Keys))
+	for i, key := range keyring.Keys {
+		secrets[i] = key.Secret
+	}
+
+	return Derive(secrets, purpose)
+}
+
+// Derive returns a key for purpose derived from each of keys, in the same order. Keys derived
+// from the keys of a keyring can be derived again for a narrower purpose, e.g. "admin session"
+// from those for "session".
+func Derive(keys [][]byte, purpose string) [][]byte {
+
+	derived := make([][]byte, len(keys))
+
+	for i, key := range keys {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte("tempshare " + purpose))
+		derived[i] = mac.Sum(nil)
+	}
+
+	return derived
+}
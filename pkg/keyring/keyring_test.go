@@ -0,0 +1,109 @@
+package keyring
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRotate(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "keyring.json")
+	keyring := &Keyring{}
+
+	for i := 0; i < 4; i++ {
+		if err := keyring.Rotate(time.Now(), 3); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := keyring.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode %v, received %v", os.FileMode(0600), info.Mode().Perm())
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(loaded.Keys) != 3 {
+		t.Fatalf("Expected 3 keys, received %d", len(loaded.Keys))
+	}
+
+	newest := loaded.Keys[0].Secret
+	if err := loaded.Rotate(time.Now(), 3); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(loaded.Keys[1].Secret, newest) {
+		t.Error("Expected the previous newest key to become the second key")
+	}
+}
+
+func TestLoad(t *testing.T) {
+
+	testCases := []struct {
+		name        string
+		contents    string
+		expectError bool
+	}{
+		{"Valid keyring", `{"keys":[{"id":"a","secret":"AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="}]}`, false},
+		{"No keys", `{"keys":[]}`, true},
+		{"Short key", `{"keys":[{"id":"a","secret":"AAAA"}]}`, true},
+		{"Invalid JSON", `{"keys":`, true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "keyring.json")
+			if err := os.WriteFile(path, []byte(testCase.contents), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := Load(path)
+			if (err != nil) != testCase.expectError {
+				t.Errorf("Expected error: %v, received %v", testCase.expectError, err)
+			}
+		})
+	}
+}
+
+func TestDerive(t *testing.T) {
+
+	keyring := &Keyring{}
+	for i := 0; i < 2; i++ {
+		if err := keyring.Rotate(time.Now(), 2); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	session := keyring.Derive("session")
+	csrf := keyring.Derive("csrf")
+
+	if len(session) != 2 || len(session[0]) != KeySize {
+		t.Fatalf("Expected 2 keys of %d bytes, received %d", KeySize, len(session))
+	}
+
+	if bytes.Equal(session[0], csrf[0]) || bytes.Equal(session[0], session[1]) {
+		t.Error("Expected every derived key to be different")
+	}
+
+	if !bytes.Equal(session[0], keyring.Derive("session")[0]) {
+		t.Error("Expected derivation to be deterministic")
+	}
+
+	adminSession := Derive(session, "admin session")
+	if len(adminSession) != 2 || bytes.Equal(adminSession[0], session[0]) || bytes.Equal(adminSession[0], Derive(csrf, "admin session")[0]) {
+		t.Error("Expected keys derived again to be different from the keys they were derived from")
+	}
+}
@@ -0,0 +1,115 @@
+// Package logging provides the structured JSON logger used by TempShare, which redacts
+// share tokens and form values so that nothing able to open a TempShare ends up in the logs.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Redacted replaces the value of any attribute which may contain sensitive data
+const Redacted = "[REDACTED]"
+
+// sensitiveKeys are attribute keys (and query/form field names) whose values are always redacted
+var sensitiveKeys = map[string]bool{
+	"token":      true,
+	"passphrase": true,
+	"text":       true,
+	"tokens":     true,
+	"email":      true,
+	"notify":     true,
+	"recipient":  true,
+	"recipients": true,
+	"password":   true,
+	"secret":     true,
+}
+
+// tokenRX matches TempShare tokens (52 base32 characters), wherever they appear in a message
+var tokenRX = regexp.MustCompile(`\b[A-Z2-7]{52}\b`)
+
+// New returns a logger which writes JSON to w, redacting sensitive attributes
+func New(w io.Writer, level slog.Leveler) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: redactAttr,
+	}))
+}
+
+// redactAttr is a slog.HandlerOptions.ReplaceAttr function which redacts the value of
+// sensitive attributes, and any tokens appearing in the value of the others
+func redactAttr(groups []string, attr slog.Attr) slog.Attr {
+	if sensitiveKeys[strings.ToLower(attr.Key)] {
+		return slog.String(attr.Key, Redacted)
+	}
+
+	switch attr.Value.Kind() {
+	case slog.KindString:
+		return slog.String(attr.Key, RedactString(attr.Value.String()))
+	case slog.KindAny:
+		if err, ok := attr.Value.Any().(error); ok {
+			return slog.String(attr.Key, RedactString(err.Error()))
+		}
+	}
+
+	return attr
+}
+
+// RedactString replaces every TempShare token in s
+func RedactString(s string) string {
+	return tokenRX.ReplaceAllString(s, Redacted)
+}
+
+// RedactQuery returns the query string of a URL with the values of sensitive fields redacted,
+// e.g. "token=[REDACTED]&lang=en"
+func RedactQuery(query url.Values) string {
+	redacted := url.Values{}
+	for key, values := range query {
+		for _, value := range values {
+			if sensitiveKeys[strings.ToLower(key)] {
+				value = Redacted
+			}
+			redacted.Add(key, RedactString(value))
+		}
+	}
+
+	// Encode escapes the brackets, which only makes the logs harder to read
+	return strings.ReplaceAll(redacted.Encode(), url.QueryEscape(Redacted), Redacted)
+}
+
+type contextKey string
+
+const requestIDKey = contextKey("requestID")
+
+// NewRequestID returns a random identifier for a request
+func NewRequestID() string {
+	randBytes := make([]byte, 8)
+	rand.Read(randBytes)
+
+	return hex.EncodeToString(randBytes)
+}
+
+// WithRequestID returns a copy of ctx carrying the given request ID
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey, requestID)
+}
+
+// RequestID returns the request ID carried by ctx, or an empty string if there isn't one
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey).(string)
+	return requestID
+}
+
+// FromContext returns logger with the request ID carried by ctx attached, if there is one
+func FromContext(ctx context.Context, logger *slog.Logger) *slog.Logger {
+	if requestID := RequestID(ctx); requestID != "" {
+		return logger.With("request_id", requestID)
+	}
+
+	return logger
+}
@@ -0,0 +1,87 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/url"
+	"testing"
+)
+
+func TestRedaction(t *testing.T) {
+
+	testCases := []struct {
+		name     string
+		attr     slog.Attr
+		expected string
+	}{
+		{
+			name:     "Sensitive key",
+			attr:     slog.String("passphrase", "ABCDE-FGHIJ-KLMNO-PQRST"),
+			expected: Redacted,
+		},
+		{
+			name:     "Sensitive key in another case",
+			attr:     slog.String("Token", "anything"),
+			expected: Redacted,
+		},
+		{
+			name:     "Token in a message",
+			attr:     slog.String("path", "/view?token=MUPPH5PDKV7AGCUAAEERL5ARIXICVVGYLRIV365X5XSV3EKISAXQ"),
+			expected: "/view?token=" + Redacted,
+		},
+		{
+			name:     "Token in an error",
+			attr:     slog.Any("error", errors.New("no share MUPPH5PDKV7AGCUAAEERL5ARIXICVVGYLRIV365X5XSV3EKISAXQ")),
+			expected: "no share " + Redacted,
+		},
+		{
+			name:     "Harmless value",
+			attr:     slog.String("method", "GET"),
+			expected: "GET",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			New(buf, slog.LevelInfo).LogAttrs(context.Background(), slog.LevelInfo, "test", testCase.attr)
+
+			entry := map[string]interface{}{}
+			if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+				t.Fatal(err)
+			}
+
+			if entry[testCase.attr.Key] != testCase.expected {
+				t.Errorf("Expected %s, received %v", testCase.expected, entry[testCase.attr.Key])
+			}
+		})
+	}
+}
+
+func TestRedactQuery(t *testing.T) {
+	query := url.Values{"token": {"MUPPH5PDKV7AGCUAAEERL5ARIXICVVGYLRIV365X5XSV3EKISAXQ"}, "page": {"2"}}
+
+	expected := "page=2&token=" + Redacted
+	if redacted := RedactQuery(query); redacted != expected {
+		t.Errorf("Expected %s, received %s", expected, redacted)
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	buf := &bytes.Buffer{}
+	ctx := WithRequestID(context.Background(), "0123456789abcdef")
+
+	FromContext(ctx, New(buf, slog.LevelInfo)).Info("test")
+
+	entry := map[string]interface{}{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatal(err)
+	}
+
+	if entry["request_id"] != "0123456789abcdef" {
+		t.Errorf("Expected %s, received %v", "0123456789abcdef", entry["request_id"])
+	}
+}
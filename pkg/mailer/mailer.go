@@ -0,0 +1,264 @@
+package mailer
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"path"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+var ErrNoStartTLS = errors.New("mailer: SMTP server does not support STARTTLS")
+
+// mailTemplate holds the two parsed versions of a single email template file.
+// Every template file must define "subject", "plainBody" and "htmlBody". The subject
+// and plain text body are executed with text/template, the HTML body with html/template
+// so that any data inserted into it is escaped.
+type mailTemplate struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+// Mailer renders email templates and sends them through an SMTP server.
+// Unless AllowInsecure is set, the connection must be upgraded with STARTTLS
+// before any credentials or messages are sent.
+type Mailer struct {
+	Host          string
+	Port          int
+	Username      string
+	Password      string
+	Sender        string
+	AllowInsecure bool
+	TLSConfig     *tls.Config
+	Timeout       time.Duration
+	templateCache map[string]*mailTemplate
+}
+
+// New returns a Mailer with every "*.mail.tmpl" file at the root of fsys parsed into its template cache
+func New(fsys fs.FS, host string, port int, username string, password string, sender string) (*Mailer, error) {
+	templateCache, err := initTemplateCache(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Mailer{
+		Host:          host,
+		Port:          port,
+		Username:      username,
+		Password:      password,
+		Sender:        sender,
+		TLSConfig:     &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12},
+		Timeout:       10 * time.Second,
+		templateCache: templateCache,
+	}, nil
+}
+
+// initTemplateCache accepts a file system and returns a map that points
+// file names to both the text and HTML parsed versions of each email template.
+func initTemplateCache(fsys fs.FS) (map[string]*mailTemplate, error) {
+
+	cache := map[string]*mailTemplate{}
+
+	pages, err := fs.Glob(fsys, "*.mail.tmpl")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, page := range pages {
+		fileName := path.Base(page)
+
+		textParsed, err := texttemplate.New(fileName).ParseFS(fsys, page)
+		if err != nil {
+			return nil, err
+		}
+
+		htmlParsed, err := htmltemplate.New(fileName).ParseFS(fsys, page)
+		if err != nil {
+			return nil, err
+		}
+
+		cache[fileName] = &mailTemplate{text: textParsed, html: htmlParsed}
+	}
+
+	return cache, nil
+}
+
+// Message is a rendered email ready to be sent
+type Message struct {
+	Recipient string
+	Subject   string
+	PlainBody string
+	HTMLBody  string
+}
+
+// Render executes the named template with data and returns the resulting Message
+func (mailer *Mailer) Render(recipient string, tmplName string, data interface{}) (*Message, error) {
+
+	// Refuse to build a message if the address could be used to inject extra headers
+	if strings.ContainsAny(recipient, "\r\n") {
+		return nil, fmt.Errorf("mailer: invalid recipient address %q", recipient)
+	}
+
+	tmpl, ok := mailer.templateCache[tmplName]
+	if !ok {
+		return nil, fmt.Errorf("the template %s does not exist", tmplName)
+	}
+
+	subject := new(bytes.Buffer)
+	if err := tmpl.text.ExecuteTemplate(subject, "subject", data); err != nil {
+		return nil, err
+	}
+
+	plainBody := new(bytes.Buffer)
+	if err := tmpl.text.ExecuteTemplate(plainBody, "plainBody", data); err != nil {
+		return nil, err
+	}
+
+	htmlBody := new(bytes.Buffer)
+	if err := tmpl.html.ExecuteTemplate(htmlBody, "htmlBody", data); err != nil {
+		return nil, err
+	}
+
+	return &Message{
+		Recipient: recipient,
+		Subject:   strings.TrimSpace(subject.String()),
+		PlainBody: plainBody.String(),
+		HTMLBody:  htmlBody.String(),
+	}, nil
+}
+
+// Send renders the named template with data and sends it to recipient
+func (mailer *Mailer) Send(recipient string, tmplName string, data interface{}) error {
+	message, err := mailer.Render(recipient, tmplName, data)
+	if err != nil {
+		return err
+	}
+
+	return mailer.SendMessage(message)
+}
+
+// SendMessage delivers an already rendered Message over SMTP
+func (mailer *Mailer) SendMessage(message *Message) error {
+
+	body, err := mailer.encode(message)
+	if err != nil {
+		return err
+	}
+
+	addr := net.JoinHostPort(mailer.Host, fmt.Sprint(mailer.Port))
+
+	conn, err := net.DialTimeout("tcp", addr, mailer.Timeout)
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(mailer.Timeout))
+
+	client, err := smtp.NewClient(conn, mailer.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err = client.StartTLS(mailer.TLSConfig); err != nil {
+			return err
+		}
+	} else if !mailer.AllowInsecure {
+		return ErrNoStartTLS
+	}
+
+	if mailer.Username != "" {
+		if err = client.Auth(smtp.PlainAuth("", mailer.Username, mailer.Password, mailer.Host)); err != nil {
+			return err
+		}
+	}
+
+	if err = client.Mail(mailer.Sender); err != nil {
+		return err
+	}
+
+	if err = client.Rcpt(message.Recipient); err != nil {
+		return err
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+
+	if _, err = writer.Write(body); err != nil {
+		return err
+	}
+
+	if err = writer.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
+
+// encode formats a Message as a multipart/alternative MIME message with a plain text
+// and an HTML part, both quoted-printable encoded.
+func (mailer *Mailer) encode(message *Message) ([]byte, error) {
+
+	messageID := make([]byte, 16)
+	if _, err := rand.Read(messageID); err != nil {
+		return nil, err
+	}
+
+	buffer := new(bytes.Buffer)
+	writer := multipart.NewWriter(buffer)
+
+	fmt.Fprintf(buffer, "From: %s\r\n", mailer.Sender)
+	fmt.Fprintf(buffer, "To: %s\r\n", message.Recipient)
+	fmt.Fprintf(buffer, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", message.Subject))
+	fmt.Fprintf(buffer, "Date: %s\r\n", time.Now().UTC().Format(time.RFC1123Z))
+	fmt.Fprintf(buffer, "Message-ID: <%x@%s>\r\n", messageID, mailer.Host)
+	fmt.Fprintf(buffer, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(buffer, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", writer.Boundary())
+
+	parts := []struct {
+		contentType string
+		body        string
+	}{
+		{"text/plain; charset=utf-8", message.PlainBody},
+		{"text/html; charset=utf-8", message.HTMLBody},
+	}
+
+	for _, part := range parts {
+		partWriter, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		encoder := quotedprintable.NewWriter(partWriter)
+		if _, err = encoder.Write([]byte(part.body)); err != nil {
+			return nil, err
+		}
+		if err = encoder.Close(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
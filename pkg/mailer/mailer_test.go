@@ -0,0 +1,143 @@
+package mailer
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matthewlmitchell/tempshare/pkg/mailer/mailertest"
+)
+
+var shareData = map[string]interface{}{
+	"Link":      "https://placeholder.com/view?token=MUPPH5PDKV7AGCUAAEERL5ARIXICVVGYLRIV365X5XSV3EKISAXQ",
+	"Expires":   "Feb 21 2022 at 12:30 UTC",
+	"ViewLimit": 1,
+}
+
+func newTestMailer(t *testing.T, server *mailertest.Server) *Mailer {
+	mailer, err := New(os.DirFS("./../../ui/mail"), server.Host, server.Port, server.Username, server.Password, "TempShare <no-reply@tempshare.local>")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mailer.TLSConfig = server.ClientTLSConfig()
+	mailer.Timeout = 5 * time.Second
+
+	return mailer
+}
+
+func TestSend(t *testing.T) {
+
+	testCases := []struct {
+		name          string
+		startTLS      bool
+		allowInsecure bool
+		recipient     string
+		template      string
+		expectError   bool
+	}{
+		{
+			name:      "STARTTLS with auth",
+			startTLS:  true,
+			recipient: "recipient@example.com",
+			template:  "share.mail.tmpl",
+		},
+		{
+			name:          "No STARTTLS but insecure allowed",
+			startTLS:      false,
+			allowInsecure: true,
+			recipient:     "recipient@example.com",
+			template:      "share.mail.tmpl",
+		},
+		{
+			name:        "No STARTTLS",
+			startTLS:    false,
+			recipient:   "recipient@example.com",
+			template:    "share.mail.tmpl",
+			expectError: true,
+		},
+		{
+			name:        "Header injection",
+			startTLS:    true,
+			recipient:   "recipient@example.com\r\nBcc: attacker@example.com",
+			template:    "share.mail.tmpl",
+			expectError: true,
+		},
+		{
+			name:        "Missing template",
+			startTLS:    true,
+			recipient:   "recipient@example.com",
+			template:    "missing.mail.tmpl",
+			expectError: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			server := mailertest.NewServer()
+			if testCase.startTLS {
+				server = mailertest.NewTLSServer()
+				server.Username = "username"
+				server.Password = "password"
+			}
+			defer server.Close()
+
+			mailer := newTestMailer(t, server)
+			mailer.AllowInsecure = testCase.allowInsecure
+
+			err := mailer.Send(testCase.recipient, testCase.template, shareData)
+			if testCase.expectError {
+				if err == nil {
+					t.Errorf("Expected an error, received nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			messages := server.Messages()
+			if len(messages) != 1 {
+				t.Fatalf("Expected 1 message, received %d", len(messages))
+			}
+
+			if messages[0].To[0] != testCase.recipient {
+				t.Errorf("Expected recipient %s, received %s", testCase.recipient, messages[0].To[0])
+			}
+
+			for _, expected := range []string{"Subject: Someone shared a TempShare with you", "multipart/alternative", "text/html"} {
+				if !strings.Contains(messages[0].Data, expected) {
+					t.Errorf("Expected message %s to contain %s", messages[0].Data, expected)
+				}
+			}
+		})
+	}
+}
+
+func TestQueueRetries(t *testing.T) {
+	server := mailertest.NewServer()
+	defer server.Close()
+
+	mailer := newTestMailer(t, server)
+	mailer.AllowInsecure = true
+
+	queue := NewQueue(mailer, 10, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	queue.RetryDelay = 10 * time.Millisecond
+	go queue.Work()
+
+	// The first two attempts are rejected, the third should be delivered
+	server.FailNext(2)
+
+	err := queue.Enqueue("recipient@example.com", "share.mail.tmpl", shareData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	messages := server.WaitForMessages(1, 5*time.Second)
+	if len(messages) != 1 {
+		t.Fatalf("Expected 1 message, received %d", len(messages))
+	}
+}
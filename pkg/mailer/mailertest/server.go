@@ -0,0 +1,283 @@
+// Package mailertest provides a fake SMTP server for testing code that sends email,
+// in the same spirit as net/http/httptest.
+package mailertest
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"net"
+	"net/http/httptest"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message is an email received by the fake SMTP server
+type Message struct {
+	From string
+	To   []string
+	Data string
+}
+
+// Server is a fake SMTP server listening on a random port of the loopback interface.
+// It implements just enough of RFC 5321 for net/smtp: EHLO, STARTTLS, AUTH PLAIN,
+// MAIL, RCPT, DATA, RSET, NOOP and QUIT.
+type Server struct {
+	Host string
+	Port int
+
+	// If set, clients must authenticate with AUTH PLAIN using these credentials
+	Username string
+	Password string
+
+	listener  net.Listener
+	tlsConfig *tls.Config
+	rootCAs   *x509.CertPool
+
+	mu       sync.Mutex
+	messages []*Message
+	failNext int
+	received chan struct{}
+}
+
+// NewServer starts a fake SMTP server which does not support STARTTLS
+func NewServer() *Server {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic("mailertest: failed to listen on a port: " + err.Error())
+	}
+
+	tcpAddr := listener.Addr().(*net.TCPAddr)
+
+	server := &Server{
+		Host:     tcpAddr.IP.String(),
+		Port:     tcpAddr.Port,
+		listener: listener,
+		received: make(chan struct{}, 64),
+	}
+
+	go server.serve()
+
+	return server
+}
+
+// NewTLSServer starts a fake SMTP server which supports STARTTLS using a self-signed
+// certificate. ClientTLSConfig returns a tls.Config which trusts that certificate.
+func NewTLSServer() *Server {
+	// Borrow the self-signed certificate that httptest generates for 127.0.0.1
+	httpServ := httptest.NewUnstartedServer(nil)
+	httpServ.StartTLS()
+	certificate := httpServ.TLS.Certificates[0]
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(httpServ.Certificate())
+	httpServ.Close()
+
+	server := NewServer()
+	server.tlsConfig = &tls.Config{Certificates: []tls.Certificate{certificate}}
+	server.rootCAs = rootCAs
+
+	return server
+}
+
+// ClientTLSConfig returns a tls.Config for clients of a server started with NewTLSServer
+func (server *Server) ClientTLSConfig() *tls.Config {
+	return &tls.Config{ServerName: server.Host, RootCAs: server.rootCAs}
+}
+
+// FailNext makes the server reject the next n messages with a temporary failure
+func (server *Server) FailNext(n int) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	server.failNext = n
+}
+
+// Messages returns every message received so far
+func (server *Server) Messages() []*Message {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	return append([]*Message{}, server.messages...)
+}
+
+// WaitForMessages blocks until at least n messages have been received or the timeout
+// runs out, then returns every message received so far.
+func (server *Server) WaitForMessages(n int, timeout time.Duration) []*Message {
+	deadline := time.After(timeout)
+
+	for len(server.Messages()) < n {
+		select {
+		case <-server.received:
+		case <-deadline:
+			return server.Messages()
+		}
+	}
+
+	return server.Messages()
+}
+
+// Close stops the server from accepting any more connections
+func (server *Server) Close() {
+	server.listener.Close()
+}
+
+func (server *Server) serve() {
+	for {
+		conn, err := server.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		go server.handle(conn)
+	}
+}
+
+func (server *Server) handle(conn net.Conn) {
+	defer conn.Close()
+
+	text := textproto.NewConn(conn)
+	tlsActive := false
+	authenticated := server.Username == ""
+	message := &Message{}
+
+	text.PrintfLine("220 %s ESMTP mailertest", server.Host)
+
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+
+		command, argument := line, ""
+		if i := strings.IndexByte(line, ' '); i >= 0 {
+			command, argument = line[:i], line[i+1:]
+		}
+
+		switch strings.ToUpper(command) {
+		case "EHLO", "HELO":
+			extensions := []string{server.Host}
+			if server.tlsConfig != nil && !tlsActive {
+				extensions = append(extensions, "STARTTLS")
+			}
+			if server.Username != "" {
+				extensions = append(extensions, "AUTH PLAIN")
+			}
+			extensions = append(extensions, "8BITMIME")
+
+			for i, extension := range extensions {
+				separator := "-"
+				if i == len(extensions)-1 {
+					separator = " "
+				}
+				text.PrintfLine("250%s%s", separator, extension)
+			}
+
+		case "STARTTLS":
+			if server.tlsConfig == nil || tlsActive {
+				text.PrintfLine("502 STARTTLS not available")
+				continue
+			}
+
+			text.PrintfLine("220 Ready to start TLS")
+
+			tlsConn := tls.Server(conn, server.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+
+			conn = tlsConn
+			text = textproto.NewConn(tlsConn)
+			tlsActive = true
+
+		case "AUTH":
+			fields := strings.Fields(argument)
+			if len(fields) != 2 || strings.ToUpper(fields[0]) != "PLAIN" {
+				text.PrintfLine("504 Unrecognized authentication type")
+				continue
+			}
+
+			credentials, err := base64.StdEncoding.DecodeString(fields[1])
+			parts := strings.Split(string(credentials), "\x00")
+			if err != nil || len(parts) != 3 || parts[1] != server.Username || parts[2] != server.Password {
+				text.PrintfLine("535 Authentication credentials invalid")
+				continue
+			}
+
+			authenticated = true
+			text.PrintfLine("235 Authentication successful")
+
+		case "MAIL":
+			if !authenticated {
+				text.PrintfLine("530 Authentication required")
+				continue
+			}
+
+			message = &Message{From: trimAddress(argument, "FROM:")}
+			text.PrintfLine("250 OK")
+
+		case "RCPT":
+			message.To = append(message.To, trimAddress(argument, "TO:"))
+			text.PrintfLine("250 OK")
+
+		case "DATA":
+			text.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+
+			data, err := text.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			message.Data = string(data)
+
+			server.mu.Lock()
+			fail := server.failNext > 0
+			if fail {
+				server.failNext--
+			} else {
+				server.messages = append(server.messages, message)
+			}
+			server.mu.Unlock()
+
+			if fail {
+				text.PrintfLine("451 Temporary failure, please try again")
+				continue
+			}
+
+			select {
+			case server.received <- struct{}{}:
+			default:
+			}
+			text.PrintfLine("250 OK: queued as %s", strconv.Itoa(len(server.Messages())))
+
+		case "RSET":
+			message = &Message{}
+			text.PrintfLine("250 OK")
+
+		case "NOOP":
+			text.PrintfLine("250 OK")
+
+		case "QUIT":
+			text.PrintfLine("221 Bye")
+			return
+
+		default:
+			text.PrintfLine("502 Command not implemented")
+		}
+	}
+}
+
+// trimAddress extracts the address from a "FROM:<address>" or "TO:<address>" argument
+func trimAddress(argument string, prefix string) string {
+	argument = strings.TrimSpace(argument)
+	if len(argument) >= len(prefix) && strings.EqualFold(argument[:len(prefix)], prefix) {
+		argument = argument[len(prefix):]
+	}
+
+	if i := strings.IndexByte(argument, ' '); i >= 0 {
+		argument = argument[:i]
+	}
+
+	return strings.Trim(argument, "<>")
+}
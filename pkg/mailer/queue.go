@@ -0,0 +1,81 @@
+package mailer
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+)
+
+var ErrQueueFull = errors.New("mailer: queue is full")
+
+// Queue sends messages in the background, retrying failed sends with exponential backoff.
+// Messages are only held in memory: they contain share links, which must never be
+// persisted in plain text alongside the hashed tokens in the database.
+type Queue struct {
+	Mailer      *Mailer
+	MaxAttempts int
+	RetryDelay  time.Duration
+	Logger      *slog.Logger
+	messages    chan *queuedMessage
+}
+
+type queuedMessage struct {
+	message  *Message
+	attempts int
+}
+
+// NewQueue returns a Queue which holds up to size messages waiting to be sent
+func NewQueue(mailer *Mailer, size int, logger *slog.Logger) *Queue {
+	return &Queue{
+		Mailer:      mailer,
+		MaxAttempts: 5,
+		RetryDelay:  30 * time.Second,
+		Logger:      logger,
+		messages:    make(chan *queuedMessage, size),
+	}
+}
+
+// Enqueue renders the named template immediately, so that template errors are returned
+// to the caller, then queues the message to be sent by a worker.
+func (queue *Queue) Enqueue(recipient string, tmplName string, data interface{}) error {
+	message, err := queue.Mailer.Render(recipient, tmplName, data)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case queue.messages <- &queuedMessage{message: message}:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Work sends queued messages until the queue is closed. It is meant to be run in its own
+// goroutine, and several workers may share a single Queue.
+func (queue *Queue) Work() {
+	for queued := range queue.messages {
+		err := queue.Mailer.SendMessage(queued.message)
+		if err == nil {
+			continue
+		}
+
+		queued.attempts++
+		if queued.attempts >= queue.MaxAttempts {
+			queue.Logger.Error("mailer: giving up on message", "recipient", queued.message.Recipient, "attempts", queued.attempts, "error", err)
+			continue
+		}
+
+		queue.Logger.Warn("mailer: sending message failed", "recipient", queued.message.Recipient, "attempt", queued.attempts, "error", err)
+
+		// The delay doubles after every failed attempt
+		delay := queue.RetryDelay << uint(queued.attempts-1)
+		time.AfterFunc(delay, func() {
+			select {
+			case queue.messages <- queued:
+			default:
+				queue.Logger.Error("mailer: dropped message", "recipient", queued.message.Recipient, "error", ErrQueueFull)
+			}
+		})
+	}
+}
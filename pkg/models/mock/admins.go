@@ -0,0 +1,127 @@
+package mock
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/matthewlmitchell/tempshare/pkg/auth"
+	"github.com/matthewlmitchell/tempshare/pkg/models"
+)
+
+// This is a mock version of the AdminModel{DB: *sql.DB} struct. Incorrect passwords and TOTP
+// codes are counted in the model, like they are in the database.
+type AdminModel struct {
+	mu                     sync.Mutex
+	failedPasswordAttempts int
+	failedTOTPAttempts     int
+	lockedUntil            time.Time
+	sessionGeneration      int
+}
+
+// MockAdminPassword is the password of mockAdmin, and MockAdminTOTPSecret the secret its
+// TOTP codes are generated from
+const (
+	MockAdminPassword   = "correct horse battery staple"
+	MockAdminTOTPSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+)
+
+var mockAdmin = &models.Admin{
+	ID:         1,
+	Username:   "alice",
+	TOTPSecret: MockAdminTOTPSecret,
+	Created:    time.Now(),
+}
+
+func (model *AdminModel) Insert(ctx context.Context, username string, password string, totpSecret string) error {
+
+	if username == mockAdmin.Username {
+		return models.ErrDuplicateUsername
+	}
+
+	return nil
+}
+
+// Authenticate accepts MockAdminPassword for the mock admin, and locks it after
+// models.MaxPasswordAttempts incorrect passwords
+func (model *AdminModel) Authenticate(ctx context.Context, username string, password string) (int, error) {
+
+	if username != mockAdmin.Username {
+		return 0, models.ErrInvalidCredentials
+	}
+
+	model.mu.Lock()
+	defer model.mu.Unlock()
+
+	if time.Now().Before(model.lockedUntil) {
+		return 0, models.ErrAccountLocked
+	}
+
+	if password == MockAdminPassword {
+		model.failedPasswordAttempts = 0
+		return mockAdmin.ID, nil
+	}
+
+	model.failedPasswordAttempts++
+	if model.failedPasswordAttempts >= models.MaxPasswordAttempts {
+		model.lockedUntil = time.Now().Add(models.PasswordLockout)
+		return 0, models.ErrAccountLocked
+	}
+
+	return 0, models.ErrInvalidCredentials
+}
+
+// VerifyTOTP accepts the current codes of MockAdminTOTPSecret, as often as they are used, and
+// locks the mock admin after models.MaxTOTPAttempts incorrect codes
+func (model *AdminModel) VerifyTOTP(ctx context.Context, id int, code string) error {
+
+	if id != mockAdmin.ID {
+		return models.ErrInvalidCredentials
+	}
+
+	model.mu.Lock()
+	defer model.mu.Unlock()
+
+	if time.Now().Before(model.lockedUntil) {
+		return models.ErrAccountLocked
+	}
+
+	if _, ok := auth.ValidateTOTP(MockAdminTOTPSecret, code, time.Now()); ok {
+		model.failedTOTPAttempts = 0
+		return nil
+	}
+
+	model.failedTOTPAttempts++
+	if model.failedTOTPAttempts >= models.MaxTOTPAttempts {
+		model.lockedUntil = time.Now().Add(models.TOTPLockout)
+		return models.ErrAccountLocked
+	}
+
+	return models.ErrInvalidCredentials
+}
+
+func (model *AdminModel) Get(ctx context.Context, id int) (*models.Admin, error) {
+
+	if id != mockAdmin.ID {
+		return nil, models.ErrNoRecord
+	}
+
+	model.mu.Lock()
+	defer model.mu.Unlock()
+
+	admin := *mockAdmin
+	admin.SessionGeneration = model.sessionGeneration
+
+	return &admin, nil
+}
+
+func (model *AdminModel) RevokeSessions(ctx context.Context, id int) error {
+
+	if id == mockAdmin.ID {
+		model.mu.Lock()
+		model.sessionGeneration++
+		model.mu.Unlock()
+	}
+
+	return nil
+}
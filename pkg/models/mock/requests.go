@@ -0,0 +1,61 @@
+package mock
+
+import (
+	"context"
+	"time"
+
+	"github.com/matthewlmitchell/tempshare/pkg/models"
+)
+
+// This is a mock version of the RequestModel{DB: *sql.DB} struct
+type RequestModel struct{}
+
+var mockRequest = &models.Request{
+	UploadToken: "UPLOADTOKENAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA",
+	ReadToken:   "READTOKENAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA",
+	Created:     time.Now(),
+	Expires:     time.Now().Add(24 * time.Hour),
+}
+
+var mockFulfilledRequest = &models.Request{
+	Text:      "This is an example response to a request!",
+	Created:   time.Now(),
+	Expires:   time.Now().Add(24 * time.Hour),
+	Fulfilled: time.Now(),
+}
+
+func (model *RequestModel) New(ctx context.Context, expires int) (*models.Request, error) {
+
+	return mockRequest, nil
+}
+
+func (model *RequestModel) Pending(ctx context.Context, plaintextUploadToken string) (*models.Request, error) {
+
+	if plaintextUploadToken == mockRequest.UploadToken {
+		return &models.Request{Created: mockRequest.Created, Expires: mockRequest.Expires}, nil
+	}
+
+	return nil, models.ErrNoRecord
+}
+
+func (model *RequestModel) Fulfil(ctx context.Context, plaintextUploadToken string, text string) error {
+
+	if plaintextUploadToken == mockRequest.UploadToken {
+		return nil
+	}
+
+	return models.ErrNoRecord
+}
+
+// Get treats mockRequest as unanswered, and FULFILLEDTOKEN... as the read token of an answered request
+func (model *RequestModel) Get(ctx context.Context, plaintextReadToken string) (*models.Request, error) {
+
+	switch plaintextReadToken {
+	case mockRequest.ReadToken:
+		return nil, models.ErrNotFulfilled
+	case "FULFILLEDTOKENAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA":
+		return mockFulfilledRequest, nil
+	}
+
+	return nil, models.ErrNoRecord
+}
@@ -1,7 +1,10 @@
 package mock
 
 import (
+	"context"
 	"crypto/sha256"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/matthewlmitchell/tempshare/pkg/models"
@@ -11,40 +14,270 @@ import (
 type TempShareModel struct{}
 
 var mockTempShare = &models.TempShare{
-	Text:      "This is an example tempshare for testing purposes!",
-	PlainText: "MUPPH5PDKV7AGCUAAEERL5ARIXICVVGYLRIV365X5XSV3EKISAXQ",
+	Text:        "This is an example tempshare for testing purposes!",
+	PlainText:   "MUPPH5PDKV7AGCUAAEERL5ARIXICVVGYLRIV365X5XSV3EKISAXQ",
+	URLToken:    hashToken("MUPPH5PDKV7AGCUAAEERL5ARIXICVVGYLRIV365X5XSV3EKISAXQ"),
+	ManageToken: "Q3NXTLOVHBWY2GWCQVJ4WDPHJ6B7TM2ZK5XRDFAE6OUKIRPLSM4Q",
+	Created:     time.Now(),
+	Expires:     time.Now().Add(24 * time.Hour),
+	Views:       0,
+	ViewLimit:   1,
+}
+
+var mockPassphraseTempShare = &models.TempShare{
+	Text:      "This is an example tempshare protected by a passphrase!",
+	PlainText: "P7ZJ2K5RXQ3MWN4VBTYHC6LDGAEUFSO2I7ZQ3XK5RWMN4VBTYHCQ",
+	URLToken:  hashToken("P7ZJ2K5RXQ3MWN4VBTYHC6LDGAEUFSO2I7ZQ3XK5RWMN4VBTYHCQ"),
 	Created:   time.Now(),
 	Expires:   time.Now().Add(24 * time.Hour),
 	Views:     0,
 	ViewLimit: 1,
 }
 
-func (model *TempShareModel) New(text string, expires string, viewlimit string) (*models.TempShare, error) {
+var mockLockedToken = "LOCKEDTOKENAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA"
+
+var mockMultiManageToken = "MULTIMANAGETOKENAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA"
 
-	hash := sha256.Sum256([]byte(mockTempShare.PlainText))
-	mockTempShare.URLToken = hash[:]
+var mockRecipients = []*models.TempShare{
+	{
+		URLToken:  hashToken("ALICETOKENAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA"),
+		Recipient: "Alice",
+		Created:   time.Now(),
+		Expires:   time.Now().Add(24 * time.Hour),
+		Views:     1,
+		ViewLimit: 1,
+	},
+	{
+		URLToken:  hashToken("BOBTOKENAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA"),
+		Recipient: "Bob",
+		Created:   time.Now(),
+		Expires:   time.Now().Add(24 * time.Hour),
+		Views:     0,
+		ViewLimit: 1,
+	},
+}
+
+var mockSplitTokens = []string{
+	"SPLITPARTONEAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA",
+	"SPLITPARTTWOAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA",
+	"SPLITPARTTHREEAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA",
+}
+
+// mockUsedSplitToken is a part of the same secret whose views have been used up
+var mockUsedSplitToken = "SPLITPARTUSEDAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA"
+
+var mockView = &models.View{
+	Viewed: time.Now(),
+	Client: "Firefox from 192.0.2.0/24",
+}
+
+func hashToken(plaintextToken string) []byte {
+	hash := sha256.Sum256([]byte(plaintextToken))
+	return hash[:]
+}
+
+func (model *TempShareModel) New(ctx context.Context, text string, expires int, viewlimit int, notify string, passphrase bool, notBefore time.Time) (*models.TempShare, error) {
 
 	// TODO: Insert(...)
 
+	if !notBefore.IsZero() {
+		tempShare := *mockTempShare
+		tempShare.NotBefore = notBefore
+		return &tempShare, nil
+	}
+
+	if passphrase {
+		tempShare := *mockTempShare
+		tempShare.Passphrase = "ABCDE-FGHIJ-KLMNO-PQRST"
+		return &tempShare, nil
+	}
+
 	return mockTempShare, nil
 }
 
-func (model *TempShareModel) Insert(urlToken []byte, text string, expires string, viewlimit int) error {
+func (model *TempShareModel) NewSplit(ctx context.Context, text string, expires int, viewlimit int, parts int, threshold int, notBefore time.Time) ([]*models.TempShare, error) {
+
+	tempShares := []*models.TempShare{}
+	for i := 0; i < parts && i < len(mockSplitTokens); i++ {
+		tempShares = append(tempShares, &models.TempShare{
+			PlainText:   mockSplitTokens[i],
+			URLToken:    hashToken(mockSplitTokens[i]),
+			ManageToken: mockTempShare.ManageToken,
+			Created:     mockTempShare.Created,
+			Expires:     mockTempShare.Expires,
+			ViewLimit:   mockTempShare.ViewLimit,
+			NotBefore:   notBefore,
+			Threshold:   threshold,
+		})
+	}
+
+	return tempShares, nil
+}
+
+func (model *TempShareModel) NewMulti(ctx context.Context, text string, expires int, viewlimit int, notify string, recipients []string, notBefore time.Time) (string, []*models.TempShare, error) {
+
+	tempShares := []*models.TempShare{}
+	for i, recipient := range recipients {
+		plaintextToken := fmt.Sprintf("RECIPIENT%dTOKEN", i+1)
+		plaintextToken += strings.Repeat("A", 52-len(plaintextToken))
+
+		tempShares = append(tempShares, &models.TempShare{
+			Text:        text,
+			PlainText:   plaintextToken,
+			URLToken:    hashToken(plaintextToken),
+			ManageToken: mockMultiManageToken,
+			Recipient:   recipient,
+			Created:     mockTempShare.Created,
+			Expires:     mockTempShare.Expires,
+			NotBefore:   notBefore,
+			ViewLimit:   mockTempShare.ViewLimit,
+		})
+	}
+
+	return mockMultiManageToken, tempShares, nil
+}
+
+// Combine treats the mock split tokens as three parts of mockTempShare's text with a threshold of two
+func (model *TempShareModel) Combine(ctx context.Context, plaintextTokens []string) (string, []*models.TempShare, error) {
+
+	tempShares := []*models.TempShare{}
+	seen := map[string]bool{}
+
+	for _, plaintextToken := range plaintextTokens {
+		if seen[plaintextToken] {
+			continue
+		}
+		seen[plaintextToken] = true
+
+		switch plaintextToken {
+		case mockTempShare.PlainText:
+			return "", nil, models.ErrNotSplitShare
+		case mockUsedSplitToken:
+			return "", nil, models.ErrViewLimitReached
+		}
+
+		found := false
+		for _, splitToken := range mockSplitTokens {
+			if plaintextToken == splitToken {
+				found = true
+			}
+		}
+		if !found {
+			return "", nil, models.ErrNotFound
+		}
+
+		tempShares = append(tempShares, &models.TempShare{
+			URLToken:  hashToken(plaintextToken),
+			Created:   mockTempShare.Created,
+			Expires:   mockTempShare.Expires,
+			ViewLimit: mockTempShare.ViewLimit,
+			Threshold: 2,
+		})
+	}
+
+	if len(tempShares) < 2 {
+		return "", nil, models.ErrThresholdNotMet
+	}
+
+	return mockTempShare.Text, tempShares, nil
+}
+
+func (model *TempShareModel) Insert(ctx context.Context, urlToken []byte, manageToken []byte, passphrase []byte, text string, expires int, viewlimit int, notify string, notBefore time.Time) error {
 
 	return nil
 }
-func (model *TempShareModel) Get(plaintextToken string) (*models.TempShare, error) {
+func (model *TempShareModel) Get(ctx context.Context, plaintextToken string, passphrase string) (*models.TempShare, error) {
 
-	if plaintextToken == "MUPPH5PDKV7AGCUAAEERL5ARIXICVVGYLRIV365X5XSV3EKISAXQ" {
+	switch plaintextToken {
+	case "MUPPH5PDKV7AGCUAAEERL5ARIXICVVGYLRIV365X5XSV3EKISAXQ":
 		// TODO: Update(...)
 
 		return mockTempShare, nil
+	case "P7ZJ2K5RXQ3MWN4VBTYHC6LDGAEUFSO2I7ZQ3XK5RWMN4VBTYHCQ":
+		if passphrase != "ABCDE-FGHIJ-KLMNO-PQRST" {
+			return nil, models.ErrInvalidPassphrase
+		}
+
+		return mockPassphraseTempShare, nil
+	case mockSplitTokens[0], mockSplitTokens[1], mockSplitTokens[2]:
+		return nil, models.ErrSplitShare
+	case mockLockedToken:
+		return nil, &models.NotYetAvailableError{NotBefore: mockTempShare.Expires.Add(-time.Hour)}
+	case "EXPIREDTOKENAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA":
+		return nil, models.ErrExpired
+	case "EXHAUSTEDTOKENAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA":
+		return nil, models.ErrViewLimitReached
+	case "REVOKEDTOKENAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA":
+		return nil, models.ErrRevoked
+	}
+
+	return nil, models.ErrNotFound
+}
+
+func (model *TempShareModel) GetManaged(ctx context.Context, plaintextManageToken string) (*models.TempShare, error) {
+
+	if plaintextManageToken == "Q3NXTLOVHBWY2GWCQVJ4WDPHJ6B7TM2ZK5XRDFAE6OUKIRPLSM4Q" {
+		return &models.TempShare{
+			URLToken:  mockTempShare.URLToken,
+			Created:   mockTempShare.Created,
+			Expires:   mockTempShare.Expires,
+			Views:     1,
+			ViewLimit: mockTempShare.ViewLimit,
+		}, nil
 	}
 
 	return nil, models.ErrNoRecord
 }
 
-func (model *TempShareModel) Update(plaintextToken string) error {
+func (model *TempShareModel) GetRecipients(ctx context.Context, plaintextManageToken string) ([]*models.TempShare, error) {
+
+	if plaintextManageToken != mockMultiManageToken {
+		return nil, models.ErrNoRecord
+	}
+
+	tempShares := []*models.TempShare{}
+	for _, recipient := range mockRecipients {
+		tempShare := *recipient
+		tempShares = append(tempShares, &tempShare)
+	}
+
+	return tempShares, nil
+}
+
+func (model *TempShareModel) RevokeRecipient(ctx context.Context, plaintextManageToken string, recipient string) (*models.TempShare, error) {
+
+	tempShares, err := model.GetRecipients(ctx, plaintextManageToken)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, tempShare := range tempShares {
+		if tempShare.Recipient == recipient {
+			tempShare.Revoked = true
+			return tempShare, nil
+		}
+	}
+
+	return nil, models.ErrNoRecord
+}
+
+func (model *TempShareModel) Revoke(ctx context.Context, plaintextManageToken string) (*models.TempShare, error) {
+
+	tempShare, err := model.GetManaged(ctx, plaintextManageToken)
+	if err != nil {
+		return nil, err
+	}
+	tempShare.Revoked = true
+
+	return tempShare, nil
+}
+
+func (model *TempShareModel) SweepExpired(ctx context.Context) ([]*models.TempShare, error) {
+
+	return []*models.TempShare{}, nil
+}
+
+func (model *TempShareModel) Update(ctx context.Context, plaintextToken string) error {
 
 	if plaintextToken == "MUPPH5PDKV7AGCUAAEERL5ARIXICVVGYLRIV365X5XSV3EKISAXQ" {
 		return nil
@@ -52,3 +285,38 @@ func (model *TempShareModel) Update(plaintextToken string) error {
 
 	return models.ErrNoRecord
 }
+
+func (model *TempShareModel) AddView(ctx context.Context, urlToken []byte, client string) error {
+
+	return nil
+}
+
+func (model *TempShareModel) Views(ctx context.Context, urlToken []byte) ([]*models.View, error) {
+
+	return []*models.View{mockView}, nil
+}
+
+// Stats reports mockTempShare as the only active TempShare, created and viewed today
+func (model *TempShareModel) Stats(ctx context.Context, days int) (*models.Stats, error) {
+
+	now := time.Now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	stats := &models.Stats{
+		Active:            1,
+		StorageBytes:      int64(len(mockTempShare.Text)),
+		ExpiringWithinDay: 1,
+	}
+
+	for i := days - 1; i >= 0; i-- {
+		count := 0
+		if i == 0 {
+			count = 1
+		}
+
+		stats.Created = append(stats.Created, models.DailyCount{Day: today.AddDate(0, 0, -i), Count: count})
+		stats.Viewed = append(stats.Viewed, models.DailyCount{Day: today.AddDate(0, 0, -i), Count: count})
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,36 @@
+package mock
+
+import (
+	"context"
+	"time"
+
+	"github.com/matthewlmitchell/tempshare/pkg/models"
+)
+
+// This is a mock version of the WebhookModel{DB: *sql.DB} struct
+type WebhookModel struct{}
+
+func (model *WebhookModel) Enqueue(ctx context.Context, destination string, event string, payload []byte) error {
+
+	return nil
+}
+
+func (model *WebhookModel) Claim(ctx context.Context, limit int, lease time.Duration) ([]*models.Delivery, error) {
+
+	return []*models.Delivery{}, nil
+}
+
+func (model *WebhookModel) Delivered(ctx context.Context, id int64) error {
+
+	return nil
+}
+
+func (model *WebhookModel) Retry(ctx context.Context, id int64, delay time.Duration) error {
+
+	return nil
+}
+
+func (model *WebhookModel) Fail(ctx context.Context, id int64) error {
+
+	return nil
+}
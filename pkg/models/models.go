@@ -2,19 +2,144 @@ package models
 
 import (
 	"errors"
+	"fmt"
 	"time"
 )
 
 var (
-	ErrNoRecord = errors.New("models: no record found matching your request")
+	ErrNoRecord           = errors.New("models: no record found matching your request")
+	ErrInvalidPassphrase  = errors.New("models: missing or incorrect passphrase")
+	ErrSplitShare         = errors.New("models: record is one part of a split secret")
+	ErrNotSplitShare      = errors.New("models: record is not a part of a split secret")
+	ErrThresholdNotMet    = errors.New("models: not enough parts of the split secret")
+	ErrMixedShares        = errors.New("models: parts belong to different split secrets")
+	ErrNotFulfilled       = errors.New("models: request has not been responded to yet")
+	ErrNotYetAvailable    = errors.New("models: record is not available yet")
+	ErrInvalidCredentials = errors.New("models: invalid credentials")
+	ErrDuplicateUsername  = errors.New("models: duplicate username")
+	ErrAccountLocked      = errors.New("models: account is locked")
 )
 
+// Errors returned when a TempShare can't be viewed, saying why without revealing anything
+// about its content. All of them match ErrNoRecord with errors.Is.
+var (
+	ErrNotFound         = fmt.Errorf("%w: unknown token", ErrNoRecord)
+	ErrExpired          = fmt.Errorf("%w: expired", ErrNoRecord)
+	ErrViewLimitReached = fmt.Errorf("%w: view limit reached", ErrNoRecord)
+	ErrRevoked          = fmt.Errorf("%w: revoked", ErrNoRecord)
+)
+
+// NotYetAvailableError is returned when a time-locked record is accessed before its
+// NotBefore time. It matches ErrNotYetAvailable with errors.Is, and can be unwrapped with
+// errors.As to find out when the record becomes available.
+type NotYetAvailableError struct {
+	NotBefore time.Time
+}
+
+func (e *NotYetAvailableError) Error() string {
+	return fmt.Sprintf("%s until %s", ErrNotYetAvailable, e.NotBefore.UTC().Format(time.RFC3339))
+}
+
+func (e *NotYetAvailableError) Is(target error) bool {
+	return target == ErrNotYetAvailable
+}
+
 type TempShare struct {
-	Text      string
-	PlainText string
-	URLToken  []byte
-	Created   time.Time
-	Expires   time.Time
-	Views     int
-	ViewLimit int
+	Text        string
+	PlainText   string
+	URLToken    []byte
+	ManageToken string
+	Passphrase  string
+	Notify      string
+	Created     time.Time
+	Expires     time.Time
+	NotBefore   time.Time // The text can't be viewed before this time, unless it is zero
+	Views       int
+	ViewLimit   int
+	Revoked     bool
+	Threshold   int    // Number of parts required to reconstruct a split secret, 0 if not split
+	Recipient   string // Name of the recipient the link was minted for, if the text was sent to several
+}
+
+// RequestReadWindow is how long the response to a Request can be read after it was submitted
+const RequestReadWindow = 7 * 24 * time.Hour
+
+// Request is a reverse share: the requester hands out a link which can be used once to
+// respond with some text, which only the holder of ReadToken can then view.
+// UploadToken and ReadToken are only set when the Request is created.
+type Request struct {
+	UploadToken string
+	ReadToken   string
+	Text        string
+	Created     time.Time
+	Expires     time.Time
+	Fulfilled   time.Time
+}
+
+// View is a single record of a TempShare being opened by a recipient.
+// Client only holds coarse information (e.g. browser family and a truncated IP address).
+type View struct {
+	Viewed time.Time
+	Client string
+}
+
+// Delivery is a webhook event queued for delivery to a single destination
+type Delivery struct {
+	ID          int64
+	Destination string
+	Event       string
+	Payload     []byte
+	Attempts    int
+	NextAttempt time.Time
+	Created     time.Time
+}
+
+// Stats are aggregate statistics of the stored TempShares, which reveal nothing about any
+// single one of them. Every part of a split secret and every recipient counts as a TempShare.
+type Stats struct {
+	Active       int   // TempShares which can still be viewed
+	TimeLocked   int   // Active TempShares which can't be viewed yet
+	StorageBytes int64 // Size of every stored text, including responses to Requests
+
+	// Active TempShares by when they expire
+	ExpiringWithinHour      int
+	ExpiringWithinDay       int
+	ExpiringWithinThreeDays int
+	ExpiringLater           int
+
+	Created []DailyCount // TempShares created on each day, oldest first
+	Viewed  []DailyCount // TempShares opened on each day, oldest first
+}
+
+// DailyCount is the number of times something happened on the UTC day starting at Day
+type DailyCount struct {
+	Day   time.Time
+	Count int
+}
+
+// An admin account is locked for TOTPLockout after MaxTOTPAttempts incorrect TOTP codes in a
+// row, and locked again by every incorrect code after that, until a correct code is entered
+const (
+	MaxTOTPAttempts = 5
+	TOTPLockout     = 15 * time.Minute
+)
+
+// An admin account is likewise locked for PasswordLockout after MaxPasswordAttempts incorrect
+// passwords in a row, until a correct password is entered once the lock has expired
+const (
+	MaxPasswordAttempts = 5
+	PasswordLockout     = 15 * time.Minute
+)
+
+// Admin is a local operator account. Signing in takes the password, checked against its
+// Argon2id hash, and then a TOTP code from the authenticator app set up with TOTPSecret.
+// Sessions are only valid for the SessionGeneration they were signed in with, which is
+// incremented to sign every session of the admin out.
+type Admin struct {
+	ID                int
+	Username          string
+	PasswordHash      string
+	TOTPSecret        string
+	SessionGeneration int
+	Created           time.Time
 }
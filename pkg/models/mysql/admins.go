@@ -0,0 +1,211 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	driver "github.com/go-sql-driver/mysql"
+	"github.com/matthewlmitchell/tempshare/pkg/auth"
+	"github.com/matthewlmitchell/tempshare/pkg/models"
+)
+
+// The MySQL error number of a duplicate entry for a unique index
+const errDuplicateEntry = 1062
+
+type AdminModel struct {
+	DB *sql.DB
+}
+
+// Insert creates an admin account, storing the Argon2id hash of password along with the
+// base32 encoded TOTP secret that the admin's authenticator app is set up with
+func (model *AdminModel) Insert(ctx context.Context, username string, password string, totpSecret string) error {
+
+	passwordHash, err := auth.HashPassword(password, auth.DefaultParams)
+	if err != nil {
+		return err
+	}
+
+	sqlStatement := `INSERT INTO admins (username, passwordhash, totpsecret, lasttotpstep, created)
+	VALUES(?, ?, ?, 0, UTC_TIMESTAMP())`
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	_, err = model.DB.ExecContext(ctx, sqlStatement, username, passwordHash, totpSecret)
+
+	var mySQLError *driver.MySQLError
+	if errors.As(err, &mySQLError) && mySQLError.Number == errDuplicateEntry {
+		return models.ErrDuplicateUsername
+	}
+
+	return err
+}
+
+// Authenticate returns the ID of the admin if password is theirs, or models.ErrInvalidCredentials
+// if the username is unknown or the password is incorrect. The TOTP code still has to be
+// checked with VerifyTOTP before the admin is signed in. Incorrect passwords are counted like
+// incorrect TOTP codes, and lock the account (see models.MaxPasswordAttempts), after which
+// models.ErrAccountLocked is returned without checking the password.
+func (model *AdminModel) Authenticate(ctx context.Context, username string, password string) (int, error) {
+
+	selectStatement := `SELECT id, passwordhash, lockeduntil > UTC_TIMESTAMP() FROM admins WHERE username = ?`
+
+	// The assignments are made in order, so lockeduntil sees the incremented count
+	failedStatement := `UPDATE admins SET failedpasswordattempts = failedpasswordattempts + 1,
+	lockeduntil = IF(failedpasswordattempts >= ?, DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? SECOND), lockeduntil)
+	WHERE id = ?`
+
+	acceptedStatement := `UPDATE admins SET failedpasswordattempts = 0 WHERE id = ?`
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var id int
+	var passwordHash string
+	var locked sql.NullBool
+
+	err := model.DB.QueryRowContext(ctx, selectStatement, username).Scan(&id, &passwordHash, &locked)
+	if err == sql.ErrNoRows {
+		// A password is hashed either way, so that the time taken doesn't tell which usernames exist
+		auth.HashPassword(password, auth.DefaultParams)
+		return 0, models.ErrInvalidCredentials
+	} else if err != nil {
+		return 0, err
+	}
+
+	if locked.Bool {
+		return 0, models.ErrAccountLocked
+	}
+
+	match, err := auth.CheckPassword(passwordHash, password)
+	if err != nil {
+		return 0, err
+	}
+
+	if match {
+		if _, err = model.DB.ExecContext(ctx, acceptedStatement, id); err != nil {
+			return 0, err
+		}
+		return id, nil
+	}
+
+	_, err = model.DB.ExecContext(ctx, failedStatement, models.MaxPasswordAttempts, int(models.PasswordLockout.Seconds()), id)
+	if err != nil {
+		return 0, err
+	}
+
+	// The admin is told if this password was the one which locked the account
+	err = model.DB.QueryRowContext(ctx, selectStatement, username).Scan(&id, &passwordHash, &locked)
+	if err != nil {
+		return 0, err
+	}
+	if locked.Bool {
+		return 0, models.ErrAccountLocked
+	}
+
+	return 0, models.ErrInvalidCredentials
+}
+
+// VerifyTOTP checks a TOTP code of the admin, returning models.ErrInvalidCredentials if it
+// is incorrect. Each code can only be used once, so that a code seen over someone's shoulder
+// can't be used to sign in again while it is still valid. Incorrect codes are counted in the
+// database rather than the session, which a client could simply replay, and lock the account
+// (see models.MaxTOTPAttempts), after which models.ErrAccountLocked is returned.
+func (model *AdminModel) VerifyTOTP(ctx context.Context, id int, code string) error {
+
+	selectStatement := `SELECT totpsecret, lasttotpstep, lockeduntil > UTC_TIMESTAMP() FROM admins WHERE id = ?`
+
+	// The assignments are made in order, so lockeduntil sees the incremented count
+	failedStatement := `UPDATE admins SET failedtotpattempts = failedtotpattempts + 1,
+	lockeduntil = IF(failedtotpattempts >= ?, DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? SECOND), lockeduntil)
+	WHERE id = ?`
+
+	acceptedStatement := `UPDATE admins SET lasttotpstep = ?, failedtotpattempts = 0, lockeduntil = NULL
+	WHERE id = ? AND lasttotpstep < ? AND (lockeduntil IS NULL OR lockeduntil <= UTC_TIMESTAMP())`
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var secret string
+	var lastStep int64
+	var locked sql.NullBool
+
+	err := model.DB.QueryRowContext(ctx, selectStatement, id).Scan(&secret, &lastStep, &locked)
+	if err == sql.ErrNoRows {
+		return models.ErrInvalidCredentials
+	} else if err != nil {
+		return err
+	}
+
+	if locked.Bool {
+		return models.ErrAccountLocked
+	}
+
+	step, ok := auth.ValidateTOTP(secret, code, time.Now())
+	if ok && step > lastStep {
+		// Only the first of two concurrent sign-ins with the same code will update the row
+		result, err := model.DB.ExecContext(ctx, acceptedStatement, step, id, step)
+		if err != nil {
+			return err
+		}
+
+		numRowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if numRowsAffected == 1 {
+			return nil
+		}
+	}
+
+	_, err = model.DB.ExecContext(ctx, failedStatement, models.MaxTOTPAttempts, int(models.TOTPLockout.Seconds()), id)
+	if err != nil {
+		return err
+	}
+
+	// The admin is told if this code was the one which locked the account
+	err = model.DB.QueryRowContext(ctx, selectStatement, id).Scan(&secret, &lastStep, &locked)
+	if err != nil {
+		return err
+	}
+	if locked.Bool {
+		return models.ErrAccountLocked
+	}
+
+	return models.ErrInvalidCredentials
+}
+
+// Get returns the admin with the given ID, or models.ErrNoRecord if the account doesn't exist
+func (model *AdminModel) Get(ctx context.Context, id int) (*models.Admin, error) {
+
+	sqlStatement := `SELECT id, username, passwordhash, totpsecret, sessiongeneration, created FROM admins WHERE id = ?`
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	admin := &models.Admin{}
+
+	err := model.DB.QueryRowContext(ctx, sqlStatement, id).Scan(&admin.ID, &admin.Username, &admin.PasswordHash, &admin.TOTPSecret, &admin.SessionGeneration, &admin.Created)
+	if err == sql.ErrNoRows {
+		return nil, models.ErrNoRecord
+	} else if err != nil {
+		return nil, err
+	}
+
+	return admin, nil
+}
+
+// RevokeSessions signs every session of the admin out, by incrementing their session generation
+func (model *AdminModel) RevokeSessions(ctx context.Context, id int) error {
+
+	sqlStatement := `UPDATE admins SET sessiongeneration = sessiongeneration + 1 WHERE id = ?`
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	_, err := model.DB.ExecContext(ctx, sqlStatement, id)
+
+	return err
+}
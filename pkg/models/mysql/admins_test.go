@@ -0,0 +1,136 @@
+package mysql
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/matthewlmitchell/tempshare/pkg/auth"
+	"github.com/matthewlmitchell/tempshare/pkg/models"
+)
+
+func TestAdminLifecycle(t *testing.T) {
+	db, teardown := newTestDatabase(t)
+	defer teardown()
+
+	model := &AdminModel{db}
+
+	secret, err := auth.NewTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = model.Insert(context.Background(), "alice", "correct horse battery staple", secret); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = model.Insert(context.Background(), "alice", "another password", secret); err != models.ErrDuplicateUsername {
+		t.Errorf("Expected %v, received %v", models.ErrDuplicateUsername, err)
+	}
+
+	testCases := []struct {
+		name          string
+		username      string
+		password      string
+		expectedError error
+	}{
+		{"Valid credentials", "alice", "correct horse battery staple", nil},
+		{"Incorrect password", "alice", "Correct horse battery staple", models.ErrInvalidCredentials},
+		{"Unknown username", "bob", "correct horse battery staple", models.ErrInvalidCredentials},
+	}
+
+	var id int
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			adminID, err := model.Authenticate(context.Background(), testCase.username, testCase.password)
+			if err != testCase.expectedError {
+				t.Fatalf("Expected %v, received %v", testCase.expectedError, err)
+			}
+			if err == nil {
+				id = adminID
+			}
+		})
+	}
+
+	admin, err := model.Get(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if admin.Username != "alice" || admin.TOTPSecret != secret {
+		t.Errorf("Unexpected admin %+v", admin)
+	}
+	if admin.PasswordHash == "correct horse battery staple" {
+		t.Error("Expected the password to be hashed")
+	}
+
+	if err = model.RevokeSessions(context.Background(), id); err != nil {
+		t.Fatal(err)
+	}
+	revoked, err := model.Get(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if revoked.SessionGeneration != admin.SessionGeneration+1 {
+		t.Errorf("Expected session generation %d, received %d", admin.SessionGeneration+1, revoked.SessionGeneration)
+	}
+
+	if _, err = model.Get(context.Background(), id+1); err != models.ErrNoRecord {
+		t.Errorf("Expected %v, received %v", models.ErrNoRecord, err)
+	}
+
+	if err = model.VerifyTOTP(context.Background(), id, "000000"); err != models.ErrInvalidCredentials {
+		t.Errorf("Expected %v, received %v", models.ErrInvalidCredentials, err)
+	}
+
+	code, err := auth.TOTP(secret, auth.TOTPStep(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = model.VerifyTOTP(context.Background(), id, code); err != nil {
+		t.Errorf("Expected %v, received %v", nil, err)
+	}
+
+	// A code can only be used once
+	if err = model.VerifyTOTP(context.Background(), id, code); err != models.ErrInvalidCredentials {
+		t.Errorf("Expected %v, received %v", models.ErrInvalidCredentials, err)
+	}
+
+	// The one incorrect code before was forgotten when the correct one was entered
+	for attempt := 1; attempt < models.MaxTOTPAttempts; attempt++ {
+		if err = model.VerifyTOTP(context.Background(), id, "000000"); err != models.ErrInvalidCredentials {
+			t.Fatalf("Expected %v, received %v", models.ErrInvalidCredentials, err)
+		}
+	}
+	if err = model.VerifyTOTP(context.Background(), id, "000000"); err != models.ErrAccountLocked {
+		t.Errorf("Expected %v, received %v", models.ErrAccountLocked, err)
+	}
+
+	nextCode, err := auth.TOTP(secret, auth.TOTPStep(time.Now())+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = model.VerifyTOTP(context.Background(), id, nextCode); err != models.ErrAccountLocked {
+		t.Errorf("Expected %v, received %v", models.ErrAccountLocked, err)
+	}
+
+	if err = model.Insert(context.Background(), "carol", "correct horse battery staple", secret); err != nil {
+		t.Fatal(err)
+	}
+
+	for attempt := 1; attempt < models.MaxPasswordAttempts; attempt++ {
+		if _, err = model.Authenticate(context.Background(), "carol", "incorrect password"); err != models.ErrInvalidCredentials {
+			t.Fatalf("Expected %v, received %v", models.ErrInvalidCredentials, err)
+		}
+	}
+	if _, err = model.Authenticate(context.Background(), "carol", "incorrect password"); err != models.ErrAccountLocked {
+		t.Errorf("Expected %v, received %v", models.ErrAccountLocked, err)
+	}
+
+	// Not even the correct password is accepted while the account is locked
+	if _, err = model.Authenticate(context.Background(), "carol", "correct horse battery staple"); err != models.ErrAccountLocked {
+		t.Errorf("Expected %v, received %v", models.ErrAccountLocked, err)
+	}
+}
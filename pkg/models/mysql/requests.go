@@ -0,0 +1,150 @@
+package mysql
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"time"
+
+	"github.com/matthewlmitchell/tempshare/pkg/models"
+)
+
+type RequestModel struct {
+	DB *sql.DB
+}
+
+// New creates a Request which can be responded to for the given number of days.
+// Two random tokens are generated: one for the link handed to the responder, and one
+// kept by the requester to read the response. Only their sha256 hashes are stored.
+func (model *RequestModel) New(ctx context.Context, expires int) (*models.Request, error) {
+	request := &models.Request{
+		Expires: time.Now().Add(time.Duration(expires*24) * time.Hour),
+	}
+
+	var err error
+
+	request.UploadToken, err = generateToken()
+	if err != nil {
+		return nil, err
+	}
+
+	request.ReadToken, err = generateToken()
+	if err != nil {
+		return nil, err
+	}
+
+	sqlStatement := `INSERT INTO requests (uploadtoken, readtoken, text, created, expires, fulfilled, viewed)
+	VALUES(?, ?, NULL, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY), NULL, FALSE)`
+
+	uploadTokenHash := sha256.Sum256([]byte(request.UploadToken))
+	readTokenHash := sha256.Sum256([]byte(request.ReadToken))
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	_, err = model.DB.ExecContext(ctx, sqlStatement, uploadTokenHash[:], readTokenHash[:], expires)
+
+	return request, err
+}
+
+// Pending accepts the base32 encoded upload token of a Request and returns it
+// if it can still be responded to, i.e. it has neither expired nor been responded to.
+func (model *RequestModel) Pending(ctx context.Context, plaintextUploadToken string) (*models.Request, error) {
+
+	sqlStatement := `SELECT created, expires FROM requests
+	WHERE expires > UTC_TIMESTAMP() AND fulfilled IS NULL AND uploadtoken = ?`
+
+	uploadTokenHash := sha256.Sum256([]byte(plaintextUploadToken))
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	request := &models.Request{}
+
+	err := model.DB.QueryRowContext(ctx, sqlStatement, uploadTokenHash[:]).Scan(&request.Created, &request.Expires)
+	if err == sql.ErrNoRows {
+		return nil, models.ErrNoRecord
+	} else if err != nil {
+		return nil, err
+	}
+
+	return request, nil
+}
+
+// Fulfil stores the response to a pending Request. A Request can only be responded to once,
+// so that nobody else holding the link can overwrite the response.
+func (model *RequestModel) Fulfil(ctx context.Context, plaintextUploadToken string, text string) error {
+
+	sqlStatement := `UPDATE requests SET text = ?, fulfilled = UTC_TIMESTAMP()
+	WHERE expires > UTC_TIMESTAMP() AND fulfilled IS NULL AND uploadtoken = ?`
+
+	uploadTokenHash := sha256.Sum256([]byte(plaintextUploadToken))
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	result, err := model.DB.ExecContext(ctx, sqlStatement, text, uploadTokenHash[:])
+	if err != nil {
+		return err
+	}
+
+	numRowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if numRowsAffected == 0 {
+		return models.ErrNoRecord
+	}
+
+	return nil
+}
+
+// Get accepts the base32 encoded read token of a Request and returns its response, which can
+// only be read once and only within models.RequestReadWindow of being submitted. If the Request hasn't
+// been responded to yet, models.ErrNotFulfilled is returned and nothing is consumed.
+func (model *RequestModel) Get(ctx context.Context, plaintextReadToken string) (*models.Request, error) {
+
+	selectStatement := `SELECT text, created, expires, fulfilled FROM requests
+	WHERE viewed = FALSE AND readtoken = ? AND (fulfilled IS NULL AND expires > UTC_TIMESTAMP()
+	OR fulfilled > DATE_SUB(UTC_TIMESTAMP(), INTERVAL ? SECOND))`
+
+	updateStatement := `UPDATE requests SET viewed = TRUE WHERE viewed = FALSE AND readtoken = ?`
+
+	readTokenHash := sha256.Sum256([]byte(plaintextReadToken))
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	request := &models.Request{}
+	var text sql.NullString
+	var fulfilled sql.NullTime
+
+	err := model.DB.QueryRowContext(ctx, selectStatement, readTokenHash[:], int(models.RequestReadWindow.Seconds())).Scan(&text, &request.Created, &request.Expires, &fulfilled)
+	if err == sql.ErrNoRows {
+		return nil, models.ErrNoRecord
+	} else if err != nil {
+		return nil, err
+	}
+
+	if !fulfilled.Valid {
+		return nil, models.ErrNotFulfilled
+	}
+	request.Text = text.String
+	request.Fulfilled = fulfilled.Time
+
+	// Only the first of two concurrent reads will update the row
+	result, err := model.DB.ExecContext(ctx, updateStatement, readTokenHash[:])
+	if err != nil {
+		return nil, err
+	}
+
+	numRowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if numRowsAffected == 0 {
+		return nil, models.ErrNoRecord
+	}
+
+	return request, nil
+}
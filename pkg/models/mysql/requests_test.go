@@ -0,0 +1,62 @@
+package mysql
+
+import (
+	"context"
+	"testing"
+
+	"github.com/matthewlmitchell/tempshare/pkg/models"
+)
+
+func TestRequestLifecycle(t *testing.T) {
+	db, teardown := newTestDatabase(t)
+	defer teardown()
+
+	model := &RequestModel{db}
+
+	request, err := model.New(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if request.UploadToken == request.ReadToken {
+		t.Fatal("Expected distinct upload and read tokens")
+	}
+
+	// The upload token must not be usable for reading and vice versa
+	if _, err = model.Get(context.Background(), request.UploadToken); err != models.ErrNoRecord {
+		t.Errorf("Expected %v, received %v", models.ErrNoRecord, err)
+	}
+	if _, err = model.Pending(context.Background(), request.ReadToken); err != models.ErrNoRecord {
+		t.Errorf("Expected %v, received %v", models.ErrNoRecord, err)
+	}
+
+	if _, err = model.Get(context.Background(), request.ReadToken); err != models.ErrNotFulfilled {
+		t.Errorf("Expected %v, received %v", models.ErrNotFulfilled, err)
+	}
+
+	if _, err = model.Pending(context.Background(), request.UploadToken); err != nil {
+		t.Errorf("Expected %v, received %v", nil, err)
+	}
+
+	if err = model.Fulfil(context.Background(), request.UploadToken, "hunter2"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A request can only be responded to once
+	if err = model.Fulfil(context.Background(), request.UploadToken, "overwritten"); err != models.ErrNoRecord {
+		t.Errorf("Expected %v, received %v", models.ErrNoRecord, err)
+	}
+
+	response, err := model.Get(context.Background(), request.ReadToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Text != "hunter2" {
+		t.Errorf("Expected %s, received %s", "hunter2", response.Text)
+	}
+
+	// A response can only be read once
+	if _, err = model.Get(context.Background(), request.ReadToken); err != models.ErrNoRecord {
+		t.Errorf("Expected %v, received %v", models.ErrNoRecord, err)
+	}
+}
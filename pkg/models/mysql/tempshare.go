@@ -1,15 +1,19 @@
 package mysql
 
 import (
+	"bytes"
 	"context"
 	"crypto/rand"
 	"crypto/sha256"
+	"crypto/subtle"
 	"database/sql"
 	"encoding/base32"
-	"strconv"
+	"encoding/base64"
+	"strings"
 	"time"
 
 	"github.com/matthewlmitchell/tempshare/pkg/models"
+	"github.com/matthewlmitchell/tempshare/pkg/shamir"
 )
 
 type TempShareModel struct {
@@ -19,23 +23,26 @@ type TempShareModel struct {
 // New generates a sha256 hash of a supplied text string, then calls Insert to create a
 // new entry in our SQL database. After insertion, a *models.TempShare struct is returned
 // containing the necessary info for retrieving the data from the SQL db.
-func (model *TempShareModel) New(text string, expires string, viewlimit string) (*models.TempShare, error) {
-	maxViews, err := strconv.Atoi(viewlimit)
+// If passphrase is true, a random passphrase is also generated which must be supplied
+// alongside the token to view the TempShare. If notBefore is non-zero, the TempShare
+// can't be viewed until that time.
+func (model *TempShareModel) New(ctx context.Context, text string, expires int, viewlimit int, notify string, passphrase bool, notBefore time.Time) (*models.TempShare, error) {
+	tempShare, err := generateTempShare(text, expires, viewlimit, passphrase)
 	if err != nil {
 		return nil, err
 	}
+	tempShare.Notify = notify
+	tempShare.NotBefore = notBefore
 
-	expiry, err := strconv.Atoi(expires)
-	if err != nil {
-		return nil, err
-	}
+	manageTokenHash := sha256.Sum256([]byte(tempShare.ManageToken))
 
-	tempShare, err := generateTempShare(text, expiry, maxViews)
-	if err != nil {
-		return nil, err
+	// An empty passphrase hash is stored for TempShares which don't require a passphrase
+	passphraseHash := []byte{}
+	if tempShare.Passphrase != "" {
+		passphraseHash = hashPassphrase(tempShare.Passphrase)
 	}
 
-	err = model.Insert(tempShare.URLToken, tempShare.Text, expires, tempShare.ViewLimit)
+	err = model.Insert(ctx, tempShare.URLToken, manageTokenHash[:], passphraseHash, tempShare.Text, expires, tempShare.ViewLimit, tempShare.Notify, tempShare.NotBefore)
 	return tempShare, err
 }
 
@@ -43,14 +50,18 @@ func (model *TempShareModel) New(text string, expires string, viewlimit string)
 // and return a token string for formatting into a shareable URL
 // The primary key is a sha256 hash used as a token in the URL
 // e.g. /view?token=XXXXXX
-func (model *TempShareModel) Insert(urlToken []byte, text string, expires string, viewlimit int) error {
+// The manageToken is a sha256 hash of a second token which is given only to the creator,
+// passphrase is a sha256 hash of the passphrase required to view the text (or empty),
+// notify is an optional email address to send read receipts to, and notBefore is the time
+// the text becomes available (NULL if zero).
+func (model *TempShareModel) Insert(ctx context.Context, urlToken []byte, manageToken []byte, passphrase []byte, text string, expires int, viewlimit int, notify string, notBefore time.Time) error {
 
-	sqlStatement := `INSERT INTO texts (urltoken, text, created, expires, views, viewlimit) 
-	VALUES(?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY), ?, ?)`
+	sqlStatement := `INSERT INTO texts (urltoken, managetoken, passphrase, text, created, expires, notbefore, views, viewlimit, notify) 
+	VALUES(?, ?, ?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY), ?, ?, ?, ?)`
 
-	sqlArgs := []interface{}{urlToken, text, expires, 0, viewlimit}
+	sqlArgs := []interface{}{urlToken, manageToken, passphrase, text, expires, nullTime(notBefore), 0, viewlimit, notify}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
 	defer cancel()
 
 	_, err := model.DB.ExecContext(ctx, sqlStatement, sqlArgs...)
@@ -61,46 +72,111 @@ func (model *TempShareModel) Insert(urlToken []byte, text string, expires string
 // Get accepts a base32 encoded string as a primary key and retrieves the corresponding
 // entry from our SQL database if it exists (and if it is not expired/exceeding view limits).
 // The data is scanned into a models.TempShare{} struct and returned,
-// the view count of the DB entry is then incremented to reflect that the data has been accessed
-func (model *TempShareModel) Get(plaintextToken string) (*models.TempShare, error) {
-
-	sqlStatement := `SELECT urltoken, text, created, expires, views, viewlimit FROM texts
-	WHERE expires > UTC_TIMESTAMP() AND views < viewlimit AND urltoken = ?`
+// the view count of the DB entry is then incremented to reflect that the data has been accessed.
+// If the entry can't be viewed, the error says why: models.ErrNotFound, models.ErrRevoked,
+// models.ErrViewLimitReached or models.ErrExpired.
+// If the entry is time-locked, a *models.NotYetAvailableError is returned until its notbefore time,
+// and if it requires a passphrase and the supplied one doesn't match, models.ErrInvalidPassphrase
+// is returned. In both cases the view count is left untouched.
+func (model *TempShareModel) Get(ctx context.Context, plaintextToken string, passphrase string) (*models.TempShare, error) {
+
+	// The text of a TempShare sent to several recipients is stored once in payloads
+	sqlStatement := `SELECT t.urltoken, COALESCE(p.text, t.text), t.sharegroup, t.passphrase, t.threshold, t.recipient, t.notify,
+	t.created, t.expires, t.notbefore, t.views, t.viewlimit, t.revoked, t.expires <= UTC_TIMESTAMP() FROM texts t
+	LEFT JOIN payloads p ON p.sharegroup = t.sharegroup
+	WHERE t.urltoken = ?`
 
 	urlTokenHash := sha256.Sum256([]byte(plaintextToken))
 	urlToken := urlTokenHash[:]
 
-	sqlRow := model.DB.QueryRow(sqlStatement, urlToken)
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	sqlRow := model.DB.QueryRowContext(ctx, sqlStatement, urlToken)
 
 	tempShare := &models.TempShare{}
+	var shareGroup []byte
+	var passphraseHash []byte
+	var notBefore sql.NullTime
+	var expired bool
 
-	err := sqlRow.Scan(&tempShare.URLToken, &tempShare.Text, &tempShare.Created, &tempShare.Expires, &tempShare.Views, &tempShare.ViewLimit)
+	err := sqlRow.Scan(&tempShare.URLToken, &tempShare.Text, &shareGroup, &passphraseHash, &tempShare.Threshold, &tempShare.Recipient, &tempShare.Notify,
+		&tempShare.Created, &tempShare.Expires, &notBefore, &tempShare.Views, &tempShare.ViewLimit, &tempShare.Revoked, &expired)
 	if err == sql.ErrNoRows {
-		return nil, models.ErrNoRecord
+		return nil, models.ErrNotFound
 	} else if err != nil {
 		return nil, err
 	}
+	tempShare.NotBefore = notBefore.Time
 
-	// Increment the number of views for the MySQL record
-	err = model.Update(plaintextToken)
+	if err = checkState(tempShare, expired); err != nil {
+		return nil, err
+	}
+
+	if err = checkNotBefore(tempShare); err != nil {
+		return nil, err
+	}
+
+	// A single part of a split secret is useless on its own, so don't waste a view on it
+	if tempShare.Threshold > 0 {
+		return nil, models.ErrSplitShare
+	}
+
+	// Check the passphrase before the view is consumed, comparing in constant time
+	// so the response time doesn't leak how much of the hash matched
+	if len(passphraseHash) > 0 && subtle.ConstantTimeCompare(passphraseHash, hashPassphrase(passphrase)) != 1 {
+		return nil, models.ErrInvalidPassphrase
+	}
+
+	// Increment the number of views for the MySQL record. If that fails, the last
+	// view was used up by someone else since the entry was selected.
+	err = model.Update(ctx, plaintextToken)
 	if err == models.ErrNoRecord {
-		return nil, models.ErrNoRecord
+		return nil, models.ErrViewLimitReached
 	} else if err != nil {
 		return nil, err
 	}
 
+	// The view may have used up the last recipient of a shared text
+	if tempShare.Recipient != "" {
+		if err = model.clearPayload(ctx, shareGroup); err != nil {
+			return nil, err
+		}
+	}
+
 	return tempShare, nil
 }
 
+// clearPayload clears the text shared by the recipients of shareGroup once none of them can
+// view it any more, as Update does for a single TempShare. Only the payload is cleared, the
+// entries of the recipients are kept as tombstones until they expire.
+func (model *TempShareModel) clearPayload(ctx context.Context, shareGroup []byte) error {
+
+	sqlStatement := `UPDATE payloads p SET p.text = ''
+	WHERE p.sharegroup = ? AND p.text != '' AND NOT EXISTS (SELECT 1 FROM texts t
+	WHERE t.sharegroup = p.sharegroup AND t.views < t.viewlimit AND t.revoked = FALSE AND t.expires > UTC_TIMESTAMP())`
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	_, err := model.DB.ExecContext(ctx, sqlStatement, shareGroup)
+
+	return err
+}
+
 // Update accepts a string (which should be base32 encoded), which is our primary key
 // after taking a sha256 hash, and attempts to increment the view count of the
 // corresponding row in our SQL database.
-func (model *TempShareModel) Update(plaintextToken string) error {
+// Once the last view has been used, the text is cleared and only a tombstone of the
+// entry (its hash and final state) is kept.
+func (model *TempShareModel) Update(ctx context.Context, plaintextToken string) error {
 
+	// MySQL assigns from left to right, so views already holds the new count when text is set
 	sqlStatement := `UPDATE texts
-	SET views = views + 1 WHERE urltoken = ?`
+	SET views = views + 1, text = IF(views >= viewlimit, '', text)
+	WHERE urltoken = ? AND views < viewlimit AND revoked = FALSE AND expires > UTC_TIMESTAMP()`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
 	defer cancel()
 
 	urlTokenHash := sha256.Sum256([]byte(plaintextToken))
@@ -125,16 +201,607 @@ func (model *TempShareModel) Update(plaintextToken string) error {
 	return nil
 }
 
+// NewSplit splits a supplied text string into parts using Shamir's secret sharing, any threshold
+// of which are required to reconstruct it. Every part is inserted as its own entry with its own
+// tokens and view limit, all within a single transaction. A *models.TempShare is returned for each part.
+// If notBefore is non-zero, the parts can't be combined until that time.
+func (model *TempShareModel) NewSplit(ctx context.Context, text string, expires int, viewlimit int, parts int, threshold int, notBefore time.Time) ([]*models.TempShare, error) {
+	shares, err := shamir.Split([]byte(text), parts, threshold)
+	if err != nil {
+		return nil, err
+	}
+
+	// Every part of the same secret shares a random group identifier,
+	// so that parts of different secrets can't be combined.
+	shareGroup := make([]byte, 16)
+	if _, err = rand.Read(shareGroup); err != nil {
+		return nil, err
+	}
+
+	sqlStatement := `INSERT INTO texts (urltoken, managetoken, text, sharegroup, threshold, created, expires, notbefore, views, viewlimit) 
+	VALUES(?, ?, ?, ?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY), ?, ?, ?)`
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	tx, err := model.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	// Rollback is a no-op once the transaction has been committed
+	defer tx.Rollback()
+
+	tempShares := []*models.TempShare{}
+
+	for _, share := range shares {
+		tempShare, err := generateTempShare(base64.StdEncoding.EncodeToString(share), expires, viewlimit, false)
+		if err != nil {
+			return nil, err
+		}
+		tempShare.Threshold = threshold
+		tempShare.NotBefore = notBefore
+
+		manageTokenHash := sha256.Sum256([]byte(tempShare.ManageToken))
+
+		_, err = tx.ExecContext(ctx, sqlStatement, tempShare.URLToken, manageTokenHash[:], tempShare.Text, shareGroup, threshold, expires, nullTime(notBefore), 0, viewlimit)
+		if err != nil {
+			return nil, err
+		}
+
+		tempShares = append(tempShares, tempShare)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return tempShares, nil
+}
+
+// NewMulti sends a supplied text string to several named recipients. The text is stored once,
+// and every recipient is given an entry of their own with its own token, view count and revocation.
+// Everything is inserted within a single transaction. The returned management token covers every
+// recipient, and a *models.TempShare is returned for each recipient in the order they were given.
+func (model *TempShareModel) NewMulti(ctx context.Context, text string, expires int, viewlimit int, notify string, recipients []string, notBefore time.Time) (string, []*models.TempShare, error) {
+	// The payload and the entries of its recipients are linked by a random group identifier
+	shareGroup := make([]byte, 16)
+	if _, err := rand.Read(shareGroup); err != nil {
+		return "", nil, err
+	}
+
+	manageToken, err := generateToken()
+	if err != nil {
+		return "", nil, err
+	}
+	manageTokenHash := sha256.Sum256([]byte(manageToken))
+
+	payloadStatement := `INSERT INTO payloads (sharegroup, managetoken, text) VALUES(?, ?, ?)`
+
+	sqlStatement := `INSERT INTO texts (urltoken, managetoken, text, sharegroup, recipient, created, expires, notbefore, views, viewlimit, notify) 
+	VALUES(?, ?, '', ?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY), ?, ?, ?, ?)`
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	tx, err := model.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	// Rollback is a no-op once the transaction has been committed
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, payloadStatement, shareGroup, manageTokenHash[:], text)
+	if err != nil {
+		return "", nil, err
+	}
+
+	tempShares := []*models.TempShare{}
+
+	for _, recipient := range recipients {
+		tempShare, err := generateTempShare(text, expires, viewlimit, false)
+		if err != nil {
+			return "", nil, err
+		}
+		tempShare.Recipient = recipient
+		tempShare.Notify = notify
+		tempShare.NotBefore = notBefore
+
+		// Each entry still needs a unique management token, but recipients are
+		// only ever managed together through the token of the payload
+		recipientManageTokenHash := sha256.Sum256([]byte(tempShare.ManageToken))
+		tempShare.ManageToken = manageToken
+
+		_, err = tx.ExecContext(ctx, sqlStatement, tempShare.URLToken, recipientManageTokenHash[:], shareGroup, recipient, expires, nullTime(notBefore), 0, viewlimit, notify)
+		if err != nil {
+			return "", nil, err
+		}
+
+		tempShares = append(tempShares, tempShare)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return "", nil, err
+	}
+
+	return manageToken, tempShares, nil
+}
+
+// Combine accepts the base32 encoded tokens of several parts of a split secret and, if they
+// all belong to the same secret and at least its threshold of parts were supplied, reconstructs it.
+// Only then is a view consumed from every part, all within a single transaction, so submitting
+// too few parts (or parts of different secrets) never uses up any views.
+// The reconstructed secret is returned along with the entries of the parts it was combined from.
+// A part which can't be viewed is reported like by Get, e.g. with models.ErrExpired, and a
+// token of a share which isn't split with models.ErrNotSplitShare.
+func (model *TempShareModel) Combine(ctx context.Context, plaintextTokens []string) (string, []*models.TempShare, error) {
+
+	selectStatement := `SELECT urltoken, text, sharegroup, threshold, notify, created, expires, notbefore, views, viewlimit,
+	revoked, expires <= UTC_TIMESTAMP() FROM texts WHERE urltoken = ? FOR UPDATE`
+
+	updateStatement := `UPDATE texts SET views = views + 1, text = IF(views >= viewlimit, '', text) WHERE urltoken = ?`
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	tx, err := model.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	// Rollback is a no-op once the transaction has been committed
+	defer tx.Rollback()
+
+	tempShares := []*models.TempShare{}
+	shares := [][]byte{}
+	seen := map[string]bool{}
+	var shareGroup []byte
+
+	for _, plaintextToken := range plaintextTokens {
+		// The same part pasted twice must only count once towards the threshold
+		if seen[plaintextToken] {
+			continue
+		}
+		seen[plaintextToken] = true
+
+		urlTokenHash := sha256.Sum256([]byte(plaintextToken))
+
+		tempShare := &models.TempShare{}
+		var group []byte
+		var notBefore sql.NullTime
+		var expired bool
+
+		err = tx.QueryRowContext(ctx, selectStatement, urlTokenHash[:]).Scan(&tempShare.URLToken, &tempShare.Text, &group, &tempShare.Threshold,
+			&tempShare.Notify, &tempShare.Created, &tempShare.Expires, &notBefore, &tempShare.Views, &tempShare.ViewLimit,
+			&tempShare.Revoked, &expired)
+		if err == sql.ErrNoRows {
+			return "", nil, models.ErrNotFound
+		} else if err != nil {
+			return "", nil, err
+		}
+		tempShare.NotBefore = notBefore.Time
+
+		if err = checkState(tempShare, expired); err != nil {
+			return "", nil, err
+		}
+
+		if err = checkNotBefore(tempShare); err != nil {
+			return "", nil, err
+		}
+
+		if tempShare.Threshold == 0 {
+			return "", nil, models.ErrNotSplitShare
+		}
+
+		if shareGroup == nil {
+			shareGroup = group
+		} else if !bytes.Equal(shareGroup, group) {
+			return "", nil, models.ErrMixedShares
+		}
+
+		share, err := base64.StdEncoding.DecodeString(tempShare.Text)
+		if err != nil {
+			return "", nil, err
+		}
+		tempShare.Text = ""
+
+		shares = append(shares, share)
+		tempShares = append(tempShares, tempShare)
+	}
+
+	if len(tempShares) == 0 || len(tempShares) < tempShares[0].Threshold {
+		return "", nil, models.ErrThresholdNotMet
+	}
+
+	secret, err := shamir.Combine(shares)
+	if err != nil {
+		return "", nil, err
+	}
+
+	for _, tempShare := range tempShares {
+		_, err = tx.ExecContext(ctx, updateStatement, tempShare.URLToken)
+		if err != nil {
+			return "", nil, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return "", nil, err
+	}
+
+	return string(secret), tempShares, nil
+}
+
+// GetManaged accepts the base32 encoded management token handed to the creator of a TempShare
+// and retrieves the metadata of the corresponding entry. The text of the TempShare is never
+// selected, and retrieving it does not count as a view.
+func (model *TempShareModel) GetManaged(ctx context.Context, plaintextManageToken string) (*models.TempShare, error) {
+
+	sqlStatement := `SELECT urltoken, notify, created, expires, notbefore, views, viewlimit, revoked FROM texts
+	WHERE managetoken = ?`
+
+	manageTokenHash := sha256.Sum256([]byte(plaintextManageToken))
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	sqlRow := model.DB.QueryRowContext(ctx, sqlStatement, manageTokenHash[:])
+
+	tempShare := &models.TempShare{}
+	var notBefore sql.NullTime
+
+	err := sqlRow.Scan(&tempShare.URLToken, &tempShare.Notify, &tempShare.Created, &tempShare.Expires, &notBefore, &tempShare.Views, &tempShare.ViewLimit, &tempShare.Revoked)
+	if err == sql.ErrNoRows {
+		return nil, models.ErrNoRecord
+	} else if err != nil {
+		return nil, err
+	}
+	tempShare.NotBefore = notBefore.Time
+
+	return tempShare, nil
+}
+
+// GetRecipients accepts the base32 encoded management token of a TempShare sent to several
+// recipients and retrieves the metadata of every recipient's entry, ordered by recipient.
+// The text is never selected, and retrieving it does not count as a view.
+func (model *TempShareModel) GetRecipients(ctx context.Context, plaintextManageToken string) ([]*models.TempShare, error) {
+
+	sqlStatement := `SELECT t.urltoken, t.recipient, t.notify, t.created, t.expires, t.notbefore, t.views, t.viewlimit, t.revoked FROM texts t
+	INNER JOIN payloads p ON p.sharegroup = t.sharegroup
+	WHERE p.managetoken = ? ORDER BY t.recipient ASC`
+
+	manageTokenHash := sha256.Sum256([]byte(plaintextManageToken))
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	sqlRows, err := model.DB.QueryContext(ctx, sqlStatement, manageTokenHash[:])
+	if err != nil {
+		return nil, err
+	}
+	defer sqlRows.Close()
+
+	tempShares := []*models.TempShare{}
+
+	for sqlRows.Next() {
+		tempShare := &models.TempShare{}
+		var notBefore sql.NullTime
+
+		err = sqlRows.Scan(&tempShare.URLToken, &tempShare.Recipient, &tempShare.Notify, &tempShare.Created, &tempShare.Expires,
+			&notBefore, &tempShare.Views, &tempShare.ViewLimit, &tempShare.Revoked)
+		if err != nil {
+			return nil, err
+		}
+		tempShare.NotBefore = notBefore.Time
+
+		tempShares = append(tempShares, tempShare)
+	}
+
+	if err = sqlRows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(tempShares) == 0 {
+		return nil, models.ErrNoRecord
+	}
+
+	return tempShares, nil
+}
+
+// RevokeRecipient accepts the base32 encoded management token of a TempShare sent to several
+// recipients and revokes the entry of a single recipient, leaving the others untouched.
+// Once no recipient is left who can view it, the shared text is cleared.
+// The revoked TempShare is returned so that the caller can report which share was revoked.
+func (model *TempShareModel) RevokeRecipient(ctx context.Context, plaintextManageToken string, recipient string) (*models.TempShare, error) {
+
+	sqlStatement := `UPDATE texts t INNER JOIN payloads p ON p.sharegroup = t.sharegroup
+	SET t.revoked = TRUE WHERE p.managetoken = ? AND t.recipient = ? AND t.revoked = FALSE`
+
+	groupStatement := `SELECT sharegroup FROM payloads WHERE managetoken = ?`
+
+	manageTokenHash := sha256.Sum256([]byte(plaintextManageToken))
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	result, err := model.DB.ExecContext(ctx, sqlStatement, manageTokenHash[:], recipient)
+	if err != nil {
+		return nil, err
+	}
+
+	numRowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if numRowsAffected == 0 {
+		return nil, models.ErrNoRecord
+	}
+
+	var shareGroup []byte
+	err = model.DB.QueryRowContext(ctx, groupStatement, manageTokenHash[:]).Scan(&shareGroup)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = model.clearPayload(ctx, shareGroup); err != nil {
+		return nil, err
+	}
+
+	tempShares, err := model.GetRecipients(ctx, plaintextManageToken)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, tempShare := range tempShares {
+		if tempShare.Recipient == recipient {
+			return tempShare, nil
+		}
+	}
+
+	return nil, models.ErrNoRecord
+}
+
+// Revoke accepts the base32 encoded management token of a TempShare and marks the
+// corresponding entry as revoked, after which it can no longer be viewed and its text is cleared.
+// The revoked TempShare is returned so that the caller can report which share was revoked.
+func (model *TempShareModel) Revoke(ctx context.Context, plaintextManageToken string) (*models.TempShare, error) {
+
+	sqlStatement := `UPDATE texts SET revoked = TRUE, text = ''
+	WHERE managetoken = ? AND revoked = FALSE`
+
+	manageTokenHash := sha256.Sum256([]byte(plaintextManageToken))
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	result, err := model.DB.ExecContext(ctx, sqlStatement, manageTokenHash[:])
+	if err != nil {
+		return nil, err
+	}
+
+	numRowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if numRowsAffected == 0 {
+		return nil, models.ErrNoRecord
+	}
+
+	return model.GetManaged(ctx, plaintextManageToken)
+}
+
+// SweepExpired returns every TempShare which has expired since the last sweep,
+// marking them so that each expiry is only ever reported once. The text of every
+// expired TempShare is cleared, leaving only a tombstone of the entry.
+func (model *TempShareModel) SweepExpired(ctx context.Context) ([]*models.TempShare, error) {
+
+	selectStatement := `SELECT urltoken, created, expires, views, viewlimit FROM texts
+	WHERE expires <= UTC_TIMESTAMP() AND expirednotified = FALSE FOR UPDATE`
+
+	updateStatement := `UPDATE texts SET expirednotified = TRUE, text = '' WHERE urltoken = ?`
+
+	payloadStatement := `UPDATE payloads p INNER JOIN texts t ON t.sharegroup = p.sharegroup
+	SET p.text = '' WHERE t.expires <= UTC_TIMESTAMP() AND p.text != ''`
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	tx, err := model.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	// Rollback is a no-op once the transaction has been committed
+	defer tx.Rollback()
+
+	sqlRows, err := tx.QueryContext(ctx, selectStatement)
+	if err != nil {
+		return nil, err
+	}
+
+	tempShares := []*models.TempShare{}
+
+	for sqlRows.Next() {
+		tempShare := &models.TempShare{}
+
+		err = sqlRows.Scan(&tempShare.URLToken, &tempShare.Created, &tempShare.Expires, &tempShare.Views, &tempShare.ViewLimit)
+		if err != nil {
+			sqlRows.Close()
+			return nil, err
+		}
+
+		tempShares = append(tempShares, tempShare)
+	}
+	sqlRows.Close()
+
+	if err = sqlRows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, tempShare := range tempShares {
+		_, err = tx.ExecContext(ctx, updateStatement, tempShare.URLToken)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, payloadStatement)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return tempShares, nil
+}
+
+// AddView records that the TempShare with the given urlToken (sha256 hash) was opened,
+// along with some coarse information about the client that opened it.
+func (model *TempShareModel) AddView(ctx context.Context, urlToken []byte, client string) error {
+
+	sqlStatement := `INSERT INTO views (urltoken, viewed, client) VALUES(?, UTC_TIMESTAMP(), ?)`
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	_, err := model.DB.ExecContext(ctx, sqlStatement, urlToken, client)
+
+	return err
+}
+
+// Views returns the view history of the TempShare with the given urlToken (sha256 hash),
+// oldest first.
+func (model *TempShareModel) Views(ctx context.Context, urlToken []byte) ([]*models.View, error) {
+
+	sqlStatement := `SELECT viewed, client FROM views WHERE urltoken = ? ORDER BY viewed ASC`
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	sqlRows, err := model.DB.QueryContext(ctx, sqlStatement, urlToken)
+	if err != nil {
+		return nil, err
+	}
+	defer sqlRows.Close()
+
+	views := []*models.View{}
+
+	for sqlRows.Next() {
+		view := &models.View{}
+
+		err = sqlRows.Scan(&view.Viewed, &view.Client)
+		if err != nil {
+			return nil, err
+		}
+
+		views = append(views, view)
+	}
+
+	if err = sqlRows.Err(); err != nil {
+		return nil, err
+	}
+
+	return views, nil
+}
+
+// Stats returns aggregate statistics of the stored TempShares, with the number created and
+// viewed on each of the last days. Only the length of texts is read, never their content.
+func (model *TempShareModel) Stats(ctx context.Context, days int) (*models.Stats, error) {
+
+	activeStatement := `SELECT COUNT(*),
+	COALESCE(SUM(notbefore > UTC_TIMESTAMP()), 0),
+	COALESCE(SUM(expires <= DATE_ADD(UTC_TIMESTAMP(), INTERVAL 1 HOUR)), 0),
+	COALESCE(SUM(expires > DATE_ADD(UTC_TIMESTAMP(), INTERVAL 1 HOUR) AND expires <= DATE_ADD(UTC_TIMESTAMP(), INTERVAL 1 DAY)), 0),
+	COALESCE(SUM(expires > DATE_ADD(UTC_TIMESTAMP(), INTERVAL 1 DAY) AND expires <= DATE_ADD(UTC_TIMESTAMP(), INTERVAL 3 DAY)), 0),
+	COALESCE(SUM(expires > DATE_ADD(UTC_TIMESTAMP(), INTERVAL 3 DAY)), 0)
+	FROM texts WHERE revoked = FALSE AND views < viewlimit AND expires > UTC_TIMESTAMP()`
+
+	storageStatement := `SELECT (SELECT COALESCE(SUM(LENGTH(text)), 0) FROM texts)
+	+ (SELECT COALESCE(SUM(LENGTH(text)), 0) FROM payloads)
+	+ (SELECT COALESCE(SUM(LENGTH(text)), 0) FROM requests)`
+
+	createdStatement := `SELECT DATE(created), COUNT(*) FROM texts WHERE created >= ? GROUP BY DATE(created)`
+
+	viewedStatement := `SELECT DATE(viewed), COUNT(*) FROM views WHERE viewed >= ? GROUP BY DATE(viewed)`
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	stats := &models.Stats{}
+
+	err := model.DB.QueryRowContext(ctx, activeStatement).Scan(&stats.Active, &stats.TimeLocked,
+		&stats.ExpiringWithinHour, &stats.ExpiringWithinDay, &stats.ExpiringWithinThreeDays, &stats.ExpiringLater)
+	if err != nil {
+		return nil, err
+	}
+
+	err = model.DB.QueryRowContext(ctx, storageStatement).Scan(&stats.StorageBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	// The first day counted is the one days-1 before today, so that today is the last
+	now := time.Now().UTC()
+	since := time.Date(now.Year(), now.Month(), now.Day()-days+1, 0, 0, 0, 0, time.UTC)
+
+	stats.Created, err = model.dailyCounts(ctx, createdStatement, since, days)
+	if err != nil {
+		return nil, err
+	}
+
+	stats.Viewed, err = model.dailyCounts(ctx, viewedStatement, since, days)
+	if err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// dailyCounts runs a query returning the count of each day from since, and fills in the days
+// without a row with zero counts
+func (model *TempShareModel) dailyCounts(ctx context.Context, sqlStatement string, since time.Time, days int) ([]models.DailyCount, error) {
+
+	sqlRows, err := model.DB.QueryContext(ctx, sqlStatement, since)
+	if err != nil {
+		return nil, err
+	}
+	defer sqlRows.Close()
+
+	counts := map[time.Time]int{}
+
+	for sqlRows.Next() {
+		var day time.Time
+		var count int
+
+		if err = sqlRows.Scan(&day, &count); err != nil {
+			return nil, err
+		}
+
+		counts[day.UTC()] = count
+	}
+
+	if err = sqlRows.Err(); err != nil {
+		return nil, err
+	}
+
+	dailyCounts := make([]models.DailyCount, days)
+	for i := range dailyCounts {
+		day := since.AddDate(0, 0, i)
+		dailyCounts[i] = models.DailyCount{Day: day, Count: counts[day]}
+	}
+
+	return dailyCounts, nil
+}
+
 /* TODO: Remove delete functionality from the webserver's sql privileges and
    move it to a separate program running on a cron.
 
 // Delete removes the row from our SQL database which corresponds
 // to the provided models.TempShare{} struct.
-func (model *TempShareModel) Delete(tempShare *models.TempShare) error {
+func (model *TempShareModel) Delete(ctx context.Context, tempShare *models.TempShare) error {
 
 	sqlStatement := `DELETE from texts WHERE urltoken = ?`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
 	defer cancel()
 
 	result, err := model.DB.ExecContext(ctx, sqlStatement, tempShare.URLToken)
@@ -160,22 +827,105 @@ func (model *TempShareModel) Delete(tempShare *models.TempShare) error {
 // and a maximum view count. These values are parsed into a models.TempShare{}
 // struct, a base32 encoded string is randomly generated to be used as a shareable URL,
 // and a sha256 hash of the URL token is generated.
-func generateTempShare(text string, expires int, viewlimit int) (*models.TempShare, error) {
+// A second randomly generated token is used by the creator to manage the TempShare,
+// and if passphrase is true a third is generated as the passphrase for split delivery.
+func generateTempShare(text string, expires int, viewlimit int, passphrase bool) (*models.TempShare, error) {
 	tempShare := &models.TempShare{
 		Text:      text,
 		Expires:   time.Now().Add(time.Duration(expires*24) * time.Hour),
 		ViewLimit: viewlimit,
 	}
 
-	randBytes := make([]byte, 32)
-	_, err := rand.Read(randBytes)
+	var err error
+
+	tempShare.PlainText, err = generateToken()
 	if err != nil {
 		return nil, err
 	}
-
-	tempShare.PlainText = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randBytes)
 	hash := sha256.Sum256([]byte(tempShare.PlainText))
 	tempShare.URLToken = hash[:]
 
+	tempShare.ManageToken, err = generateToken()
+	if err != nil {
+		return nil, err
+	}
+
+	if passphrase {
+		tempShare.Passphrase, err = generatePassphrase()
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return tempShare, nil
 }
+
+// generatePassphrase returns 100 bits of cryptographically random data as 20 base32 characters
+// split into groups of five, e.g. "ABCDE-FGHIJ-KLMNO-PQRST", which is easy to read out or type.
+func generatePassphrase() (string, error) {
+	randBytes := make([]byte, 13)
+	_, err := rand.Read(randBytes)
+	if err != nil {
+		return "", err
+	}
+
+	encoded := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randBytes)[:20]
+
+	groups := []string{}
+	for i := 0; i < len(encoded); i += 5 {
+		groups = append(groups, encoded[i:i+5])
+	}
+
+	return strings.Join(groups, "-"), nil
+}
+
+// hashPassphrase returns the sha256 hash of a passphrase, ignoring case, spaces and dashes
+// so that "abcde fghij ..." matches "ABCDE-FGHIJ-...". Since passphrases are randomly
+// generated with 100 bits of entropy, a slow password hash isn't necessary.
+func hashPassphrase(passphrase string) []byte {
+	normalized := strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(passphrase))
+
+	hash := sha256.Sum256([]byte(normalized))
+	return hash[:]
+}
+
+// checkState returns the error explaining why tempShare can no longer be viewed, if any.
+// expired is reported by the database so that the check doesn't depend on the local clock.
+func checkState(tempShare *models.TempShare, expired bool) error {
+	switch {
+	case tempShare.Revoked:
+		return models.ErrRevoked
+	case tempShare.Views >= tempShare.ViewLimit:
+		return models.ErrViewLimitReached
+	case expired:
+		return models.ErrExpired
+	}
+
+	return nil
+}
+
+// checkNotBefore returns a *models.NotYetAvailableError if tempShare is time-locked
+// and its notbefore time hasn't been reached yet
+func checkNotBefore(tempShare *models.TempShare) error {
+	if !tempShare.NotBefore.IsZero() && time.Now().Before(tempShare.NotBefore) {
+		return &models.NotYetAvailableError{NotBefore: tempShare.NotBefore}
+	}
+
+	return nil
+}
+
+// nullTime converts a time.Time into a sql.NullTime which is NULL if the time is zero
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t.UTC(), Valid: !t.IsZero()}
+}
+
+// generateToken returns a base32 encoded string of 32 cryptographically random bytes
+func generateToken() (string, error) {
+	randBytes := make([]byte, 32)
+	_, err := rand.Read(randBytes)
+	if err != nil {
+		return "", err
+	}
+
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randBytes), nil
+}
@@ -1,8 +1,11 @@
 package mysql
 
 import (
+	"context"
 	"crypto/sha256"
+	"errors"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -13,8 +16,8 @@ func TestNew(t *testing.T) {
 
 	type tempShareInput struct {
 		Text      string
-		Expires   string
-		ViewLimit string
+		Expires   int
+		ViewLimit int
 	}
 
 	testCases := []struct {
@@ -27,8 +30,8 @@ func TestNew(t *testing.T) {
 			name: "Valid input",
 			inputTempShare: tempShareInput{
 				Text:      "This is an example tempshare for testing purposes!",
-				Expires:   "7",
-				ViewLimit: "10",
+				Expires:   7,
+				ViewLimit: 10,
 			},
 			expectedTempShare: &models.TempShare{
 				Text:      "This is an example tempshare for testing purposes!",
@@ -40,8 +43,8 @@ func TestNew(t *testing.T) {
 			name: "Empty text",
 			inputTempShare: tempShareInput{
 				Text:      "",
-				Expires:   "1",
-				ViewLimit: "1",
+				Expires:   1,
+				ViewLimit: 1,
 			},
 			expectedTempShare: &models.TempShare{
 				Text:      "",
@@ -58,7 +61,7 @@ func TestNew(t *testing.T) {
 
 			model := &TempShareModel{db}
 
-			tempShare, err := model.New(testCase.inputTempShare.Text, testCase.inputTempShare.Expires, testCase.inputTempShare.ViewLimit)
+			tempShare, err := model.New(context.Background(), testCase.inputTempShare.Text, testCase.inputTempShare.Expires, testCase.inputTempShare.ViewLimit, "", false, time.Time{})
 			if err != testCase.expectedError {
 				t.Errorf("Expected %v, received %v", testCase.expectedError, err)
 			}
@@ -76,6 +79,7 @@ func TestGet(t *testing.T) {
 	testCases := []struct {
 		name                string
 		inputPlainTextToken string
+		inputPassphrase     string
 		expectedTempShare   *models.TempShare
 		expectedError       error
 	}{
@@ -95,19 +99,64 @@ func TestGet(t *testing.T) {
 			name:                "No matching record",
 			inputPlainTextToken: "FEAQ44QWC7QZ2P5D5NW3Y64UJFTR43TPBEWDCQ4B2HRCNXPSDBXA",
 			expectedTempShare:   nil,
-			expectedError:       models.ErrNoRecord,
+			expectedError:       models.ErrNotFound,
 		},
 		{
 			name:                "Empty token",
 			inputPlainTextToken: "",
 			expectedTempShare:   nil,
-			expectedError:       models.ErrNoRecord,
+			expectedError:       models.ErrNotFound,
 		},
 		{
-			name:                "Expired tempshare",
+			name:                "Exhausted tempshare",
 			inputPlainTextToken: "HVN2JMTD5DVPODS632YXWVT6REYSXR26O7B3G5ZBQRD72IOBYTVA",
 			expectedTempShare:   nil,
-			expectedError:       models.ErrNoRecord,
+			expectedError:       models.ErrViewLimitReached,
+		},
+		{
+			name:                "Expired tempshare",
+			inputPlainTextToken: "EXPIREDSHAREJ4XK7Q3ZBTN5DWV6CMRA2ZQ3XK5RWMN4VBTYHCQL",
+			expectedTempShare:   nil,
+			expectedError:       models.ErrExpired,
+		},
+		{
+			name:                "Revoked tempshare",
+			inputPlainTextToken: "REVOKEDSHAREJ4XK7Q3ZBTN5DWV6CMRA2ZQ3XK5RWMN4VBTYHCQL",
+			expectedTempShare:   nil,
+			expectedError:       models.ErrRevoked,
+		},
+		{
+			name:                "Valid passphrase",
+			inputPlainTextToken: "P7ZJ2K5RXQ3MWN4VBTYHC6LDGAEUFSO2I7ZQ3XK5RWMN4VBTYHCQ",
+			inputPassphrase:     "abcde fghij klmno pqrst",
+			expectedTempShare: &models.TempShare{
+				Text:      "This is a tempshare protected by a passphrase!",
+				Created:   time.Date(2022, 3, 2, 12, 0, 0, 0, time.UTC),
+				Expires:   time.Date(2048, 3, 9, 12, 0, 0, 0, time.UTC),
+				Views:     0,
+				ViewLimit: 1,
+			},
+			expectedError: nil,
+		},
+		{
+			name:                "Incorrect passphrase",
+			inputPlainTextToken: "P7ZJ2K5RXQ3MWN4VBTYHC6LDGAEUFSO2I7ZQ3XK5RWMN4VBTYHCQ",
+			inputPassphrase:     "ABCDE-FGHIJ-KLMNO-PQRSA",
+			expectedTempShare:   nil,
+			expectedError:       models.ErrInvalidPassphrase,
+		},
+		{
+			name:                "Missing passphrase",
+			inputPlainTextToken: "P7ZJ2K5RXQ3MWN4VBTYHC6LDGAEUFSO2I7ZQ3XK5RWMN4VBTYHCQ",
+			inputPassphrase:     "",
+			expectedTempShare:   nil,
+			expectedError:       models.ErrInvalidPassphrase,
+		},
+		{
+			name:                "Time-locked tempshare",
+			inputPlainTextToken: "LOCKEDSHAREAJ4XK7Q3ZBTN5DWV6CMRA2ZQ3XK5RWMN4VBTYHCQL",
+			expectedTempShare:   nil,
+			expectedError:       models.ErrNotYetAvailable,
 		},
 	}
 
@@ -124,8 +173,8 @@ func TestGet(t *testing.T) {
 
 			model := &TempShareModel{db}
 
-			tempShare, err := model.Get(testCase.inputPlainTextToken)
-			if err != testCase.expectedError {
+			tempShare, err := model.Get(context.Background(), testCase.inputPlainTextToken, testCase.inputPassphrase)
+			if !errors.Is(err, testCase.expectedError) {
 				t.Errorf("Expected %v, received %v", testCase.expectedError, err)
 			}
 
@@ -138,3 +187,413 @@ func TestGet(t *testing.T) {
 		})
 	}
 }
+
+func TestGetIncorrectPassphraseKeepsView(t *testing.T) {
+	db, teardown := newTestDatabase(t)
+	defer teardown()
+
+	model := &TempShareModel{db}
+
+	_, err := model.Get(context.Background(), "P7ZJ2K5RXQ3MWN4VBTYHC6LDGAEUFSO2I7ZQ3XK5RWMN4VBTYHCQ", "WRONG")
+	if err != models.ErrInvalidPassphrase {
+		t.Fatalf("Expected %v, received %v", models.ErrInvalidPassphrase, err)
+	}
+
+	// The incorrect attempt must not have used up the only view
+	_, err = model.Get(context.Background(), "P7ZJ2K5RXQ3MWN4VBTYHC6LDGAEUFSO2I7ZQ3XK5RWMN4VBTYHCQ", "ABCDE-FGHIJ-KLMNO-PQRST")
+	if err != nil {
+		t.Errorf("Expected %v, received %v", nil, err)
+	}
+}
+
+func TestGetTimeLocked(t *testing.T) {
+	db, teardown := newTestDatabase(t)
+	defer teardown()
+
+	model := &TempShareModel{db}
+
+	_, err := model.Get(context.Background(), "LOCKEDSHAREAJ4XK7Q3ZBTN5DWV6CMRA2ZQ3XK5RWMN4VBTYHCQL", "")
+
+	var notYet *models.NotYetAvailableError
+	if !errors.As(err, &notYet) {
+		t.Fatalf("Expected %T, received %v", notYet, err)
+	}
+
+	expected := time.Date(2048, 3, 8, 12, 0, 0, 0, time.UTC)
+	if !notYet.NotBefore.Equal(expected) {
+		t.Errorf("Expected %v, received %v", expected, notYet.NotBefore)
+	}
+
+	// The early attempt must not have used up the only view
+	tempShare, err := model.GetManaged(context.Background(), "LOCKEDMANAGEJ4XK7Q3ZBTN5DWV6CMRA2ZQ3XK5RWMN4VBTYHCQL")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tempShare.Views != 0 {
+		t.Errorf("Expected %d views, received %d", 0, tempShare.Views)
+	}
+}
+
+func TestGetLastViewLeavesTombstone(t *testing.T) {
+	db, teardown := newTestDatabase(t)
+	defer teardown()
+
+	model := &TempShareModel{db}
+
+	_, err := model.Get(context.Background(), "FTR43TPBEWDCQ4B2HRCNXPSDBXFEAQ44QWC7QZ2P5D5NW3Y64UJA", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = model.Get(context.Background(), "FTR43TPBEWDCQ4B2HRCNXPSDBXFEAQ44QWC7QZ2P5D5NW3Y64UJA", "")
+	if err != models.ErrViewLimitReached {
+		t.Errorf("Expected %v, received %v", models.ErrViewLimitReached, err)
+	}
+
+	// Only the hash and final state of the entry are kept once its last view has been used
+	var text string
+	hash := sha256.Sum256([]byte("FTR43TPBEWDCQ4B2HRCNXPSDBXFEAQ44QWC7QZ2P5D5NW3Y64UJA"))
+	err = db.QueryRow("SELECT text FROM texts WHERE urltoken = ?", hash[:]).Scan(&text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "" {
+		t.Errorf("Expected the text to be cleared, received %s", text)
+	}
+}
+
+func TestGeneratePassphrase(t *testing.T) {
+	passphrase, err := generatePassphrase()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(passphrase) != 23 || strings.Count(passphrase, "-") != 3 {
+		t.Errorf("Expected a passphrase of the form XXXXX-XXXXX-XXXXX-XXXXX, received %s", passphrase)
+	}
+
+	if !reflect.DeepEqual(hashPassphrase(passphrase), hashPassphrase(strings.ToLower(strings.ReplaceAll(passphrase, "-", " ")))) {
+		t.Errorf("Expected passphrase hash to ignore case and separators")
+	}
+}
+
+func TestGetManaged(t *testing.T) {
+
+	testCases := []struct {
+		name                      string
+		inputPlainTextManageToken string
+		expectedViews             int
+		expectedError             error
+	}{
+		{
+			name:                      "Valid token",
+			inputPlainTextManageToken: "Z7CWGQ2LNVRA4X6TKEJ5YBUHF3OIDP2MS7QZV4XN6CGKLA5WTRBQ",
+			expectedViews:             1,
+			expectedError:             nil,
+		},
+		{
+			name:                      "Share token is not a management token",
+			inputPlainTextManageToken: "HVN2JMTD5DVPODS632YXWVT6REYSXR26O7B3G5ZBQRD72IOBYTVA",
+			expectedError:             models.ErrNoRecord,
+		},
+		{
+			name:                      "Empty token",
+			inputPlainTextManageToken: "",
+			expectedError:             models.ErrNoRecord,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			db, teardown := newTestDatabase(t)
+			defer teardown()
+
+			model := &TempShareModel{db}
+
+			tempShare, err := model.GetManaged(context.Background(), testCase.inputPlainTextManageToken)
+			if err != testCase.expectedError {
+				t.Errorf("Expected %v, received %v", testCase.expectedError, err)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if tempShare.Text != "" {
+				t.Errorf("Expected no text, received %s", tempShare.Text)
+			}
+
+			if tempShare.Views != testCase.expectedViews {
+				t.Errorf("Expected %d views, received %d", testCase.expectedViews, tempShare.Views)
+			}
+		})
+	}
+}
+
+func TestViews(t *testing.T) {
+	db, teardown := newTestDatabase(t)
+	defer teardown()
+
+	model := &TempShareModel{db}
+
+	hash := sha256.Sum256([]byte("FTR43TPBEWDCQ4B2HRCNXPSDBXFEAQ44QWC7QZ2P5D5NW3Y64UJA"))
+
+	views, err := model.Views(context.Background(), hash[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(views) != 0 {
+		t.Errorf("Expected 0 views, received %d", len(views))
+	}
+
+	err = model.AddView(context.Background(), hash[:], "Firefox from 192.0.2.0/24")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	views, err = model.Views(context.Background(), hash[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(views) != 1 {
+		t.Fatalf("Expected 1 view, received %d", len(views))
+	}
+
+	if views[0].Client != "Firefox from 192.0.2.0/24" {
+		t.Errorf("Expected %s, received %s", "Firefox from 192.0.2.0/24", views[0].Client)
+	}
+}
+
+func TestNewSplitCombine(t *testing.T) {
+	db, teardown := newTestDatabase(t)
+	defer teardown()
+
+	model := &TempShareModel{db}
+
+	secret := "This is an example tempshare for testing purposes!"
+
+	tempShares, err := model.NewSplit(context.Background(), secret, 1, 1, 3, 2, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tempShares) != 3 {
+		t.Fatalf("Expected 3 parts, received %d", len(tempShares))
+	}
+
+	// A single part can't be viewed on its own
+	_, err = model.Get(context.Background(), tempShares[0].PlainText, "")
+	if err != models.ErrSplitShare {
+		t.Errorf("Expected %v, received %v", models.ErrSplitShare, err)
+	}
+
+	// Too few parts must not consume any views
+	_, _, err = model.Combine(context.Background(), []string{tempShares[0].PlainText, tempShares[0].PlainText})
+	if err != models.ErrThresholdNotMet {
+		t.Errorf("Expected %v, received %v", models.ErrThresholdNotMet, err)
+	}
+
+	combined, parts, err := model.Combine(context.Background(), []string{tempShares[0].PlainText, tempShares[2].PlainText})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if combined != secret {
+		t.Errorf("Expected %s, received %s", secret, combined)
+	}
+	if len(parts) != 2 {
+		t.Errorf("Expected 2 parts, received %d", len(parts))
+	}
+
+	// Each part had a view limit of one, so combining them again must fail
+	_, _, err = model.Combine(context.Background(), []string{tempShares[0].PlainText, tempShares[1].PlainText})
+	if err != models.ErrViewLimitReached {
+		t.Errorf("Expected %v, received %v", models.ErrViewLimitReached, err)
+	}
+
+	// Unknown tokens, and tokens of shares which aren't split, are told apart
+	_, _, err = model.Combine(context.Background(), []string{tempShares[1].PlainText, "UNKNOWNPARTAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA"})
+	if err != models.ErrNotFound {
+		t.Errorf("Expected %v, received %v", models.ErrNotFound, err)
+	}
+
+	_, _, err = model.Combine(context.Background(), []string{tempShares[1].PlainText, "FTR43TPBEWDCQ4B2HRCNXPSDBXFEAQ44QWC7QZ2P5D5NW3Y64UJA"})
+	if err != models.ErrNotSplitShare {
+		t.Errorf("Expected %v, received %v", models.ErrNotSplitShare, err)
+	}
+
+	// Parts of different secrets can't be combined
+	otherShares, err := model.NewSplit(context.Background(), secret, 1, 1, 2, 2, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err = model.Combine(context.Background(), []string{tempShares[1].PlainText, otherShares[0].PlainText})
+	if err != models.ErrMixedShares {
+		t.Errorf("Expected %v, received %v", models.ErrMixedShares, err)
+	}
+}
+
+func TestNewMulti(t *testing.T) {
+	db, teardown := newTestDatabase(t)
+	defer teardown()
+
+	model := &TempShareModel{db}
+
+	text := "This is an example tempshare for testing purposes!"
+
+	manageToken, tempShares, err := model.NewMulti(context.Background(), text, 1, 3, "", []string{"Bob", "Alice"}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tempShares) != 2 {
+		t.Fatalf("Expected 2 recipients, received %d", len(tempShares))
+	}
+
+	// Every recipient reads the same text through their own link
+	tempShare, err := model.Get(context.Background(), tempShares[1].PlainText, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tempShare.Text != text || tempShare.Recipient != "Alice" {
+		t.Errorf("Expected %s for %s, received %s for %s", text, "Alice", tempShare.Text, tempShare.Recipient)
+	}
+
+	_, err = model.RevokeRecipient(context.Background(), manageToken, "Bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = model.Get(context.Background(), tempShares[0].PlainText, "")
+	if err != models.ErrRevoked {
+		t.Errorf("Expected %v, received %v", models.ErrRevoked, err)
+	}
+
+	recipients, err := model.GetRecipients(context.Background(), manageToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []struct {
+		recipient string
+		views     int
+		revoked   bool
+	}{
+		{"Alice", 1, false},
+		{"Bob", 0, true},
+	}
+
+	if len(recipients) != len(expected) {
+		t.Fatalf("Expected %d recipients, received %d", len(expected), len(recipients))
+	}
+	for i, recipient := range recipients {
+		if recipient.Recipient != expected[i].recipient || recipient.Views != expected[i].views || recipient.Revoked != expected[i].revoked {
+			t.Errorf("Expected %+v, received %+v", expected[i], recipient)
+		}
+	}
+
+	// Recipients are managed through the token of the payload, not one of their own
+	_, err = model.GetRecipients(context.Background(), tempShares[0].PlainText)
+	if err != models.ErrNoRecord {
+		t.Errorf("Expected %v, received %v", models.ErrNoRecord, err)
+	}
+}
+
+func TestNewMultiClearsPayload(t *testing.T) {
+
+	testCases := []struct {
+		name      string
+		viewFirst bool
+	}{
+		{"Last recipient revoked", true},
+		{"Last recipient viewed", false},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			db, teardown := newTestDatabase(t)
+			defer teardown()
+
+			model := &TempShareModel{db}
+
+			manageToken, tempShares, err := model.NewMulti(context.Background(), "This is an example tempshare for testing purposes!", 1, 1, "", []string{"Bob", "Alice"}, time.Time{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			view := func() {
+				if _, err := model.Get(context.Background(), tempShares[1].PlainText, ""); err != nil {
+					t.Fatal(err)
+				}
+			}
+			revoke := func() {
+				if _, err := model.RevokeRecipient(context.Background(), manageToken, "Bob"); err != nil {
+					t.Fatal(err)
+				}
+			}
+			payload := func() string {
+				var text string
+				err := db.QueryRow("SELECT text FROM payloads").Scan(&text)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return text
+			}
+
+			first, last := revoke, view
+			if testCase.viewFirst {
+				first, last = view, revoke
+			}
+
+			// The text is kept while a recipient can still view it
+			first()
+			if payload() == "" {
+				t.Fatal("Expected the payload to be kept while a recipient can view it")
+			}
+
+			last()
+			if text := payload(); text != "" {
+				t.Errorf("Expected the payload to be cleared, received %q", text)
+			}
+		})
+	}
+}
+
+func TestStats(t *testing.T) {
+	db, teardown := newTestDatabase(t)
+	defer teardown()
+
+	model := &TempShareModel{db}
+
+	// Only the seeded TempShares which can still be viewed are active, one of them time-locked
+	stats, err := model.Stats(context.Background(), 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Active != 3 || stats.TimeLocked != 1 || stats.ExpiringLater != 3 {
+		t.Errorf("Expected 3 active, 1 time-locked and 3 expiring later, received %+v", stats)
+	}
+	if stats.StorageBytes == 0 {
+		t.Error("Expected the storage used to be counted")
+	}
+
+	_, err = model.New(context.Background(), "This is an example tempshare for testing purposes!", 1, 1, "", false, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err = model.Stats(context.Background(), 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats.Created) != 7 || len(stats.Viewed) != 7 {
+		t.Fatalf("Expected 7 days, received %d and %d", len(stats.Created), len(stats.Viewed))
+	}
+
+	// The seeded TempShares were created years ago, so only the new one counts, today
+	today := stats.Created[6]
+	if today.Count != 1 || !today.Day.Equal(time.Now().UTC().Truncate(24*time.Hour)) {
+		t.Errorf("Expected 1 created today, received %d on %s", today.Count, today.Day)
+	}
+	if stats.Active != 4 || stats.ExpiringWithinDay != 1 {
+		t.Errorf("Expected 4 active and 1 expiring within a day, received %+v", stats)
+	}
+}
@@ -1,15 +1,79 @@
 CREATE TABLE IF NOT EXISTS texts (
     urltoken BINARY(32) NOT NULL PRIMARY KEY,
+    managetoken BINARY(32) NOT NULL,
+    passphrase VARBINARY(32) NOT NULL DEFAULT '',
     text TEXT NOT NULL,
+    sharegroup VARBINARY(16) NOT NULL DEFAULT '',
+    threshold INTEGER NOT NULL DEFAULT 0,
+    recipient VARCHAR(64) NOT NULL DEFAULT '',
+    notify VARCHAR(254) NOT NULL DEFAULT '',
     created DATETIME NOT NULL,
     expires DATETIME NOT NULL,
+    notbefore DATETIME NULL,
     views INTEGER NOT NULL,
-    viewlimit INTEGER NOT NULL
+    viewlimit INTEGER NOT NULL,
+    revoked BOOLEAN NOT NULL DEFAULT FALSE,
+    expirednotified BOOLEAN NOT NULL DEFAULT FALSE,
+    UNIQUE INDEX idx_texts_managetoken (managetoken)
+);
+
+CREATE TABLE IF NOT EXISTS payloads (
+    sharegroup VARBINARY(16) NOT NULL PRIMARY KEY,
+    managetoken BINARY(32) NOT NULL,
+    text TEXT NOT NULL,
+    UNIQUE INDEX idx_payloads_managetoken (managetoken)
+);
+
+CREATE TABLE IF NOT EXISTS views (
+    urltoken BINARY(32) NOT NULL,
+    viewed DATETIME NOT NULL,
+    client VARCHAR(255) NOT NULL,
+    INDEX idx_views_urltoken (urltoken)
+);
+
+CREATE TABLE IF NOT EXISTS requests (
+    uploadtoken BINARY(32) NOT NULL PRIMARY KEY,
+    readtoken BINARY(32) NOT NULL,
+    text TEXT NULL,
+    created DATETIME NOT NULL,
+    expires DATETIME NOT NULL,
+    fulfilled DATETIME NULL,
+    viewed BOOLEAN NOT NULL DEFAULT FALSE,
+    UNIQUE INDEX idx_requests_readtoken (readtoken)
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    destination VARCHAR(255) NOT NULL,
+    event VARCHAR(32) NOT NULL,
+    payload TEXT NOT NULL,
+    attempts INTEGER NOT NULL,
+    nextattempt DATETIME NOT NULL,
+    created DATETIME NOT NULL,
+    delivered DATETIME NULL,
+    failed BOOLEAN NOT NULL DEFAULT FALSE,
+    INDEX idx_webhook_deliveries_nextattempt (nextattempt)
+);
+
+CREATE TABLE IF NOT EXISTS admins (
+    id INTEGER NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    username VARCHAR(64) NOT NULL,
+    passwordhash VARCHAR(255) NOT NULL,
+    totpsecret VARCHAR(64) NOT NULL,
+    lasttotpstep BIGINT NOT NULL DEFAULT 0,
+    failedtotpattempts INTEGER NOT NULL DEFAULT 0,
+    failedpasswordattempts INTEGER NOT NULL DEFAULT 0,
+    lockeduntil DATETIME NULL,
+    sessiongeneration INTEGER NOT NULL DEFAULT 0,
+    created DATETIME NOT NULL,
+    UNIQUE INDEX idx_admins_username (username)
 );
 
 /*plainTextToken: FTR43TPBEWDCQ4B2HRCNXPSDBXFEAQ44QWC7QZ2P5D5NW3Y64UJA */
-INSERT INTO texts (urltoken, text, created, expires, views, viewlimit) VALUES (
+/*plainTextManageToken: MQXK4RT2ZB7YW5VNDH3LCE6GAJOS2PUIF4XK7Q3ZBTN5DWV6CMRA */
+INSERT INTO texts (urltoken, managetoken, text, created, expires, views, viewlimit) VALUES (
     0x3FA4941C5FDA1A71EA31A94312ADB2CA58480F98415B2A3F1D24F8F99F7C5C3C,
+    0xB02F17C22F1498BA552A2A87A56F6B0F36A43A08CF89FA726EC944495FEC56AE,
     'This is an example tempshare for testing purposes!',
     '2022-03-02 12:00:00',
     '2048-03-09 12:00:00',
@@ -18,11 +82,71 @@ INSERT INTO texts (urltoken, text, created, expires, views, viewlimit) VALUES (
 );
 
 /*plainTextToken: HVN2JMTD5DVPODS632YXWVT6REYSXR26O7B3G5ZBQRD72IOBYTVA */
-INSERT INTO texts (urltoken, text, created, expires, views, viewlimit) VALUES (
+/*plainTextManageToken: Z7CWGQ2LNVRA4X6TKEJ5YBUHF3OIDP2MS7QZV4XN6CGKLA5WTRBQ */
+INSERT INTO texts (urltoken, managetoken, text, created, expires, views, viewlimit) VALUES (
     0x87236F3ED11C646E80652DE80FB121F6315BB5BB7C649E83251DD088D2A61148,
-    'This is an expired tempshare!',
+    0x2CE956E828629ED5817BD19ABA02B3506A75A83F1F75C5DCC033F1E351D13C7E,
+    'This is an exhausted tempshare!',
     '2022-03-02 12:00:00',
     '2048-03-09 12:00:00',
     1,
     1
 );
+
+/*plainTextToken: P7ZJ2K5RXQ3MWN4VBTYHC6LDGAEUFSO2I7ZQ3XK5RWMN4VBTYHCQ */
+/*plainTextManageToken: K2DWQ7XNZR5TB3YHMC6VLPGA4JEUFSO7I2ZQ3XK5RWMN4VBTYHDA */
+/*passphrase: ABCDE-FGHIJ-KLMNO-PQRST */
+INSERT INTO texts (urltoken, managetoken, passphrase, text, created, expires, views, viewlimit) VALUES (
+    0x6C9B42C51F3DFA4C6B58847E11F5552C46912C5D5EB5CC698BFA899528A925FF,
+    0xF52F1E7C54FA0BCC7549782D2D54F177DF9E7C5CC3929658D6FF5AF6259C6D4F,
+    0x40800C4DC7925AA3CE2BD450F0B46EFE056DBF5F4A83844555A43564B680A8AE,
+    'This is a tempshare protected by a passphrase!',
+    '2022-03-02 12:00:00',
+    '2048-03-09 12:00:00',
+    0,
+    1
+);
+
+/*plainTextToken: LOCKEDSHAREAJ4XK7Q3ZBTN5DWV6CMRA2ZQ3XK5RWMN4VBTYHCQL */
+/*plainTextManageToken: LOCKEDMANAGEJ4XK7Q3ZBTN5DWV6CMRA2ZQ3XK5RWMN4VBTYHCQL */
+INSERT INTO texts (urltoken, managetoken, text, created, expires, notbefore, views, viewlimit) VALUES (
+    0xBF4D4F06E8A7F472C812B0ACDCF32DB69BF98146F6F16C4E1810DA0DDF1F05A3,
+    0x4F9C49DBCA42E23539B6DA9D2055B5D5730F50C3DD7756F86FECAC8C82AD6F5E,
+    'This is a time-locked tempshare!',
+    '2022-03-02 12:00:00',
+    '2048-03-09 12:00:00',
+    '2048-03-08 12:00:00',
+    0,
+    1
+);
+
+/*plainTextToken: EXPIREDSHAREJ4XK7Q3ZBTN5DWV6CMRA2ZQ3XK5RWMN4VBTYHCQL */
+/*plainTextManageToken: EXPIREDMANAGEJ4XK7Q3ZBTN5DWV6CMRA2ZQ3XK5RWMN4VBTYHCQ */
+INSERT INTO texts (urltoken, managetoken, text, created, expires, views, viewlimit) VALUES (
+    0xBA50F4DAC7985DB9D2457D7474D9BE41A37DFCD90AB93F3FFF7FC7D5CE8D49AC,
+    0x1D3B5BC8A868C7EC91A53A535C9DEAAE999423A91B5BF37721773E2DDEDED18E,
+    'This is an expired tempshare!',
+    '2022-03-02 12:00:00',
+    '2022-03-03 12:00:00',
+    0,
+    1
+);
+
+/*plainTextToken: REVOKEDSHAREJ4XK7Q3ZBTN5DWV6CMRA2ZQ3XK5RWMN4VBTYHCQL */
+/*plainTextManageToken: REVOKEDMANAGEJ4XK7Q3ZBTN5DWV6CMRA2ZQ3XK5RWMN4VBTYHCQ */
+INSERT INTO texts (urltoken, managetoken, text, created, expires, views, viewlimit, revoked) VALUES (
+    0x5046FD22430254911E940A63640DA12DA42A92B2818716975E5EA5B1C360BAFC,
+    0xB931D609E8B0485E51630318E72A873B81164AF1995D87D7EBEC81F0F9F52639,
+    '',
+    '2022-03-02 12:00:00',
+    '2048-03-09 12:00:00',
+    0,
+    1,
+    TRUE
+);
+
+INSERT INTO views (urltoken, viewed, client) VALUES (
+    0x87236F3ED11C646E80652DE80FB121F6315BB5BB7C649E83251DD088D2A61148,
+    '2022-03-03 12:00:00',
+    'Firefox from 192.0.2.0/24'
+);
\ No newline at end of file
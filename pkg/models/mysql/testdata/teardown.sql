@@ -1 +1,6 @@
-DROP TABLE texts;
\ No newline at end of file
+DROP TABLE texts;
+DROP TABLE payloads;
+DROP TABLE views;
+DROP TABLE webhook_deliveries;
+DROP TABLE requests;
+DROP TABLE admins;
\ No newline at end of file
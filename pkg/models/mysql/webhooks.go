@@ -0,0 +1,128 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/matthewlmitchell/tempshare/pkg/models"
+)
+
+type WebhookModel struct {
+	DB *sql.DB
+}
+
+// Enqueue persists an event to be delivered to the named destination, so that
+// pending deliveries survive a restart of the server.
+func (model *WebhookModel) Enqueue(ctx context.Context, destination string, event string, payload []byte) error {
+
+	sqlStatement := `INSERT INTO webhook_deliveries (destination, event, payload, attempts, nextattempt, created, delivered, failed)
+	VALUES(?, ?, ?, 0, UTC_TIMESTAMP(), UTC_TIMESTAMP(), NULL, FALSE)`
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	_, err := model.DB.ExecContext(ctx, sqlStatement, destination, event, payload)
+
+	return err
+}
+
+// Claim returns up to limit deliveries which are due to be attempted. Claimed deliveries
+// have their next attempt pushed back by lease inside the same transaction, so that
+// they aren't picked up again by another worker (or replica) while being delivered.
+// If the worker dies mid-delivery the lease simply runs out and the delivery is retried.
+func (model *WebhookModel) Claim(ctx context.Context, limit int, lease time.Duration) ([]*models.Delivery, error) {
+
+	selectStatement := `SELECT id, destination, event, payload, attempts, nextattempt, created FROM webhook_deliveries
+	WHERE delivered IS NULL AND failed = FALSE AND nextattempt <= UTC_TIMESTAMP()
+	ORDER BY nextattempt ASC LIMIT ? FOR UPDATE`
+
+	updateStatement := `UPDATE webhook_deliveries SET nextattempt = DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? SECOND) WHERE id = ?`
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	tx, err := model.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	// Rollback is a no-op once the transaction has been committed
+	defer tx.Rollback()
+
+	sqlRows, err := tx.QueryContext(ctx, selectStatement, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	deliveries := []*models.Delivery{}
+
+	for sqlRows.Next() {
+		delivery := &models.Delivery{}
+
+		err = sqlRows.Scan(&delivery.ID, &delivery.Destination, &delivery.Event, &delivery.Payload, &delivery.Attempts, &delivery.NextAttempt, &delivery.Created)
+		if err != nil {
+			sqlRows.Close()
+			return nil, err
+		}
+
+		deliveries = append(deliveries, delivery)
+	}
+	sqlRows.Close()
+
+	if err = sqlRows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, delivery := range deliveries {
+		_, err = tx.ExecContext(ctx, updateStatement, int(lease.Seconds()), delivery.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+// Delivered marks a delivery as successfully delivered
+func (model *WebhookModel) Delivered(ctx context.Context, id int64) error {
+
+	sqlStatement := `UPDATE webhook_deliveries SET delivered = UTC_TIMESTAMP(), attempts = attempts + 1 WHERE id = ?`
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	_, err := model.DB.ExecContext(ctx, sqlStatement, id)
+
+	return err
+}
+
+// Retry records a failed attempt of a delivery and schedules the next attempt after delay
+func (model *WebhookModel) Retry(ctx context.Context, id int64, delay time.Duration) error {
+
+	sqlStatement := `UPDATE webhook_deliveries SET attempts = attempts + 1,
+	nextattempt = DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? SECOND) WHERE id = ?`
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	_, err := model.DB.ExecContext(ctx, sqlStatement, int(delay.Seconds()), id)
+
+	return err
+}
+
+// Fail records a final failed attempt of a delivery, after which it is never retried
+func (model *WebhookModel) Fail(ctx context.Context, id int64) error {
+
+	sqlStatement := `UPDATE webhook_deliveries SET attempts = attempts + 1, failed = TRUE WHERE id = ?`
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	_, err := model.DB.ExecContext(ctx, sqlStatement, id)
+
+	return err
+}
@@ -0,0 +1,71 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/matthewlmitchell/tempshare/pkg/mailer"
+)
+
+// Event is emitted whenever a TempShare is opened by a recipient. Share is a short,
+// non-reversible identifier of the TempShare, and Recipient is the (optional) email address
+// the creator asked for read receipts to be sent to.
+type Event struct {
+	Share          string    `json:"share"`
+	Recipient      string    `json:"-"`
+	Viewed         time.Time `json:"viewed"`
+	Client         string    `json:"client"`
+	ViewsRemaining int       `json:"views_remaining"`
+}
+
+// Notifier is implemented by every channel that can deliver view notifications to a creator
+type Notifier interface {
+	Notify(event *Event) error
+}
+
+// SMTPNotifier emails a read receipt to the recipient of an Event through a mailer.Queue,
+// using the "receipt.mail.tmpl" email template. Events without a recipient are ignored.
+type SMTPNotifier struct {
+	Queue *mailer.Queue
+}
+
+func (notifier *SMTPNotifier) Notify(event *Event) error {
+	if event.Recipient == "" {
+		return nil
+	}
+
+	return notifier.Queue.Enqueue(event.Recipient, "receipt.mail.tmpl", map[string]interface{}{
+		"Share":          event.Share,
+		"Viewed":         event.Viewed.UTC().Format("Jan 02 2006 at 15:04 UTC"),
+		"Client":         event.Client,
+		"ViewsRemaining": event.ViewsRemaining,
+	})
+}
+
+// WebhookNotifier sends every Event as a JSON encoded POST request to URL
+type WebhookNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+func (notifier *WebhookNotifier) Notify(event *Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	response, err := notifier.Client.Post(notifier.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("notify: webhook %s responded with status %d", notifier.URL, response.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+// Package overlayfs layers one file system over another, e.g. a directory of customized
+// files over the defaults compiled into the binary.
+package overlayfs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"sort"
+)
+
+// FS serves every file from Upper if it exists there, and from Lower otherwise.
+// Directories list the entries of both.
+type FS struct {
+	Upper fs.FS
+	Lower fs.FS
+}
+
+// New returns an FS serving the files of upper over those of lower
+func New(upper fs.FS, lower fs.FS) *FS {
+	return &FS{Upper: upper, Lower: lower}
+}
+
+// Open opens the named file from Upper, or from Lower if Upper doesn't have it.
+// Directories in Upper list the entries of both file systems.
+func (fsys *FS) Open(name string) (fs.File, error) {
+
+	file, err := fsys.Upper.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fsys.Lower.Open(name)
+	} else if err != nil {
+		return nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		return file, nil
+	}
+
+	entries, err := fsys.ReadDir(name)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &dir{File: file, entries: entries}, nil
+}
+
+// ReadDir returns the entries of the named directory in both file systems, sorted by name.
+// Entries in Upper replace those with the same name in Lower.
+func (fsys *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+
+	entries := map[string]fs.DirEntry{}
+	found := false
+
+	for _, layer := range []fs.FS{fsys.Lower, fsys.Upper} {
+		layerEntries, err := fs.ReadDir(layer, name)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		found = true
+		for _, entry := range layerEntries {
+			entries[entry.Name()] = entry
+		}
+	}
+
+	if !found {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+
+	merged := make([]fs.DirEntry, 0, len(entries))
+	for _, entry := range entries {
+		merged = append(merged, entry)
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].Name() < merged[j].Name() })
+
+	return merged, nil
+}
+
+// dir is a directory of Upper, listing the merged entries of both file systems
+type dir struct {
+	fs.File
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *dir) ReadDir(n int) ([]fs.DirEntry, error) {
+
+	remaining := d.entries[d.offset:]
+
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return remaining, nil
+	}
+
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+
+	if n > len(remaining) {
+		n = len(remaining)
+	}
+	d.offset += n
+
+	return remaining[:n], nil
+}
@@ -0,0 +1,59 @@
+package overlayfs
+
+import (
+	"io/fs"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestFS(t *testing.T) {
+
+	upper := fstest.MapFS{
+		"static/css/main.css":  {Data: []byte("custom")},
+		"static/css/theme.css": {Data: []byte("theme")},
+	}
+	lower := fstest.MapFS{
+		"static/css/main.css": {Data: []byte("default")},
+		"static/js/main.js":   {Data: []byte("script")},
+	}
+
+	fsys := New(upper, lower)
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"static/css/main.css", "custom"},
+		{"static/css/theme.css", "theme"},
+		{"static/js/main.js", "script"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := fs.ReadFile(fsys, tt.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("Expected %q, received %q", tt.want, data)
+			}
+		})
+	}
+
+	if _, err := fsys.Open("static/missing.css"); err == nil {
+		t.Error("Expected an error opening a missing file")
+	}
+
+	matches, err := fs.Glob(fsys, "static/css/*.css")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"static/css/main.css", "static/css/theme.css"}; !reflect.DeepEqual(matches, want) {
+		t.Errorf("Expected %v, received %v", want, matches)
+	}
+
+	if err := fstest.TestFS(fsys, "static/css/main.css", "static/css/theme.css", "static/js/main.js"); err != nil {
+		t.Error(err)
+	}
+}
@@ -1,14 +1,18 @@
 package recaptcha
 
 import (
+	"context"
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
 	"net/url"
-	"os"
+	"strings"
 	"time"
 )
 
+// APIEndpoint is the URL of the reCAPTCHA API that responses are verified with
+const APIEndpoint = "https://google.com/recaptcha/api/siteverify"
+
 // RecaptchaResponse is a custom struct used for unmarshalling the json response
 // from a POST request to the reCAPTCHA API endpoint.
 // Note: Score and Action are values used in reCAPTCHA v3 and are not used here at the moment.
@@ -22,11 +26,12 @@ type RecaptchaResponse struct {
 }
 
 // VerifyRecaptcha submits a POST request to the google reCAPTCHA API endpoint containing
-// the server-side reCAPTCHA secret key along with the client's "g-recaptcha-response" and
-// the client's IP address. The json response is then unmarshalled into our RecaptchaResponse
-// struct and we return whether true if the recaptcha challenge was successful, false otherwise.
-func VerifyRecaptcha(env string, client *http.Client, r *http.Request, gRecaptchaResponse string) (bool, error) {
-	googleAPIEndpoint := "https://google.com/recaptcha/api/siteverify"
+// the server-side reCAPTCHA secret key (or the test key if env is "testing") along with
+// the client's "g-recaptcha-response" and the client's IP address. The json response is then
+// unmarshalled into our RecaptchaResponse struct and we return whether true if the recaptcha
+// challenge was successful, false otherwise.
+func VerifyRecaptcha(env string, secret string, client *http.Client, r *http.Request, gRecaptchaResponse string) (bool, error) {
+	googleAPIEndpoint := APIEndpoint
 
 	// When launched in a test environment, use the following test key
 	// c.f. https://developers.google.com/recaptcha/docs/faq#id-like-to-run-automated-tests-with-recaptcha.-what-should-i-do
@@ -37,13 +42,21 @@ func VerifyRecaptcha(env string, client *http.Client, r *http.Request, gRecaptch
 	if env == "testing" {
 		requestData.Add("secret", "6LeIxAcTAAAAAGG-vFI1TnRWxMZNFuojJ4WifJWe")
 	} else {
-		requestData.Add("secret", os.Getenv("TEMPSHARE_reCAPTCHA_SECRET"))
+		requestData.Add("secret", secret)
 	}
 
-	response, err := client.PostForm(googleAPIEndpoint, requestData)
+	// The request carries the context of r, so that it's cancelled (and traced) along with it
+	request, err := http.NewRequestWithContext(r.Context(), http.MethodPost, googleAPIEndpoint, strings.NewReader(requestData.Encode()))
 	if err != nil {
 		return false, err
 	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	response, err := client.Do(request)
+	if err != nil {
+		return false, err
+	}
+	defer response.Body.Close()
 
 	responseData, err := ioutil.ReadAll(response.Body)
 	if err != nil {
@@ -62,3 +75,20 @@ func VerifyRecaptcha(env string, client *http.Client, r *http.Request, gRecaptch
 func (response *RecaptchaResponse) validate() bool {
 	return response.Success
 }
+
+// Ping checks that the reCAPTCHA API is reachable, without verifying anything.
+// Any HTTP response counts, since the API rejects requests without a secret key.
+func Ping(ctx context.Context, client *http.Client) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, APIEndpoint, nil)
+	if err != nil {
+		return err
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+
+	return nil
+}
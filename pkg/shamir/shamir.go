@@ -0,0 +1,153 @@
+// Package shamir implements Shamir's secret sharing over GF(2^8).
+//
+// Every byte of the secret is the constant term of its own random polynomial of
+// degree threshold-1, and each share holds that polynomial evaluated at a distinct,
+// non-zero x coordinate. Any threshold shares reconstruct the secret by Lagrange
+// interpolation at x = 0, while fewer reveal nothing about it.
+// The x coordinate of a share is stored as its last byte.
+package shamir
+
+import (
+	"crypto/rand"
+	"errors"
+)
+
+const (
+	MinParts = 2
+	MaxParts = 255
+)
+
+var (
+	ErrInvalidParameters = errors.New("shamir: threshold must be between 2 and parts, and parts at most 255")
+	ErrEmptySecret       = errors.New("shamir: secret must not be empty")
+	ErrInvalidShares     = errors.New("shamir: shares must be of equal length with distinct coordinates")
+	ErrTooFewShares      = errors.New("shamir: at least 2 shares are required")
+)
+
+// expTable and logTable hold powers and discrete logarithms of the generator 3
+// in GF(2^8) with the AES reduction polynomial x^8 + x^4 + x^3 + x + 1
+var expTable, logTable [256]byte
+
+func init() {
+	x := byte(1)
+	for i := 0; i < 255; i++ {
+		expTable[i] = x
+		logTable[x] = byte(i)
+
+		// Multiply x by the generator 3, i.e. x*2 + x, reducing modulo the polynomial
+		doubled := x << 1
+		if x&0x80 != 0 {
+			doubled ^= 0x1b
+		}
+		x ^= doubled
+	}
+	expTable[255] = expTable[0]
+}
+
+func mul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+
+	return expTable[(int(logTable[a])+int(logTable[b]))%255]
+}
+
+func div(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+
+	return expTable[(int(logTable[a])-int(logTable[b])+255)%255]
+}
+
+// Split divides secret into parts shares, any threshold of which can be combined to
+// reconstruct it.
+func Split(secret []byte, parts int, threshold int) ([][]byte, error) {
+	if parts < MinParts || parts > MaxParts || threshold < MinParts || threshold > parts {
+		return nil, ErrInvalidParameters
+	}
+	if len(secret) == 0 {
+		return nil, ErrEmptySecret
+	}
+
+	shares := make([][]byte, parts)
+	for i := range shares {
+		shares[i] = make([]byte, len(secret)+1)
+		shares[i][len(secret)] = byte(i + 1)
+	}
+
+	coefficients := make([]byte, threshold)
+	for byteIndex, secretByte := range secret {
+		// coefficients[0] is the secret itself, the rest are random
+		coefficients[0] = secretByte
+		if _, err := rand.Read(coefficients[1:]); err != nil {
+			return nil, err
+		}
+
+		for _, share := range shares {
+			x := share[len(secret)]
+
+			// Evaluate the polynomial at x using Horner's method
+			y := byte(0)
+			for i := threshold - 1; i >= 0; i-- {
+				y = mul(y, x) ^ coefficients[i]
+			}
+
+			share[byteIndex] = y
+		}
+	}
+
+	return shares, nil
+}
+
+// Combine reconstructs a secret from shares created by Split. If fewer shares than the
+// threshold are supplied, the result is indistinguishable from random data, so callers
+// must know the threshold themselves.
+func Combine(shares [][]byte) ([]byte, error) {
+	if len(shares) < MinParts {
+		return nil, ErrTooFewShares
+	}
+
+	length := len(shares[0])
+	if length < 2 {
+		return nil, ErrInvalidShares
+	}
+
+	xs := make([]byte, len(shares))
+	seen := map[byte]bool{}
+	for i, share := range shares {
+		if len(share) != length {
+			return nil, ErrInvalidShares
+		}
+
+		x := share[length-1]
+		if x == 0 || seen[x] {
+			return nil, ErrInvalidShares
+		}
+
+		seen[x] = true
+		xs[i] = x
+	}
+
+	secret := make([]byte, length-1)
+	for byteIndex := range secret {
+		// Lagrange interpolation at x = 0. In GF(2^8) subtraction is the same as addition (xor).
+		value := byte(0)
+		for i, share := range shares {
+			basis := byte(1)
+			for j := range shares {
+				if i == j {
+					continue
+				}
+
+				basis = mul(basis, div(xs[j], xs[i]^xs[j]))
+			}
+
+			value ^= mul(share[byteIndex], basis)
+		}
+
+		secret[byteIndex] = value
+	}
+
+	return secret, nil
+}
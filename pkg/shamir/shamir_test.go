@@ -0,0 +1,103 @@
+package shamir
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSplitCombine(t *testing.T) {
+
+	secret := []byte("This is an example tempshare for testing purposes!")
+
+	testCases := []struct {
+		name      string
+		parts     int
+		threshold int
+		combine   []int // indices of the shares passed to Combine
+		expected  bool  // whether the combined secret should match
+	}{
+		{"Threshold met", 5, 3, []int{0, 2, 4}, true},
+		{"All shares", 5, 3, []int{0, 1, 2, 3, 4}, true},
+		{"Shares out of order", 5, 3, []int{4, 1, 3}, true},
+		{"Two of two", 2, 2, []int{1, 0}, true},
+		{"Below threshold", 5, 3, []int{0, 1}, false},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			shares, err := Split(secret, testCase.parts, testCase.threshold)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(shares) != testCase.parts {
+				t.Fatalf("Expected %d shares, received %d", testCase.parts, len(shares))
+			}
+
+			subset := [][]byte{}
+			for _, i := range testCase.combine {
+				subset = append(subset, shares[i])
+			}
+
+			combined, err := Combine(subset)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if bytes.Equal(combined, secret) != testCase.expected {
+				t.Errorf("Expected match to be %t, received %s", testCase.expected, combined)
+			}
+		})
+	}
+}
+
+func TestSplitInvalidParameters(t *testing.T) {
+
+	testCases := []struct {
+		name      string
+		secret    []byte
+		parts     int
+		threshold int
+		expected  error
+	}{
+		{"Threshold above parts", []byte("secret"), 3, 4, ErrInvalidParameters},
+		{"Threshold of one", []byte("secret"), 3, 1, ErrInvalidParameters},
+		{"Too many parts", []byte("secret"), 256, 2, ErrInvalidParameters},
+		{"Empty secret", []byte{}, 3, 2, ErrEmptySecret},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			_, err := Split(testCase.secret, testCase.parts, testCase.threshold)
+			if err != testCase.expected {
+				t.Errorf("Expected %v, received %v", testCase.expected, err)
+			}
+		})
+	}
+}
+
+func TestCombineInvalidShares(t *testing.T) {
+	shares, err := Split([]byte("secret"), 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name     string
+		shares   [][]byte
+		expected error
+	}{
+		{"Single share", shares[:1], ErrTooFewShares},
+		{"Duplicate share", [][]byte{shares[0], shares[0]}, ErrInvalidShares},
+		{"Mismatched length", [][]byte{shares[0], shares[1][1:]}, ErrInvalidShares},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			_, err := Combine(testCase.shares)
+			if err != testCase.expected {
+				t.Errorf("Expected %v, received %v", testCase.expected, err)
+			}
+		})
+	}
+}
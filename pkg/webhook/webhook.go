@@ -0,0 +1,188 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Types of events emitted over the lifetime of a TempShare
+const (
+	EventCreated   = "created"
+	EventViewed    = "viewed"
+	EventExhausted = "exhausted"
+	EventExpired   = "expired"
+	EventRevoked   = "revoked"
+)
+
+// MaxAttempts is the number of times a delivery is attempted before it is given up on
+const MaxAttempts = 10
+
+var (
+	ErrInvalidSignature = errors.New("webhook: invalid signature")
+	ErrExpiredSignature = errors.New("webhook: signature timestamp outside of tolerance")
+)
+
+// Event is the JSON payload sent to webhook destinations. Share is a short,
+// non-reversible identifier of the TempShare the event is about.
+type Event struct {
+	Type           string    `json:"type"`
+	Share          string    `json:"share"`
+	Time           time.Time `json:"time"`
+	Client         string    `json:"client,omitempty"`
+	ViewsRemaining int       `json:"views_remaining"`
+}
+
+// Destination is an endpoint which receives events. Each destination has its own
+// secret used for signing payloads, and may subscribe to a subset of event types.
+// If Events is empty, the destination receives every event.
+type Destination struct {
+	Name   string   `json:"name"`
+	URL    string   `json:"url"`
+	Secret string   `json:"secret"`
+	Events []string `json:"events"`
+}
+
+// LoadDestinations reads a JSON encoded list of destinations from the file at path
+func LoadDestinations(path string) ([]*Destination, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	destinations := []*Destination{}
+	err = json.Unmarshal(data, &destinations)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, destination := range destinations {
+		if destination.Name == "" || destination.URL == "" || destination.Secret == "" {
+			return nil, fmt.Errorf("webhook: destination %q requires a name, url and secret", destination.Name)
+		}
+	}
+
+	return destinations, nil
+}
+
+// Subscribed reports whether the destination wants to receive events of the given type
+func (destination *Destination) Subscribed(eventType string) bool {
+	if len(destination.Events) == 0 {
+		return true
+	}
+
+	for _, subscribed := range destination.Events {
+		if subscribed == eventType {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Sign returns the value of the X-TempShare-Signature header for a payload sent at
+// the given time, in the form "t=<unix timestamp>,v1=<hex encoded HMAC-SHA256>".
+// The timestamp is included in the MAC so that captured requests can't be replayed later.
+func Sign(secret string, timestamp time.Time, payload []byte) string {
+	return fmt.Sprintf("t=%d,v1=%s", timestamp.Unix(), computeMAC(secret, timestamp.Unix(), payload))
+}
+
+// Verify checks a X-TempShare-Signature header against the payload it was sent with,
+// rejecting signatures older (or newer) than tolerance.
+func Verify(secret string, header string, payload []byte, tolerance time.Duration) error {
+	var timestamp int64
+	var signature string
+
+	for _, part := range strings.Split(header, ",") {
+		keyValue := strings.SplitN(part, "=", 2)
+		if len(keyValue) != 2 {
+			return ErrInvalidSignature
+		}
+
+		switch keyValue[0] {
+		case "t":
+			parsed, err := strconv.ParseInt(keyValue[1], 10, 64)
+			if err != nil {
+				return ErrInvalidSignature
+			}
+			timestamp = parsed
+		case "v1":
+			signature = keyValue[1]
+		}
+	}
+
+	if timestamp == 0 || signature == "" {
+		return ErrInvalidSignature
+	}
+
+	age := time.Since(time.Unix(timestamp, 0))
+	if age > tolerance || age < -tolerance {
+		return ErrExpiredSignature
+	}
+
+	if !hmac.Equal([]byte(signature), []byte(computeMAC(secret, timestamp, payload))) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+func computeMAC(secret string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(payload)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Deliver POSTs a signed payload to the destination. Any response outside of
+// the 2xx range is treated as a failed delivery.
+func Deliver(client *http.Client, destination *Destination, deliveryID int64, eventType string, payload []byte) error {
+	request, err := http.NewRequest("POST", destination.URL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("X-TempShare-Event", eventType)
+	request.Header.Set("X-TempShare-Delivery", strconv.FormatInt(deliveryID, 10))
+	request.Header.Set("X-TempShare-Signature", Sign(destination.Secret, time.Now(), payload))
+
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("webhook: %s responded with status %d", destination.Name, response.StatusCode)
+	}
+
+	return nil
+}
+
+// Backoff returns how long to wait before the next attempt of a delivery which has
+// already failed the given number of times. The delay doubles with every attempt,
+// starting at 10 seconds and capped at one hour, with up to 10% of random jitter
+// so that many failed deliveries don't all retry at the same instant.
+func Backoff(attempts int) time.Duration {
+	delay := time.Hour
+	if attempts < 9 {
+		delay = 10 * time.Second << uint(attempts)
+		if delay > time.Hour {
+			delay = time.Hour
+		}
+	}
+
+	return delay + time.Duration(rand.Int63n(int64(delay/10)+1))
+}
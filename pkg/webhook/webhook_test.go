@@ -0,0 +1,131 @@
+package webhook
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+
+	payload := []byte(`{"type":"viewed"}`)
+
+	testCases := []struct {
+		name          string
+		secret        string
+		header        string
+		payload       []byte
+		expectedError error
+	}{
+		{
+			name:          "Valid signature",
+			secret:        "secret",
+			header:        Sign("secret", time.Now(), payload),
+			payload:       payload,
+			expectedError: nil,
+		},
+		{
+			name:          "Wrong secret",
+			secret:        "other secret",
+			header:        Sign("secret", time.Now(), payload),
+			payload:       payload,
+			expectedError: ErrInvalidSignature,
+		},
+		{
+			name:          "Modified payload",
+			secret:        "secret",
+			header:        Sign("secret", time.Now(), payload),
+			payload:       []byte(`{"type":"revoked"}`),
+			expectedError: ErrInvalidSignature,
+		},
+		{
+			name:          "Replayed signature",
+			secret:        "secret",
+			header:        Sign("secret", time.Now().Add(-time.Hour), payload),
+			payload:       payload,
+			expectedError: ErrExpiredSignature,
+		},
+		{
+			name:          "Malformed header",
+			secret:        "secret",
+			header:        "INVALID",
+			payload:       payload,
+			expectedError: ErrInvalidSignature,
+		},
+		{
+			name:          "Empty header",
+			secret:        "secret",
+			header:        "",
+			payload:       payload,
+			expectedError: ErrInvalidSignature,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := Verify(testCase.secret, testCase.header, testCase.payload, 5*time.Minute)
+			if err != testCase.expectedError {
+				t.Errorf("Expected %v, received %v", testCase.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+
+	testCases := []struct {
+		attempts    int
+		expectedMin time.Duration
+		expectedMax time.Duration
+	}{
+		{0, 10 * time.Second, 11 * time.Second},
+		{1, 20 * time.Second, 22 * time.Second},
+		{3, 80 * time.Second, 88 * time.Second},
+		{9, time.Hour, time.Hour + 6*time.Minute},
+		{50, time.Hour, time.Hour + 6*time.Minute},
+	}
+
+	for _, testCase := range testCases {
+		delay := Backoff(testCase.attempts)
+		if delay < testCase.expectedMin || delay > testCase.expectedMax {
+			t.Errorf("Expected delay between %s and %s after %d attempts, received %s", testCase.expectedMin, testCase.expectedMax, testCase.attempts, delay)
+		}
+	}
+}
+
+func TestDeliver(t *testing.T) {
+
+	payload := []byte(`{"type":"created"}`)
+	destination := &Destination{Name: "test", Secret: "secret"}
+
+	testServ := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if r.Header.Get("X-TempShare-Event") != EventCreated {
+			t.Errorf("Expected event %s, received %s", EventCreated, r.Header.Get("X-TempShare-Event"))
+		}
+
+		if err := Verify("secret", r.Header.Get("X-TempShare-Signature"), body, time.Minute); err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer testServ.Close()
+
+	destination.URL = testServ.URL
+	if err := Deliver(testServ.Client(), destination, 1, EventCreated, payload); err != nil {
+		t.Errorf("Expected successful delivery, received %v", err)
+	}
+
+	destination.Secret = "wrong secret"
+	if err := Deliver(testServ.Client(), destination, 2, EventCreated, payload); err == nil {
+		t.Errorf("Expected delivery with the wrong secret to fail")
+	}
+}
@@ -0,0 +1,8 @@
+// Package ui holds the templates and static files of the web interface, which are
+// compiled into the binary so that it doesn't depend on its working directory.
+package ui
+
+import "embed"
+
+//go:embed "html" "locales" "mail" "static"
+var Files embed.FS
@@ -22,4 +22,9 @@ switchTheme.addEventListener("click", () => {
 if(localStorage.getItem("dark-mode") == "enabled") {
 	document.body.classList.toggle("dark-mode");
 	document.getElementById("switch").checked = true;
-}
\ No newline at end of file
+}
+
+// Called by reCAPTCHA (see data-callback) once the challenge has been solved
+function enableSubmit() {
+	document.getElementById("submit").removeAttribute("disabled");
+}